@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const azureTranslatorBaseURL = "https://api.cognitive.microsofttranslator.com"
+
+// AzureTranslator implements Translator on top of the Azure AI Translator
+// v3 REST API. Region is the Azure region of the Translator resource.
+type AzureTranslator struct {
+	APIKey  string
+	Region  string
+	BaseURL string
+	Client  HTTPDoer
+	// Retry is nil to retry as maxRetries and backoff say
+	Retry *retryPolicy
+}
+
+func newAzureTranslator(apiKey string, region string) *AzureTranslator {
+	return &AzureTranslator{APIKey: apiKey, Region: region, BaseURL: azureTranslatorBaseURL, Client: httpClient}
+}
+
+// Azure mostly uses lowercase ISO codes, these are the exceptions.
+var deeplToAzureLangs = map[string]string{
+	"EN-GB":   "en",
+	"EN-US":   "en",
+	"PT-BR":   "pt",
+	"PT-PT":   "pt-pt",
+	"ZH":      "zh-Hans",
+	"ZH-HANS": "zh-Hans",
+	"ZH-HANT": "zh-Hant",
+}
+
+var azureToDeeplLangs = map[string]string{
+	"pt":      "PT-BR",
+	"pt-pt":   "PT-PT",
+	"zh-Hans": "ZH",
+	"zh-Hant": "ZH-HANT",
+}
+
+func toAzureLang(code string) string {
+	if code == "" {
+		return ""
+	}
+	if azure, ok := deeplToAzureLangs[strings.ToUpper(code)]; ok {
+		return azure
+	}
+	return strings.ToLower(baseLanguage(code))
+}
+
+func fromAzureLang(code string) string {
+	if deepl, ok := azureToDeeplLangs[code]; ok {
+		return deepl
+	}
+	return strings.ToUpper(code)
+}
+
+// AzureError is returned when the Azure Translator API answers with a
+// non-200 status.
+type AzureError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *AzureError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Azure Translator request failed with status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("Azure Translator request failed with status code: %d: %s", e.StatusCode, e.Message)
+}
+
+func azureError(resp *http.Response) error {
+	azureErr := &AzureError{StatusCode: resp.StatusCode}
+	var body struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
+		azureErr.Message = body.Error.Message
+	}
+	return azureErr
+}
+
+func (t *AzureTranslator) newRequest(ctx context.Context, method string, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	query.Set("api-version", "3.0")
+	req, err := http.NewRequestWithContext(ctx, method, t.BaseURL+path+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", t.APIKey)
+	req.Header.Set("Ocp-Apim-Subscription-Region", t.Region)
+	return req, nil
+}
+
+func (t *AzureTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	result, err := t.translate(context.Background(), text, sourceLang, targetLang)
+	return result.Text, err
+}
+
+// TranslateDetailed is Translate that also reports the detected source
+// language as a DeepL code.
+func (t *AzureTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.translate(context.Background(), text, sourceLang, targetLang)
+}
+
+// TranslateDetailedCtx is TranslateDetailed with the request cancelled once
+// ctx is done.
+func (t *AzureTranslator) TranslateDetailedCtx(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.translate(ctx, text, sourceLang, targetLang)
+}
+
+func (t *AzureTranslator) translate(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	requestData, err := json.Marshal([]map[string]string{{"Text": text}})
+	if err != nil {
+		return TranslationResult{}, fmt.Errorf("failed to marshal request data: %v", err)
+	}
+
+	query := url.Values{}
+	query.Set("to", toAzureLang(targetLang))
+	if sourceLang != "" {
+		query.Set("from", toAzureLang(sourceLang))
+	}
+
+	req, err := t.newRequest(ctx, "POST", "/translate", query, bytes.NewBuffer(requestData))
+	if err != nil {
+		return TranslationResult{}, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.Retry.do(t.Client, nil, req)
+	if err != nil {
+		return TranslationResult{}, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return TranslationResult{}, azureError(resp)
+	}
+
+	var response []struct {
+		DetectedLanguage struct {
+			Language string `json:"language"`
+		} `json:"detectedLanguage"`
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return TranslationResult{}, fmt.Errorf("failed to parse response body: %v", err)
+	}
+
+	if len(response) == 0 || len(response[0].Translations) == 0 {
+		return TranslationResult{}, errors.New("no translations found")
+	}
+
+	// Azure bills every character of the source text
+	result := TranslationResult{Text: response[0].Translations[0].Text, DetectedSourceLanguage: sourceLang, BilledCharacters: utf8.RuneCountInString(text)}
+	if detected := response[0].DetectedLanguage.Language; detected != "" {
+		result.DetectedSourceLanguage = fromAzureLang(detected)
+	}
+	return result, nil
+}
+
+func (t *AzureTranslator) Languages() ([]DeeplLanguage, error) {
+	query := url.Values{}
+	query.Set("scope", "translation")
+
+	req, err := t.newRequest(context.Background(), "GET", "/languages", query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.Retry.do(t.Client, nil, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, azureError(resp)
+	}
+
+	var response struct {
+		Translation map[string]struct {
+			Name string `json:"name"`
+		} `json:"translation"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	listed := make([]DeeplLanguage, 0, len(response.Translation))
+	for code, lang := range response.Translation {
+		listed = append(listed, DeeplLanguage{Code: code, Name: lang.Name})
+	}
+	languages := providerLanguages(listed, deeplToAzureLangs, toAzureLang, fromAzureLang)
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Code < languages[j].Code })
+
+	return languages, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureLangMapping(t *testing.T) {
+	tests := []struct {
+		deepl string
+		azure string
+	}{
+		{"EN-US", "en"},
+		{"de", "de"},
+		{"PT-BR", "pt"},
+		{"PT-PT", "pt-pt"},
+		{"ZH", "zh-Hans"},
+		{"ZH-HANT", "zh-Hant"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := toAzureLang(tt.deepl); got != tt.azure {
+			t.Errorf("toAzureLang(%q) = %q, want %q", tt.deepl, got, tt.azure)
+		}
+	}
+}
+
+// newAzureStub serves the translate and languages endpoints of Azure
+// Translator, translating by prefixing the target language.
+func newAzureStub(t *testing.T) *AzureTranslator {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/translate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Ocp-Apim-Subscription-Key") != "azure-key" || r.Header.Get("Ocp-Apim-Subscription-Region") != "westeurope" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"code":401000,"message":"credentials are missing"}}`))
+			return
+		}
+		if r.URL.Query().Get("api-version") != "3.0" {
+			t.Errorf("api-version = %q, want 3.0", r.URL.Query().Get("api-version"))
+		}
+		var body []struct {
+			Text string `json:"Text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body) != 1 {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		response := []map[string]interface{}{{
+			"translations": []map[string]string{{"text": r.URL.Query().Get("to") + ":" + body[0].Text}},
+		}}
+		if r.URL.Query().Get("from") == "" {
+			response[0]["detectedLanguage"] = map[string]interface{}{"language": "pt", "score": 1.0}
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+	mux.HandleFunc("/languages", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"translation":{
+			"de":{"name":"German"},
+			"en":{"name":"English"},
+			"pt":{"name":"Portuguese (Brazil)"},
+			"pt-pt":{"name":"Portuguese (Portugal)"},
+			"zh-Hans":{"name":"Chinese Simplified"}
+		}}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &AzureTranslator{APIKey: "azure-key", Region: "westeurope", BaseURL: server.URL, Client: server.Client()}
+}
+
+func TestAzureTranslate(t *testing.T) {
+	translator := newAzureStub(t)
+
+	result, err := translator.TranslateDetailed("Hello", "", "ZH-HANS")
+	if err != nil {
+		t.Fatalf("TranslateDetailed() error = %v", err)
+	}
+	if result.Text != "zh-Hans:Hello" || result.DetectedSourceLanguage != "PT-BR" || result.BilledCharacters != 5 {
+		t.Errorf("TranslateDetailed() = %+v, want zh-Hans:Hello detected as PT-BR and 5 billed characters", result)
+	}
+
+	translator.APIKey = "wrong"
+	_, err = translator.Translate("Hello", "EN", "DE")
+	var azureErr *AzureError
+	if !errors.As(err, &azureErr) || azureErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Translate() error = %v, want an AzureError with status 401", err)
+	}
+}
+
+func TestAzureLanguagesAcceptMappedTargets(t *testing.T) {
+	languages, err := newAzureStub(t).Languages()
+	if err != nil {
+		t.Fatalf("Languages() error = %v", err)
+	}
+
+	accepted := []string{"DE", "EN", "EN-GB", "EN-US", "PT", "PT-BR", "PT-PT", "ZH", "ZH-HANS"}
+	if err := validateTargetLangs(accepted, languages); err != nil {
+		t.Errorf("validateTargetLangs() error = %v", err)
+	}
+	// zh-Hant isn't listed by the stub
+	if err := validateTargetLangs([]string{"ZH-HANT"}, languages); err == nil {
+		t.Error("validateTargetLangs(ZH-HANT) succeeded, want an error")
+	}
+
+	for i := 1; i < len(languages); i++ {
+		if languages[i-1].Code > languages[i].Code {
+			t.Fatalf("Languages() not sorted: %v", languages)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "go-translate-youtube")
+}
+
+// The values of cache_backend.
+const (
+	cacheBackendDisk   = "disk"
+	cacheBackendMemory = "memory"
+	cacheBackendNone   = "none"
+)
+
+// defaultMemoryCacheSize is how many entries the memory backend keeps when
+// cache_size is unset.
+const defaultMemoryCacheSize = 10000
+
+// newCache builds the cache config.CacheBackend selects for name, which
+// keeps the entries of different users apart on disk. The disk backend
+// caches nothing when config.CacheDir is empty.
+func newCache(config Config, name string) TranslationCache {
+	switch config.CacheBackend {
+	case cacheBackendMemory:
+		return NewMemoryCache(config.CacheSize)
+	case cacheBackendNone:
+		return noopCache{}
+	}
+	if config.CacheDir == "" {
+		return noopCache{}
+	}
+	return DiskCache{Dir: filepath.Join(config.CacheDir, name)}
+}
+
+// translationCacheKey identifies a translation of text. scope, built by
+// cacheScope, holds everything else that shapes it.
+func translationCacheKey(text string, sourceLang string, targetLang string, scope string) string {
+	hash := sha256.New()
+	for _, part := range []string{text, sourceLang, targetLang, scope} {
+		hash.Write([]byte(part))
+		// Separate the parts so ("ab", "c") and ("a", "bc") don't collide
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// cacheScope describes the provider and every option in config that
+// changes what a translation looks like, so changing any of them misses the
+// cache instead of serving translations made with the old settings.
+func cacheScope(config Config) string {
+	scope := struct {
+		Provider      string
+		Options       *TranslateOptions `json:",omitempty"`
+		PreserveEmoji bool
+		MaxChunkChars int
+	}{
+		Provider:      config.Provider,
+		PreserveEmoji: config.PreserveEmoji,
+		MaxChunkChars: config.MaxChunkChars,
+	}
+	if config.Provider == providerDeepl || config.Provider == "" {
+		// The other providers take none of these options
+		options := deeplOptions(config)
+		scope.Provider = providerDeepl
+		scope.Options = &options
+	}
+	data, err := json.Marshal(scope)
+	if err != nil {
+		// Can't happen for these types, and the provider alone still keeps
+		// the caches apart
+		return scope.Provider
+	}
+	return string(data)
+}
+
+// TranslationCache stores finished translations by translationCacheKey.
+// Implementations must be safe for concurrent use.
+type TranslationCache interface {
+	Get(key string) (string, bool)
+	Set(key string, value string)
+}
+
+// noopCache caches nothing, every lookup misses.
+type noopCache struct{}
+
+func (noopCache) Get(key string) (string, bool) { return "", false }
+func (noopCache) Set(key string, value string)  {}
+
+// DiskCache keeps one file per entry under Dir, so translations survive
+// between runs.
+type DiskCache struct {
+	Dir string
+}
+
+func (c DiskCache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key)
+}
+
+func (c DiskCache) Get(key string) (string, bool) {
+	cached, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	return string(cached), true
+}
+
+// Set is best effort, a failed write only costs a later re-translation.
+func (c DiskCache) Set(key string, value string) {
+	writeCacheFile(c.path(key), value)
+}
+
+// MemoryCache keeps the size most recently used entries in memory.
+type MemoryCache struct {
+	size    int
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key   string
+	value string
+}
+
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *MemoryCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*memoryCacheEntry).value, true
+}
+
+// Set stores value and evicts the least recently used entry once the cache
+// holds more than its size.
+func (c *MemoryCache) Set(key string, value string) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*memoryCacheEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&memoryCacheEntry{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+}
+
+// cachedTranslator answers repeated requests from cache instead of billing
+// them again.
+type cachedTranslator struct {
+	Translator
+	cache TranslationCache
+	// scope is the cacheScope of the config the translator was built from
+	scope string
+}
+
+func (t cachedTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	result, err := t.TranslateDetailed(text, sourceLang, targetLang)
+	return result.Text, err
+}
+
+// cachedTranslation is a translation as cachedTranslator stores it. Older
+// versions stored the bare text, which still reads as a translation without
+// a detected language.
+type cachedTranslation struct {
+	Text                   *string `json:"text"`
+	DetectedSourceLanguage string  `json:"detected_source_language,omitempty"`
+}
+
+// decodeCachedTranslation reads an entry written by any version.
+func decodeCachedTranslation(entry string) TranslationResult {
+	var cached cachedTranslation
+	if err := json.Unmarshal([]byte(entry), &cached); err != nil || cached.Text == nil {
+		return TranslationResult{Text: entry}
+	}
+	return TranslationResult{Text: *cached.Text, DetectedSourceLanguage: cached.DetectedSourceLanguage}
+}
+
+// TranslateDetailed reports the source language detected when the text was
+// first translated. Cached texts bill no characters.
+func (t cachedTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.TranslateDetailedCtx(context.Background(), text, sourceLang, targetLang)
+}
+
+func (t cachedTranslator) TranslateDetailedCtx(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	key := translationCacheKey(text, sourceLang, targetLang, t.scope)
+	if cached, ok := t.cache.Get(key); ok {
+		runMetrics.cacheHits.Add(1)
+		return decodeCachedTranslation(cached), nil
+	}
+	runMetrics.cacheMisses.Add(1)
+
+	translated, err := translateWithDetectionCtx(ctx, t.Translator, text, sourceLang, targetLang)
+	if err != nil {
+		return TranslationResult{}, err
+	}
+
+	entry, err := json.Marshal(cachedTranslation{Text: &translated.Text, DetectedSourceLanguage: translated.DetectedSourceLanguage})
+	if err == nil {
+		t.cache.Set(key, string(entry))
+	}
+
+	return translated, nil
+}
+
+// writeCacheFile writes through a temporary file so concurrent readers never
+// see a partially written entry.
+func writeCacheFile(path string, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCacheScopeSeparatesOptions(t *testing.T) {
+	yes, no := true, false
+	base := Config{Provider: providerDeepl, MaxChunkChars: 5000}
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+	}{
+		{"provider", func(c *Config) { c.Provider = providerGoogle }},
+		{"formality", func(c *Config) { c.Formality = "more" }},
+		{"glossary", func(c *Config) { c.GlossaryID = "g1" }},
+		{"tag handling", func(c *Config) { c.TagHandling = "xml" }},
+		{"outline detection", func(c *Config) { c.TagHandling = "xml"; c.OutlineDetection = &no }},
+		{"ignore tags", func(c *Config) { c.IgnoreTags = []string{"x"} }},
+		{"preserve formatting", func(c *Config) { c.PreserveFormatting = true }},
+		{"split sentences", func(c *Config) { c.SplitSentences = "0" }},
+		{"preserve emoji", func(c *Config) { c.PreserveEmoji = true }},
+		{"chunk size", func(c *Config) { c.MaxChunkChars = 100 }},
+	}
+	seen := map[string]string{cacheScope(base): "base"}
+	for _, tt := range tests {
+		config := base
+		tt.change(&config)
+		scope := cacheScope(config)
+		if other, ok := seen[scope]; ok {
+			t.Errorf("%s: same cache scope as %s", tt.name, other)
+		}
+		seen[scope] = tt.name
+	}
+
+	// An explicit outline_detection of true still differs from leaving it out
+	withOutline := base
+	withOutline.TagHandling = "xml"
+	withOutline.OutlineDetection = &yes
+	plainXML := base
+	plainXML.TagHandling = "xml"
+	if cacheScope(withOutline) == cacheScope(plainXML) {
+		t.Error("outline_detection true and unset share a cache scope")
+	}
+
+	// The default provider is DeepL
+	unnamed := base
+	unnamed.Provider = ""
+	if cacheScope(unnamed) != cacheScope(base) {
+		t.Error("empty provider and deepl have different cache scopes")
+	}
+	// DeepL options don't split the caches of other providers
+	google, formalGoogle := base, base
+	google.Provider, formalGoogle.Provider = providerGoogle, providerGoogle
+	formalGoogle.Formality = "more"
+	if cacheScope(google) != cacheScope(formalGoogle) {
+		t.Error("formality changed the cache scope of google")
+	}
+}
+
+func TestTranslationCacheKey(t *testing.T) {
+	keys := map[string]bool{}
+	for _, parts := range [][4]string{
+		{"ab", "c", "DE", ""},
+		{"a", "bc", "DE", ""},
+		{"ab", "c", "FR", ""},
+		{"ab", "c", "DE", "scope"},
+	} {
+		key := translationCacheKey(parts[0], parts[1], parts[2], parts[3])
+		if keys[key] {
+			t.Errorf("translationCacheKey%q collides", parts)
+		}
+		keys[key] = true
+	}
+}
+
+func TestCachedTranslator(t *testing.T) {
+	calls := 0
+	inner := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		calls++
+		return targetLang + ":" + text, nil
+	}}
+	cache := NewMemoryCache(10)
+	formal := cachedTranslator{Translator: inner, cache: cache, scope: cacheScope(Config{Formality: "more"})}
+	informal := cachedTranslator{Translator: inner, cache: cache, scope: cacheScope(Config{Formality: "less"})}
+
+	for i := 0; i < 3; i++ {
+		if got, err := formal.Translate("Hi", "", "DE"); err != nil || got != "DE:Hi" {
+			t.Fatalf("Translate() = %q, %v", got, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("provider called %d times for a repeated text, want 1", calls)
+	}
+	if _, err := informal.Translate("Hi", "", "DE"); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("other formality was served from the cache")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	cache := DiskCache{Dir: t.TempDir()}
+	key := translationCacheKey("Hello", "", "DE", "")
+
+	if _, ok := cache.Get(key); ok {
+		t.Fatal("Get() hit on an empty cache")
+	}
+	cache.Set(key, "Hallo")
+	if got, ok := cache.Get(key); !ok || got != "Hallo" {
+		t.Errorf("Get() = %q, %v, want Hallo", got, ok)
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCache(2)
+	cache.Set("a", "1")
+	cache.Set("b", "2")
+	cache.Get("a")
+	cache.Set("c", "3")
+
+	tests := []struct {
+		key    string
+		wantOK bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"c", true},
+	}
+	for _, tt := range tests {
+		if _, ok := cache.Get(tt.key); ok != tt.wantOK {
+			t.Errorf("Get(%q) ok = %v, want %v", tt.key, ok, tt.wantOK)
+		}
+	}
+
+	if _, ok := NewMemoryCache(0).Get("a"); ok {
+		t.Error("zero sized cache holds entries")
+	}
+}
+
+func TestMemoryCacheConcurrent(t *testing.T) {
+	cache := NewMemoryCache(16)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprint(i % 20)
+			cache.Set(key, key)
+			cache.Get(key)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCachedTranslatorHitAndMiss(t *testing.T) {
+	var calls atomic.Int64
+	translator := cachedTranslator{Translator: countingTranslator(&calls), cache: DiskCache{Dir: t.TempDir()}}
+
+	tests := []struct {
+		name       string
+		text       string
+		targetLang string
+		wantCalls  int64
+	}{
+		{"miss", "Hello", "DE", 1},
+		{"hit", "Hello", "DE", 1},
+		{"other target language", "Hello", "FR", 2},
+		{"other text", "Bye", "DE", 3},
+	}
+	for _, tt := range tests {
+		got, err := translator.Translate(tt.text, "", tt.targetLang)
+		if err != nil || got != tt.targetLang+":"+tt.text {
+			t.Errorf("%s: Translate() = %q, %v", tt.name, got, err)
+		}
+		if calls.Load() != tt.wantCalls {
+			t.Errorf("%s: provider called %d times, want %d", tt.name, calls.Load(), tt.wantCalls)
+		}
+	}
+}
+
+func TestTranslateNoCache(t *testing.T) {
+	recorder := useAPIStub(t)
+	config := testConfig()
+	config.CacheDir = t.TempDir()
+
+	tests := []struct {
+		args         []string
+		wantRequests int
+	}{
+		{nil, 4},
+		{nil, 0},
+		{[]string{"-no-cache"}, 4},
+	}
+	for _, tt := range tests {
+		recorder.requests = nil
+		a, _, _ := newTestApp(config)
+		if err := a.runTranslate(tt.args); err != nil {
+			t.Fatalf("translate %v error = %v", tt.args, err)
+		}
+		if len(recorder.requests) != tt.wantRequests {
+			t.Errorf("translate %v sent %d translate requests, want %d", tt.args, len(recorder.requests), tt.wantRequests)
+		}
+	}
+}
+
+func TestNewCache(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		backend  string
+		cacheDir string
+		want     TranslationCache
+	}{
+		{cacheBackendDisk, dir, DiskCache{Dir: filepath.Join(dir, "deepl")}},
+		{"", dir, DiskCache{Dir: filepath.Join(dir, "deepl")}},
+		{cacheBackendDisk, "", noopCache{}},
+		{cacheBackendNone, dir, noopCache{}},
+		{cacheBackendMemory, "", NewMemoryCache(5)},
+	}
+	for _, tt := range tests {
+		config := Config{CacheBackend: tt.backend, CacheDir: tt.cacheDir, CacheSize: 5}
+		if got := newCache(config, "deepl"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("newCache(%q, %q) = %#v, want %#v", tt.backend, tt.cacheDir, got, tt.want)
+		}
+	}
+
+	config := testConfig()
+	config.CacheBackend = "redis"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "cache_backend") {
+		t.Errorf("Validate() error = %v, want the unknown cache_backend reported", err)
+	}
+}
+
+func TestTranslateMemoryCache(t *testing.T) {
+	recorder := useAPIStub(t)
+	config := testConfig()
+	config.CacheDir = t.TempDir()
+	config.CacheBackend = cacheBackendMemory
+	config.CacheSize = 10
+
+	a, _, _ := newTestApp(config)
+	translator, err := a.newTranslator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := translator.Translate("Hello", "", "DE"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(recorder.requests) != 1 {
+		t.Errorf("sent %d translate requests, want the second one served from memory", len(recorder.requests))
+	}
+	if entries, _ := os.ReadDir(config.CacheDir); len(entries) != 0 {
+		t.Errorf("memory backend wrote %d entries to cache_dir", len(entries))
+	}
+}
+
+func TestDecodeCachedTranslation(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  TranslationResult
+	}{
+		{`{"text":"Hallo","detected_source_language":"EN"}`, TranslationResult{Text: "Hallo", DetectedSourceLanguage: "EN"}},
+		{`{"text":""}`, TranslationResult{}},
+		// Entries of older versions hold the bare text
+		{"Hallo Welt", TranslationResult{Text: "Hallo Welt"}},
+		{`{"a":1}`, TranslationResult{Text: `{"a":1}`}},
+	}
+	for _, tt := range tests {
+		if got := decodeCachedTranslation(tt.entry); got != tt.want {
+			t.Errorf("decodeCachedTranslation(%q) = %+v, want %+v", tt.entry, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+type CaptionTrack struct {
+	ID        string `json:"id"`
+	Language  string `json:"language"`
+	Name      string `json:"name"`
+	TrackKind string `json:"track_kind"`
+}
+
+// fetchCaptions lists the caption tracks of a video. Listing works with an
+// API key, downloading a track needs OAuth.
+func fetchCaptions(videoID string, apiKey string) ([]CaptionTrack, error) {
+	return fetchCaptionsCtx(context.Background(), videoID, apiKey)
+}
+
+func fetchCaptionsCtx(ctx context.Context, videoID string, apiKey string) ([]CaptionTrack, error) {
+	query := url.Values{}
+	query.Set("videoId", videoID)
+	query.Set("key", apiKey)
+	query.Set("part", "snippet")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", youtubeAPIBaseURL+"/captions?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list captions: %w", newYouTubeError(resp))
+	}
+
+	var response struct {
+		Items []struct {
+			ID      string `json:"id"`
+			Snippet struct {
+				Language  string `json:"language"`
+				Name      string `json:"name"`
+				TrackKind string `json:"trackKind"`
+			} `json:"snippet"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	tracks := make([]CaptionTrack, 0, len(response.Items))
+	for _, item := range response.Items {
+		tracks = append(tracks, CaptionTrack{
+			ID:        item.ID,
+			Language:  item.Snippet.Language,
+			Name:      item.Snippet.Name,
+			TrackKind: item.Snippet.TrackKind,
+		})
+	}
+
+	return tracks, nil
+}
+
+// downloadCaption downloads a caption track as SRT and parses it into cues.
+// token must be an OAuth access token, YouTube refuses API keys here.
+func downloadCaption(captionID string, token string) ([]SubtitleCue, error) {
+	return downloadCaptionCtx(context.Background(), captionID, token)
+}
+
+func downloadCaptionCtx(ctx context.Context, captionID string, token string) ([]SubtitleCue, error) {
+	if err := checkOAuthToken(token); err != nil {
+		return nil, fmt.Errorf("downloading captions: %w", err)
+	}
+
+	endpoint := youtubeAPIBaseURL + "/captions/" + url.PathEscape(captionID) + "?tfmt=srt"
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download caption %s: %w", captionID, newYouTubeError(resp))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSRT(string(body))
+}
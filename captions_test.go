@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const sampleSRT = "1\r\n00:00:01,000 --> 00:00:02,500\r\nHello there\r\n\r\n" +
+	"2\r\n00:00:03,000 --> 00:00:05,000\r\nTwo\r\nlines\r\n"
+
+func TestParseSRT(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []SubtitleCue
+		wantErr bool
+	}{
+		{
+			name:    "youtube payload",
+			content: sampleSRT,
+			want: []SubtitleCue{
+				{Start: time.Second, End: 2500 * time.Millisecond, Text: "Hello there"},
+				{Start: 3 * time.Second, End: 5 * time.Second, Text: "Two\nlines"},
+			},
+		},
+		{
+			name:    "byte order mark and no identifiers",
+			content: "\ufeff00:00:00.000 --> 00:00:01.000\nHi\n",
+			want:    []SubtitleCue{{End: time.Second, Text: "Hi"}},
+		},
+		{name: "empty", content: ""},
+		{name: "broken timing", content: "1\n00:00:01 --> 00:00:02\nHi\n", wantErr: true},
+		{name: "ends before it starts", content: "00:00:02,000 --> 00:00:01,000\nHi\n", wantErr: true},
+		{name: "identifier at the end", content: "1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSRT(tt.content)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseSRT() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseSRT() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFetchCaptions(t *testing.T) {
+	useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/youtube/v3/captions" || r.URL.Query().Get("videoId") != "dQw4w9WgXcQ" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"items":[{"id":"cap1","snippet":{"language":"en","name":"","trackKind":"asr"}}]}`))
+	}))
+
+	tracks, err := fetchCaptions("dQw4w9WgXcQ", "youtube-key")
+	if err != nil {
+		t.Fatalf("fetchCaptions() error = %v", err)
+	}
+	if want := []CaptionTrack{{ID: "cap1", Language: "en", TrackKind: "asr"}}; !reflect.DeepEqual(tracks, want) {
+		t.Errorf("fetchCaptions() = %+v, want %+v", tracks, want)
+	}
+}
+
+func TestDownloadCaption(t *testing.T) {
+	useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ya29.token" || r.URL.Query().Get("tfmt") != "srt" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(sampleSRT))
+	}))
+
+	cues, err := downloadCaption("cap1", "ya29.token")
+	if err != nil || len(cues) != 2 {
+		t.Fatalf("downloadCaption() = %+v, %v, want two cues", cues, err)
+	}
+
+	translated, err := translateCues(cues, fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		return targetLang + ":" + text, nil
+	}}, "", "DE")
+	if err != nil || translated[1].Text != "DE:Two\nlines" || translated[1].Start != cues[1].Start {
+		t.Errorf("translateCues() = %+v, %v, want the texts translated and the timings kept", translated, err)
+	}
+
+	if _, err := downloadCaption("cap1", googleAPIKeyPrefix+"SyApiKey"); !errors.Is(err, errOAuthRequired) {
+		t.Errorf("downloadCaption() with an API key error = %v, want errOAuthRequired", err)
+	}
+}
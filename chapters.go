@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// chapterPattern matches a chapter marker line such as "01:23 Setup" or
+// "1:02:03 - Wrap up". The groups are the timestamp with the separator
+// following it, and the label.
+var chapterPattern = regexp.MustCompile(`^(\s*(?:\d{1,2}:)?\d{1,2}:\d{2}(?:\s*[-–—:|]\s*|\s+))(\S.*)$`)
+
+// parseChapter splits a chapter line into the part that has to survive
+// translation exactly, the timestamp, and the label to translate.
+func parseChapter(line string) (timestamp string, label string, ok bool) {
+	match := chapterPattern.FindStringSubmatch(line)
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
+
+// chapterBlock is a run of consecutive lines that either all are chapter
+// markers or all aren't.
+type chapterBlock struct {
+	lines    []string
+	chapters bool
+}
+
+func splitChapterBlocks(text string) []chapterBlock {
+	var blocks []chapterBlock
+	for _, line := range strings.Split(text, "\n") {
+		_, _, isChapter := parseChapter(line)
+		if len(blocks) == 0 || blocks[len(blocks)-1].chapters != isChapter {
+			blocks = append(blocks, chapterBlock{chapters: isChapter})
+		}
+		last := &blocks[len(blocks)-1]
+		last.lines = append(last.lines, line)
+	}
+	return blocks
+}
+
+// chapterTranslator translates only the labels of chapter lines and keeps
+// their timestamps exactly, which YouTube needs to recognize the chapters.
+// Text around the chapters is translated as before.
+type chapterTranslator struct {
+	Translator
+}
+
+func (t chapterTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	result, err := t.TranslateDetailed(text, sourceLang, targetLang)
+	return result.Text, err
+}
+
+func (t chapterTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.TranslateDetailedCtx(context.Background(), text, sourceLang, targetLang)
+}
+
+func (t chapterTranslator) TranslateDetailedCtx(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	blocks := splitChapterBlocks(text)
+	if len(blocks) == 1 && !blocks[0].chapters {
+		return translateWithDetectionCtx(ctx, t.Translator, text, sourceLang, targetLang)
+	}
+
+	var result TranslationResult
+	translated := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		var blockResult TranslationResult
+		var err error
+		if block.chapters {
+			blockResult, err = t.translateChapters(ctx, block.lines, sourceLang, targetLang)
+		} else {
+			blockResult, err = t.translateText(ctx, strings.Join(block.lines, "\n"), sourceLang, targetLang)
+		}
+		if err != nil {
+			return TranslationResult{}, err
+		}
+
+		translated = append(translated, blockResult.Text)
+		result.BilledCharacters += blockResult.BilledCharacters
+		if result.DetectedSourceLanguage == "" {
+			result.DetectedSourceLanguage = blockResult.DetectedSourceLanguage
+		}
+	}
+
+	result.Text = strings.Join(translated, "\n")
+	return result, nil
+}
+
+// translateText translates the text between chapter blocks. The blank lines
+// separating it from the chapters are kept as they were, translation tends
+// to drop surrounding whitespace.
+func (t chapterTranslator) translateText(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	core := strings.TrimSpace(text)
+	if core == "" {
+		return TranslationResult{Text: text}, nil
+	}
+	start := strings.Index(text, core)
+
+	result, err := translateWithDetectionCtx(ctx, t.Translator, core, sourceLang, targetLang)
+	if err != nil {
+		return TranslationResult{}, err
+	}
+	result.Text = text[:start] + result.Text + text[start+len(core):]
+	return result, nil
+}
+
+// translateChapters sends all labels of a block in one request, one label
+// per line. If the translation doesn't come back with one line per label,
+// each label is translated on its own instead.
+func (t chapterTranslator) translateChapters(ctx context.Context, lines []string, sourceLang string, targetLang string) (TranslationResult, error) {
+	timestamps := make([]string, len(lines))
+	labels := make([]string, len(lines))
+	for i, line := range lines {
+		timestamps[i], labels[i], _ = parseChapter(line)
+	}
+
+	result, err := translateWithDetectionCtx(ctx, t.Translator, strings.Join(labels, "\n"), sourceLang, targetLang)
+	if err != nil {
+		return TranslationResult{}, err
+	}
+	translatedLabels := strings.Split(strings.TrimRight(result.Text, "\n"), "\n")
+
+	if len(translatedLabels) != len(labels) {
+		translatedLabels = make([]string, len(labels))
+		for i, label := range labels {
+			labelResult, err := translateWithDetectionCtx(ctx, t.Translator, label, sourceLang, targetLang)
+			if err != nil {
+				return TranslationResult{}, err
+			}
+			translatedLabels[i] = labelResult.Text
+			result.BilledCharacters += labelResult.BilledCharacters
+		}
+	}
+
+	rebuilt := make([]string, len(lines))
+	for i := range lines {
+		rebuilt[i] = timestamps[i] + strings.TrimSpace(translatedLabels[i])
+	}
+	result.Text = strings.Join(rebuilt, "\n")
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseChapter(t *testing.T) {
+	tests := []struct {
+		line          string
+		wantTimestamp string
+		wantLabel     string
+		wantOK        bool
+	}{
+		{"00:00 Intro", "00:00 ", "Intro", true},
+		{"1:02:03 - Wrap up", "1:02:03 - ", "Wrap up", true},
+		{"  12:34 | Setup", "  12:34 | ", "Setup", true},
+		{"01:23", "", "", false},
+		{"Call me at 10:30 tomorrow", "", "", false},
+		{"123:45 Too long", "", "", false},
+	}
+	for _, tt := range tests {
+		timestamp, label, ok := parseChapter(tt.line)
+		if timestamp != tt.wantTimestamp || label != tt.wantLabel || ok != tt.wantOK {
+			t.Errorf("parseChapter(%q) = %q, %q, %v, want %q, %q, %v", tt.line, timestamp, label, ok, tt.wantTimestamp, tt.wantLabel, tt.wantOK)
+		}
+	}
+}
+
+func TestChapterTranslator(t *testing.T) {
+	var sent []string
+	inner := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		sent = append(sent, text)
+		return strings.ToUpper(text), nil
+	}}
+	description := "My video about bread.\n\n00:00 Intro\n01:23 - Setup\n1:02:03 Wrap up\n\nThanks for watching"
+
+	got, err := chapterTranslator{Translator: inner}.Translate(description, "", "DE")
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	want := "MY VIDEO ABOUT BREAD.\n\n00:00 INTRO\n01:23 - SETUP\n1:02:03 WRAP UP\n\nTHANKS FOR WATCHING"
+	if got != want {
+		t.Errorf("Translate() = %q, want %q", got, want)
+	}
+	for _, text := range sent {
+		if strings.Contains(text, "00:00") || strings.Contains(text, "01:23") {
+			t.Errorf("sent a timestamp to the translator: %q", text)
+		}
+	}
+	if len(sent) != 3 {
+		t.Errorf("sent %d requests, want the labels in one: %q", len(sent), sent)
+	}
+}
+
+func TestChapterTranslatorLineMismatch(t *testing.T) {
+	// A provider that merges lines makes every label go out on its own
+	inner := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		return strings.ReplaceAll(strings.ToUpper(text), "\n", " "), nil
+	}}
+
+	got, err := chapterTranslator{Translator: inner}.Translate("00:00 Intro\n01:23 Setup", "", "DE")
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if want := "00:00 INTRO\n01:23 SETUP"; got != want {
+		t.Errorf("Translate() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const defaultMaxChunkChars = 5000
+
+// chunkBoundary matches the whitespace after a sentence end, including
+// closing quotes and brackets, or any run of whitespace containing a line
+// break.
+//
+// The standard library has no Unicode sentence segmentation and
+// golang.org/x/text implements none either, so a regexp finds the places
+// where a long text can be cut. Where it is wrong the cut is only worse:
+//   - Decimals such as "1.5" and periods inside URLs have no whitespace
+//     after them and never split. A URL ending a sentence is cut after its
+//     period, which keeps the URL whole.
+//   - Abbreviations are only known from the list below. "etc." ending a
+//     sentence doesn't split, one missing from the list such as "incl."
+//     splits in the middle of a sentence.
+//   - Chinese and Japanese full stops usually have no whitespace after
+//     them, so such text only splits at line breaks, and splitOversized
+//     cuts anything longer at the character limit.
+var chunkBoundary = regexp.MustCompile(`[.!?。！？]+["'”’»)\]]*\s+|\s*\n\s*`)
+
+// Periods after these words, compared in lower case, don't end a sentence.
+var abbreviations = map[string]bool{
+	"e.g": true, "i.e": true, "etc": true, "vs": true, "cf": true, "approx": true,
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "st": true, "jr": true, "sr": true,
+	"no": true, "nr": true, "vol": true, "fig": true, "ca": true,
+	"z.b": true, "d.h": true, "bzw": true, "usw": true, "ggf": true,
+}
+
+// endsWithAbbreviation reports whether the period ending text belongs to an
+// abbreviation or an initial like the "J." in "J. R. R. Tolkien".
+func endsWithAbbreviation(text string) bool {
+	if !strings.HasSuffix(text, ".") || strings.HasSuffix(text, "..") {
+		return false
+	}
+	word := strings.TrimSuffix(text, ".")
+	if i := strings.LastIndexFunc(word, unicode.IsSpace); i >= 0 {
+		word = word[i+1:]
+	}
+	word = strings.TrimLeft(word, "(\"'“‘«")
+	if utf8.RuneCountInString(word) == 1 {
+		r, _ := utf8.DecodeRuneInString(word)
+		return unicode.IsUpper(r)
+	}
+	return abbreviations[strings.ToLower(word)]
+}
+
+type textChunk struct {
+	Text string
+	// Separator is the whitespace following Text. It is never translated, so
+	// line breaks between chunks survive the round trip unchanged.
+	Separator string
+}
+
+// splitUnits cuts text after every sentence end and line break. Periods of
+// abbreviations and initials aren't sentence ends.
+func splitUnits(text string) []textChunk {
+	var units []textChunk
+	start := 0
+	for _, m := range chunkBoundary.FindAllStringIndex(text, -1) {
+		end := m[0] + len(strings.TrimRightFunc(text[m[0]:m[1]], unicode.IsSpace))
+		if !strings.Contains(text[end:m[1]], "\n") && endsWithAbbreviation(text[start:end]) {
+			continue
+		}
+		units = append(units, textChunk{Text: text[start:end], Separator: text[end:m[1]]})
+		start = m[1]
+	}
+	if start < len(text) {
+		units = append(units, textChunk{Text: text[start:]})
+	}
+	return units
+}
+
+// splitOversized breaks a unit that alone exceeds maxChars at word
+// boundaries, or mid-word when there is no whitespace to cut at.
+func splitOversized(unit textChunk, maxChars int) []textChunk {
+	var pieces []textChunk
+	text := unit.Text
+	for utf8.RuneCountInString(text) > maxChars {
+		cut := runeOffset(text, maxChars)
+		if i := strings.LastIndexFunc(text[:cut], unicode.IsSpace); i > 0 {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			pieces = append(pieces, textChunk{Text: text[:i], Separator: text[i : i+size]})
+			text = text[i+size:]
+			continue
+		}
+		pieces = append(pieces, textChunk{Text: text[:cut]})
+		text = text[cut:]
+	}
+	return append(pieces, textChunk{Text: text, Separator: unit.Separator})
+}
+
+// runeOffset returns the byte offset of the n-th rune in s.
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
+
+// splitIntoChunks packs sentences and paragraphs of text into chunks of at
+// most maxChars characters. Concatenating every chunk's Text and Separator
+// yields the original text.
+func splitIntoChunks(text string, maxChars int) []textChunk {
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return []textChunk{{Text: text}}
+	}
+
+	var chunks []textChunk
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	if leading := text[:len(text)-len(trimmed)]; leading != "" {
+		chunks = append(chunks, textChunk{Separator: leading})
+	}
+
+	var current textChunk
+	size := 0
+	for _, unit := range splitUnits(trimmed) {
+		for _, piece := range splitOversized(unit, maxChars) {
+			pieceSize := utf8.RuneCountInString(piece.Text)
+			separatorSize := utf8.RuneCountInString(current.Separator)
+			if current.Text != "" && size+separatorSize+pieceSize > maxChars {
+				chunks = append(chunks, current)
+				current, size = textChunk{}, 0
+			}
+			if current.Text != "" {
+				current.Text += current.Separator
+				size += separatorSize
+			}
+			current.Text += piece.Text
+			current.Separator = piece.Separator
+			size += pieceSize
+		}
+	}
+
+	return append(chunks, current)
+}
+
+// chunkedTranslator splits texts longer than maxChars before handing them to
+// the wrapped Translator and reassembles the translated chunks.
+type chunkedTranslator struct {
+	Translator
+	maxChars int
+}
+
+func (t chunkedTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	result, err := t.TranslateDetailed(text, sourceLang, targetLang)
+	return result.Text, err
+}
+
+// TranslateDetailed reports the source language detected for the first
+// chunk that has one, and the characters billed for all chunks.
+func (t chunkedTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.TranslateDetailedCtx(context.Background(), text, sourceLang, targetLang)
+}
+
+func (t chunkedTranslator) TranslateDetailedCtx(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	chunks := splitIntoChunks(text, t.maxChars)
+	if len(chunks) == 1 {
+		return translateWithDetectionCtx(ctx, t.Translator, text, sourceLang, targetLang)
+	}
+
+	var b strings.Builder
+	var detected string
+	billed := 0
+	for _, chunk := range chunks {
+		if strings.TrimSpace(chunk.Text) != "" {
+			translated, err := translateWithDetectionCtx(ctx, t.Translator, chunk.Text, sourceLang, targetLang)
+			if err != nil {
+				return TranslationResult{}, err
+			}
+			b.WriteString(translated.Text)
+			billed += translated.BilledCharacters
+			if detected == "" {
+				detected = translated.DetectedSourceLanguage
+			}
+		} else {
+			b.WriteString(chunk.Text)
+		}
+		b.WriteString(chunk.Separator)
+	}
+
+	return TranslationResult{Text: b.String(), DetectedSourceLanguage: detected, BilledCharacters: billed}, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// longDescription builds paragraphs of sentences, separated by blank lines,
+// of about chars characters in total.
+func longDescription(chars int) string {
+	const sentence = "This sentence belongs to a long description. "
+	var paragraphs []string
+	for size := 0; size < chars; {
+		paragraph := strings.TrimSpace(strings.Repeat(sentence, 12))
+		paragraphs = append(paragraphs, paragraph)
+		size += len(paragraph) + 2
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func TestSplitIntoChunks(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		maxChars   int
+		wantChunks int
+	}{
+		{"short text", "Hello. World.", 100, 1},
+		{"no limit", longDescription(6000), 0, 1},
+		{"sentences", "One. Two. Three.", 9, 2},
+		{"long description", longDescription(6000), 5000, 2},
+		{"leading whitespace", "\n\nOne. Two.", 5, 3},
+		{"word without breaks", strings.Repeat("x", 25), 10, 3},
+		{"CJK without whitespace", strings.Repeat("一二三。", 10), 12, 4},
+	}
+	for _, tt := range tests {
+		chunks := splitIntoChunks(tt.text, tt.maxChars)
+		if len(chunks) != tt.wantChunks {
+			t.Errorf("%s: %d chunks, want %d: %q", tt.name, len(chunks), tt.wantChunks, chunks)
+		}
+
+		var b strings.Builder
+		for _, chunk := range chunks {
+			if tt.maxChars > 0 && utf8.RuneCountInString(chunk.Text) > tt.maxChars {
+				t.Errorf("%s: chunk of %d characters over the limit of %d", tt.name, utf8.RuneCountInString(chunk.Text), tt.maxChars)
+			}
+			b.WriteString(chunk.Text + chunk.Separator)
+		}
+		if b.String() != tt.text {
+			t.Errorf("%s: chunks join to %q, want the original text", tt.name, b.String())
+		}
+	}
+}
+
+func TestChunkedTranslatorKeepsParagraphs(t *testing.T) {
+	text := longDescription(6000)
+	var calls int
+	inner := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		calls++
+		if utf8.RuneCountInString(text) > defaultMaxChunkChars {
+			t.Errorf("sent %d characters, over the limit of %d", utf8.RuneCountInString(text), defaultMaxChunkChars)
+		}
+		return strings.ToUpper(text), nil
+	}}
+
+	translated, err := chunkedTranslator{Translator: inner, maxChars: defaultMaxChunkChars}.Translate(text, "", "DE")
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if calls < 2 {
+		t.Errorf("translated a %d character text in %d request", len(text), calls)
+	}
+	if got, want := strings.Count(translated, "\n\n"), strings.Count(text, "\n\n"); got != want {
+		t.Errorf("translation has %d paragraph breaks, want %d", got, want)
+	}
+	if translated != strings.ToUpper(text) {
+		t.Error("translation isn't the chunks reassembled in order")
+	}
+}
+
+func TestSplitUnits(t *testing.T) {
+	tests := []struct {
+		text string
+		want []textChunk
+	}{
+		{"One. Two!  Three?", []textChunk{{"One.", " "}, {"Two!", "  "}, {"Three?", ""}}},
+		{"He said \"Stop.\" Then left.", []textChunk{{"He said \"Stop.\"", " "}, {"Then left.", ""}}},
+		{"Use e.g. flour. Done.", []textChunk{{"Use e.g. flour.", " "}, {"Done.", ""}}},
+		{"By J. R. R. Tolkien. Read it.", []textChunk{{"By J. R. R. Tolkien.", " "}, {"Read it.", ""}}},
+		{"Links\n\nhttps://example.com", []textChunk{{"Links", "\n\n"}, {"https://example.com", ""}}},
+		{"Version 1.5 is out", []textChunk{{"Version 1.5 is out", ""}}},
+		{"終わり。次", []textChunk{{"終わり。次", ""}}},
+		{"It costs 3.50 euros. Pay now.", []textChunk{{"It costs 3.50 euros.", " "}, {"Pay now.", ""}}},
+		{"See https://example.com. Then go.", []textChunk{{"See https://example.com.", " "}, {"Then go.", ""}}},
+		{"Open example.com/a.b now.", []textChunk{{"Open example.com/a.b now.", ""}}},
+		{"終わり。 次。", []textChunk{{"終わり。", " "}, {"次。", ""}}},
+		// What a regexp gets wrong, see chunkBoundary
+		{"Bring tools etc. Then go.", []textChunk{{"Bring tools etc. Then go.", ""}}},
+		{"Price incl. tax. Done.", []textChunk{{"Price incl.", " "}, {"tax.", " "}, {"Done.", ""}}},
+		{"一。二。三。", []textChunk{{"一。二。三。", ""}}},
+	}
+	for _, tt := range tests {
+		if got := splitUnits(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitUnits(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitIntoChunksKeepsSentences(t *testing.T) {
+	sentences := []string{
+		"The first sentence is here.",
+		"Dr. Smith wrote the second one!",
+		"Is this the third?",
+		"The fourth ends the paragraph.",
+	}
+	text := sentences[0] + "  " + sentences[1] + "\t" + sentences[2] + " " + sentences[3] + "\n\n" + sentences[0]
+
+	for _, maxChars := range []int{35, 60, 80} {
+		chunks := splitIntoChunks(text, maxChars)
+		var b strings.Builder
+		for _, chunk := range chunks {
+			b.WriteString(chunk.Text + chunk.Separator)
+			if chunk.Text == "" {
+				continue
+			}
+			// Every chunk is made of whole sentences
+			rest := chunk.Text
+			for rest != "" {
+				found := false
+				for _, sentence := range sentences {
+					if strings.HasPrefix(rest, sentence) {
+						rest = strings.TrimLeftFunc(strings.TrimPrefix(rest, sentence), unicode.IsSpace)
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("max %d: chunk %q splits a sentence", maxChars, chunk.Text)
+					break
+				}
+			}
+		}
+		if b.String() != text {
+			t.Errorf("max %d: chunks join to %q, want the original whitespace kept", maxChars, b.String())
+		}
+	}
+}
@@ -0,0 +1,870 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const commandUsage = `Usage: go-translate-youtube <command> [flags]
+
+Commands:
+  translate   translate the video metadata (default)
+  languages   list the languages the translation provider supports
+  usage       show the DeepL character quota
+  check       check that the translation and YouTube API keys work
+  localize    translate a video and write the translations to it as localizations
+  subtitles   translate a local SRT subtitle file
+
+Run "go-translate-youtube <command> -h" for the flags of a command. Every
+command accepts -config PATH to read another config file than config.json.
+`
+
+// app holds what every subcommand needs. Output only goes to stdout and
+// stderr, so the commands can run embedded or in tests. newTranslator is a
+// field so tests can swap the real backend for a fake.
+type app struct {
+	config        Config
+	stdin         io.Reader
+	stdout        io.Writer
+	stderr        io.Writer
+	newTranslator func(Config) (Translator, error)
+}
+
+// run dispatches args to a subcommand. Without a command name, or when args
+// start with a flag, the translate command runs.
+func (a *app) run(args []string) error {
+	command := "translate"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "translate":
+		return a.runTranslate(args)
+	case "languages":
+		return a.runLanguages(args)
+	case "usage":
+		return a.runUsage(args)
+	case "check":
+		return a.runCheck(args)
+	case "localize":
+		return a.runLocalize(args)
+	case "subtitles":
+		return a.runSubtitles(args)
+	case "help":
+		fmt.Fprint(a.stdout, commandUsage)
+		return nil
+	default:
+		fmt.Fprint(a.stderr, commandUsage)
+		return &configError{fmt.Errorf("unknown command %q", command)}
+	}
+}
+
+type commonOptions struct {
+	verbose bool
+	record  string
+	replay  string
+}
+
+func newFlagSet(name string, common *commonOptions) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.BoolVar(&common.verbose, "v", false, "enable debug logging, shortcut for log_level \"debug\"")
+	flags.StringVar(&common.record, "record", "", "save every API response into this directory for -replay")
+	flags.StringVar(&common.replay, "replay", "", "answer API requests from the responses -record saved in this directory, without network access")
+	return flags
+}
+
+// setupLogging installs the default logger once the flags are known, along
+// with the HTTP client for -record or -replay.
+func (a *app) setupLogging(common commonOptions) error {
+	level, err := parseLogLevel(a.config.LogLevel)
+	if err != nil {
+		return &configError{fmt.Errorf("invalid log_level: %w", err)}
+	}
+	if common.verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(newLogger(a.stderr, level, configSecrets(a.config)))
+
+	client, err := fixtureDoer(httpClient, common.record, common.replay)
+	if err != nil {
+		return &configError{err}
+	}
+	httpClient = client
+	if common.record != "" || common.replay != "" {
+		// Without cached videos no request is conditional, so a recording
+		// replays the same whatever the ETag cache held at either end
+		youtubeCache = noopCache{}
+	}
+	return nil
+}
+
+func (a *app) runLanguages(args []string) error {
+	var common commonOptions
+	var asJSON bool
+	flags := newFlagSet("languages", &common)
+	flags.BoolVar(&asJSON, "json", false, "print the languages as a JSON list of objects with \"code\" and \"name\"")
+	flags.Parse(args)
+
+	if err := a.setupLogging(common); err != nil {
+		return err
+	}
+	if err := a.config.ValidateProvider(); err != nil {
+		return &configError{err}
+	}
+
+	translator, err := a.newTranslator(a.config)
+	if err != nil {
+		return err
+	}
+
+	languages, err := translator.Languages()
+	if err != nil {
+		return fmt.Errorf("failed to fetch supported languages: %w", err)
+	}
+
+	if asJSON {
+		return encodeJSON(a.stdout, languages)
+	}
+	for _, lang := range languages {
+		fmt.Fprintf(a.stdout, "%s\t%s\n", lang.Code, lang.Name)
+	}
+
+	return nil
+}
+
+func (a *app) runUsage(args []string) error {
+	var common commonOptions
+	flags := newFlagSet("usage", &common)
+	flags.Parse(args)
+
+	if err := a.setupLogging(common); err != nil {
+		return err
+	}
+	if a.config.Provider != providerDeepl {
+		return &configError{fmt.Errorf("usage is only available for the %s provider", providerDeepl)}
+	}
+	if err := a.config.ValidateProvider(); err != nil {
+		return &configError{err}
+	}
+
+	usage, err := getDeeplUsage(a.config.DeeplApiKey)
+	if err != nil {
+		return fmt.Errorf("failed to fetch DeepL usage: %w", err)
+	}
+
+	fmt.Fprintf(a.stdout, "%d of %d characters used\n", usage.CharacterCount, usage.CharacterLimit)
+	return nil
+}
+
+// checkVideoID is a public video that is always available, "Me at the zoo".
+const checkVideoID = "jNQXAC9IVRw"
+
+// runCheck makes the cheapest call each API allows and reports whether the
+// key works. It never spends translated characters.
+func (a *app) runCheck(args []string) error {
+	var common commonOptions
+	flags := newFlagSet("check", &common)
+	flags.Parse(args)
+
+	if err := a.setupLogging(common); err != nil {
+		return err
+	}
+
+	var errs []error
+	report := func(name string, err error) {
+		if err != nil {
+			fmt.Fprintf(a.stdout, "%s: FAILED: %v\n", name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		fmt.Fprintf(a.stdout, "%s: OK\n", name)
+	}
+
+	// DeepL has a free usage endpoint, the other providers only the languages
+	// list
+	if err := a.config.ValidateProvider(); err != nil {
+		report(a.config.Provider, err)
+	} else if a.config.Provider == providerDeepl {
+		_, err := getDeeplUsage(a.config.DeeplApiKey)
+		report("DeepL", err)
+	} else {
+		translator, err := a.newTranslator(a.config)
+		if err == nil {
+			_, err = translator.Languages()
+		}
+		report(a.config.Provider, err)
+	}
+
+	if a.config.YoutubeApiKey == "" {
+		report("YouTube", errors.New("missing required config value youtube_api_key (YOUTUBE_API_KEY)"))
+	} else {
+		_, err := fetchYouTubeVideoInfo(checkVideoID, a.config.YoutubeApiKey)
+		report("YouTube", err)
+	}
+
+	return errors.Join(errs...)
+}
+
+type localizeOptions struct {
+	commonOptions
+	force    bool
+	truncate bool
+	video    string
+	playlist string
+	target   string
+}
+
+// runLocalize translates a single video, or the title and description of a
+// playlist, and writes the translations back as localizations. Languages that
+// already exist are left alone unless -force is given.
+func (a *app) runLocalize(args []string) error {
+	var opts localizeOptions
+	flags := newFlagSet("localize", &opts.commonOptions)
+	flags.BoolVar(&opts.force, "force", false, "overwrite localizations the video already has")
+	flags.StringVar(&opts.video, "video", "", "video ID or URL, overrides youtube_video_id")
+	flags.BoolVar(&opts.truncate, "truncate", false, "cut translated descriptions longer than YouTube's 5000 characters at a word boundary")
+	flags.StringVar(&opts.playlist, "playlist", "", "playlist ID, localizes the title and description of the playlist itself instead of a video")
+	flags.StringVar(&opts.target, "target", "", "comma separated target languages, overrides target_langs")
+	flags.Parse(args)
+
+	if err := a.setupLogging(opts.commonOptions); err != nil {
+		return err
+	}
+	if opts.video != "" && opts.playlist != "" {
+		return &configError{errors.New("-video and -playlist can't be used together")}
+	}
+
+	config := a.config
+	config.PlaylistId = opts.playlist
+	config.ChannelId = ""
+	config.Jobs = nil
+	if opts.video != "" {
+		id, err := extractVideoID(opts.video)
+		if err != nil {
+			return &configError{err}
+		}
+		config.YoutubeVideoId = id
+	}
+	if opts.target != "" {
+		targets, err := parseTargetLangs(opts.target)
+		if err != nil {
+			return &configError{fmt.Errorf("invalid -target: %w", err)}
+		}
+		config.TargetLangs = targets
+	}
+	if err := config.Validate(); err != nil {
+		return &configError{fmt.Errorf("invalid config: %w", err)}
+	}
+	if len(config.TargetLangs) == 0 {
+		return &configError{errors.New("missing target languages, set target_langs or -target")}
+	}
+
+	// Fail before spending any characters when the video can't be written
+	token, err := youtubeAccessToken(config)
+	if err != nil {
+		return &configError{err}
+	}
+
+	translator, err := a.newTranslator(config)
+	if err != nil {
+		return err
+	}
+
+	videoOpts := translateVideoOptions{
+		SourceLang:  config.SourceLang,
+		Concurrency: config.MaxConcurrency,
+		Truncate:    opts.truncate,
+	}
+	if config.PlaylistId != "" {
+		return a.localizePlaylist(config, translator, token, videoOpts, opts.force)
+	}
+
+	translated, err := localizeVideo(context.Background(), config, globalAPISettings(), translator, config.TargetLangs, videoOpts)
+	if err != nil {
+		return err
+	}
+
+	added, err := uploadLocalizations(context.Background(), translated.VideoID, token, translated.localizations(), allVideoFields, opts.force)
+	if err != nil {
+		return fmt.Errorf("failed to upload localizations: %w", err)
+	}
+
+	if len(added) == 0 {
+		fmt.Fprintf(a.stdout, "%s already has every target language, use -force to overwrite them\n", translated.VideoID)
+		return nil
+	}
+	fmt.Fprintf(a.stdout, "Wrote localizations for %s: %s\n", translated.VideoID, strings.Join(added, ", "))
+	return nil
+}
+
+// localizePlaylist is runLocalize for the metadata of a playlist.
+func (a *app) localizePlaylist(config Config, translator Translator, token string, videoOpts translateVideoOptions, force bool) error {
+	playlist, err := fetchPlaylistInfo(config.PlaylistId, config.YoutubeApiKey)
+	if err != nil {
+		return err
+	}
+
+	translated, err := translatePlaylist(context.Background(), playlist, translator, config.TargetLangs, videoOpts)
+	if err != nil {
+		return fmt.Errorf("failed to translate playlist: %w", err)
+	}
+
+	merged, added := mergeLocalizations(playlist.Localizations, translated.localizations(), force)
+	if len(added) == 0 {
+		fmt.Fprintf(a.stdout, "%s already has every target language, use -force to overwrite them\n", playlist.ID)
+		return nil
+	}
+	if err := updatePlaylistLocalizations(playlist, token, merged); err != nil {
+		return fmt.Errorf("failed to upload localizations: %w", err)
+	}
+
+	fmt.Fprintf(a.stdout, "Wrote localizations for %s: %s\n", playlist.ID, strings.Join(added, ", "))
+	return nil
+}
+
+// runSubtitles translates a local SRT file into a single target language.
+func (a *app) runSubtitles(args []string) error {
+	var common commonOptions
+	var input, output, target string
+	var opts srtFileOptions
+	flags := newFlagSet("subtitles", &common)
+	flags.StringVar(&input, "input", "", "SRT file to translate")
+	flags.StringVar(&output, "output", "", "where to write the translated SRT file, stdout when empty")
+	flags.StringVar(&target, "target", "", "target language")
+	flags.BoolVar(&opts.RestorePunctuation, "restore-punctuation", false, "add sentence breaks at pauses first, for auto-generated captions without punctuation")
+	flags.Parse(args)
+
+	if err := a.setupLogging(common); err != nil {
+		return err
+	}
+	if input == "" {
+		return &configError{errors.New("missing -input")}
+	}
+	targets, err := parseTargetLangs(target)
+	if err != nil || len(targets) != 1 {
+		return &configError{fmt.Errorf("-target needs a single language code, got %q", target)}
+	}
+	if err := a.config.ValidateProvider(); err != nil {
+		return &configError{err}
+	}
+
+	translator, err := a.newTranslator(a.config)
+	if err != nil {
+		return err
+	}
+
+	opts.SourceLang = a.config.SourceLang
+	return translateSRTFile(a.stdout, input, output, targets[0], translator, opts)
+}
+
+type translateOptions struct {
+	commonOptions
+	dryRun          bool
+	noCache         bool
+	checkQuota      bool
+	upload          bool
+	force           bool
+	progress        bool
+	interactive     bool
+	skipUnsupported bool
+	resume          bool
+	allLanguages    bool
+	includeVariants bool
+	statePath       string
+	serveMetrics    string
+	verify          bool
+	titleSource     string
+	descSource      string
+	compare         string
+	channel         string
+	since           string
+	truncate        bool
+	titleMax        int
+	video           string
+	playlist        string
+	target          string
+	fields          string
+	outputDir       string
+}
+
+func (a *app) runTranslate(args []string) error {
+	var opts translateOptions
+	flags := newFlagSet("translate", &opts.commonOptions)
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "fetch the YouTube metadata and show what would be translated without calling DeepL")
+	flags.BoolVar(&opts.noCache, "no-cache", false, "always call the translation API instead of reusing cached translations, and fetch the video metadata in full")
+	flags.BoolVar(&opts.checkQuota, "check-quota", false, "abort when the estimated cost exceeds the remaining DeepL quota")
+	flags.BoolVar(&opts.progress, "progress", false, "print a line to stderr for every video finished in a language")
+	flags.BoolVar(&opts.upload, "upload", false, "write the translations to the video as YouTube localizations, needs OAuth")
+	flags.BoolVar(&opts.force, "force", false, "translate videos the state file records as done again, overwrite localizations the video already has with -upload, or existing files in -output-dir")
+	flags.BoolVar(&opts.interactive, "interactive", false, "ask for the video and the target languages when the config has none")
+	flags.BoolVar(&opts.skipUnsupported, "skip-unsupported", false, "drop target languages the provider doesn't support with a warning instead of failing")
+	flags.BoolVar(&opts.resume, "resume", false, "deprecated, every run with a state file skips what it records as done")
+	flags.StringVar(&opts.statePath, "state", "", "record finished videos and languages in this file, overrides state_path")
+	flags.BoolVar(&opts.allLanguages, "all-languages", false, "translate into every target language the provider supports, instead of target_langs")
+	flags.BoolVar(&opts.includeVariants, "include-variants", false, "with -all-languages, keep regional variants such as EN-GB and EN-US apart instead of one EN")
+	flags.StringVar(&opts.serveMetrics, "serve-metrics", "", "serve Prometheus metrics on this address, e.g. :9090, while translating")
+	flags.BoolVar(&opts.verify, "verify", false, "translate every translation back and add its similarity to the original to the output, doubles the cost")
+	flags.StringVar(&opts.titleSource, "title-source", "", "source language of the title, overrides source_lang for it")
+	flags.StringVar(&opts.descSource, "desc-source", "", "source language of the description, overrides source_lang for it")
+	flags.StringVar(&opts.compare, "compare", "", "comma separated providers, e.g. deepl,google, to print their translations side by side instead of writing output")
+	flags.BoolVar(&opts.truncate, "truncate", false, "cut translated descriptions longer than YouTube's 5000 characters at a word boundary")
+	flags.IntVar(&opts.titleMax, "title-max", 0, "ask for a shorter translation of titles longer than this many characters, or truncate them, e.g. 70 for search results")
+	flags.StringVar(&opts.fields, "fields", "title,description", "comma separated video fields to translate, the others keep their original text, or with -upload what YouTube already has")
+	flags.StringVar(&opts.outputDir, "output-dir", "", "write one JSON file per target language into this directory instead of output_path")
+	flags.StringVar(&opts.video, "video", "", "video ID or URL, overrides youtube_video_id")
+	flags.StringVar(&opts.playlist, "playlist", "", "playlist ID, translates every video in it instead of a single video")
+	flags.StringVar(&opts.channel, "channel", "", "channel ID, translates every upload of the channel instead of a single video")
+	flags.StringVar(&opts.since, "since", "", "with a channel, only translate uploads published at or after this RFC 3339 time")
+	flags.StringVar(&opts.target, "target", "", "comma separated target languages, overrides target_langs")
+	flags.Parse(args)
+
+	if err := a.setupLogging(opts.commonOptions); err != nil {
+		return err
+	}
+
+	config := a.config
+	if opts.channel != "" {
+		config.ChannelId = opts.channel
+		config.PlaylistId = ""
+	} else if opts.video != "" || opts.playlist != "" {
+		config.ChannelId = ""
+	}
+	var since time.Time
+	if opts.since != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, opts.since)
+		if err != nil {
+			return &configError{fmt.Errorf("invalid -since, expected an RFC 3339 time like 2024-01-02T15:04:05Z: %w", err)}
+		}
+		if config.ChannelId == "" {
+			return &configError{errors.New("-since only works with a channel")}
+		}
+	}
+	if opts.video != "" {
+		id, err := extractVideoID(opts.video)
+		if err != nil {
+			return &configError{err}
+		}
+		config.YoutubeVideoId = id
+	}
+	if opts.playlist != "" {
+		config.PlaylistId = opts.playlist
+	}
+	if opts.target != "" {
+		targets, err := parseTargetLangs(opts.target)
+		if err != nil {
+			return &configError{fmt.Errorf("invalid -target: %w", err)}
+		}
+		config.TargetLangs = targets
+	}
+	if opts.allLanguages && opts.target != "" {
+		return &configError{errors.New("-all-languages and -target can't be used together")}
+	}
+	if opts.interactive {
+		if err := promptMissing(a.stdin, a.stderr, &config); err != nil {
+			return &configError{err}
+		}
+	}
+	fields, err := parseVideoFields(opts.fields)
+	if err != nil {
+		return &configError{fmt.Errorf("invalid -fields: %w", err)}
+	}
+	for name, value := range map[string]*string{"-title-source": &opts.titleSource, "-desc-source": &opts.descSource} {
+		*value = strings.ToUpper(strings.TrimSpace(*value))
+		if *value != "" && !languageCodePattern.MatchString(*value) {
+			return &configError{fmt.Errorf("invalid %s: %q is not a language code", name, *value)}
+		}
+	}
+	if opts.noCache {
+		config.CacheBackend = cacheBackendNone
+		youtubeCache = noopCache{}
+	}
+	var compareProviders []string
+	if opts.compare != "" {
+		compareProviders, err = parseProviders(opts.compare)
+		if err != nil {
+			return &configError{fmt.Errorf("invalid -compare: %w", err)}
+		}
+		// Each provider is checked on its own, one without keys must not
+		// stop the others. Validate only needs one that works.
+		config.Provider = compareProviders[0]
+		for _, provider := range compareProviders {
+			candidate := config
+			candidate.Provider = provider
+			if candidate.ValidateProvider() == nil {
+				config.Provider = provider
+				break
+			}
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return &configError{fmt.Errorf("invalid config: %w", err)}
+	}
+	if opts.statePath != "" {
+		config.StatePath = opts.statePath
+	}
+	if opts.resume {
+		slog.Warn("-resume is deprecated and does nothing, the state file is read on every run")
+	}
+	// A channel translates like a playlist of its uploads
+	manyVideos := config.PlaylistId != "" || config.ChannelId != ""
+	if opts.upload && (manyVideos || (len(config.Jobs) > 0 && opts.video == "")) {
+		return &configError{errors.New("-upload only works for a single video")}
+	}
+
+	if compareProviders != nil {
+		return a.runCompare(config, compareProviders, fields)
+	}
+
+	if opts.serveMetrics != "" {
+		stop, err := serveMetrics(opts.serveMetrics)
+		if err != nil {
+			return &configError{err}
+		}
+		defer stop()
+	}
+
+	translator, err := a.newTranslator(config)
+	if err != nil {
+		return err
+	}
+
+	apiKey := config.DeeplApiKey
+	slog.Debug("loaded config", "provider", config.Provider, "deepl_api_key", redactKey(apiKey))
+
+	// Listing the languages is free, a dry run only needs them for
+	// -all-languages
+	var deepLLanguages []DeeplLanguage
+	if !opts.dryRun || opts.allLanguages {
+		deepLLanguages, err = translator.Languages()
+		if err != nil {
+			return fmt.Errorf("failed to fetch supported languages: %w", err)
+		}
+
+		for _, lang := range deepLLanguages {
+			slog.Debug("supported language", "code", lang.Code, "name", lang.Name)
+		}
+	}
+	if opts.allLanguages {
+		config.TargetLangs = allTargetLangs(deepLLanguages, opts.includeVariants, config.SourceLang)
+		slog.Info("translating into every supported language", "languages", strings.Join(config.TargetLangs, ", "))
+	}
+
+	// A dry run must not spend any translation requests
+	var usage *DeeplUsage
+	if !opts.dryRun {
+		if config.Provider == providerDeepl {
+			deeplUsage, err := getDeeplUsage(apiKey)
+			if err != nil {
+				return fmt.Errorf("failed to fetch DeepL usage: %w", err)
+			}
+			slog.Info("DeepL usage", "character_count", deeplUsage.CharacterCount, "character_limit", deeplUsage.CharacterLimit)
+			usage = &deeplUsage
+		}
+
+		if opts.skipUnsupported {
+			config.TargetLangs = skipUnsupportedLangs(config.TargetLangs, deepLLanguages)
+			jobs := make([]BatchJob, 0, len(config.Jobs))
+			for _, job := range config.Jobs {
+				if len(job.TargetLangs) > 0 {
+					job.TargetLangs = skipUnsupportedLangs(job.TargetLangs, deepLLanguages)
+					if len(job.TargetLangs) == 0 {
+						// Without targets the job would fall back to target_langs
+						slog.Warn("skipping job without supported target languages", "video", job.Video)
+						continue
+					}
+				}
+				jobs = append(jobs, job)
+			}
+			config.Jobs = jobs
+		}
+
+		// Collapsed variants, e.g. EN, aren't in the list but still valid
+		if !opts.allLanguages {
+			if err := validateTargetLangs(config.TargetLangs, deepLLanguages); err != nil {
+				return &configError{err}
+			}
+		}
+		for i, job := range config.Jobs {
+			if err := validateTargetLangs(job.TargetLangs, deepLLanguages); err != nil {
+				return &configError{fmt.Errorf("jobs[%d]: %w", i, err)}
+			}
+		}
+	}
+
+	// The state carries over between runs, so videos that didn't change
+	// aren't translated and billed again
+	var state *runState
+	if config.StatePath != "" && !opts.dryRun {
+		state, err = loadRunState(config.StatePath, stateScope(config, opts, fields))
+		if err != nil {
+			return fmt.Errorf("failed to load state: %w", err)
+		}
+		state.force = opts.force
+	}
+
+	ctx, cancel := batchContext(config)
+	defer cancel()
+
+	// The jobs list only runs when no video, playlist or channel was picked on
+	// the command line
+	if len(config.Jobs) > 0 && opts.video == "" && opts.playlist == "" && opts.channel == "" {
+		return a.runJobs(ctx, config, translator, opts, fields, state)
+	}
+
+	videos, err := a.fetchVideos(config, since)
+	if err != nil {
+		return err
+	}
+	for _, video := range videos {
+		slog.Info("fetched video", "id", video.ID, "title", video.Title)
+		slog.Debug("video details", "description", video.Description, "tags", strings.Join(video.Tags, ", "))
+	}
+
+	texts := videoTexts(videos, fields)
+	cost := estimateCost(texts, config.TargetLangs)
+
+	if opts.dryRun {
+		for _, video := range videos {
+			fmt.Fprintln(a.stdout, "Video:", video.ID)
+			fmt.Fprintln(a.stdout, "Title:", video.Title)
+			fmt.Fprintln(a.stdout, "Description:", video.Description)
+			fmt.Fprintln(a.stdout, "Tags:", strings.Join(video.Tags, ", "))
+		}
+		fmt.Fprintf(a.stdout, "Dry run: %d characters per target language, %d in total for %d target languages\n",
+			countCharacters(texts), cost, len(config.TargetLangs))
+		return nil
+	}
+
+	slog.Info("estimated cost", "characters", cost)
+	if opts.checkQuota && usage != nil && usage.CharacterLimit > 0 {
+		if remaining := usage.CharacterLimit - usage.CharacterCount; int64(cost) > remaining {
+			return fmt.Errorf("translation needs about %d characters but only %d remain in the DeepL quota", cost, remaining)
+		}
+	}
+
+	if len(config.TargetLangs) == 0 {
+		return nil
+	}
+
+	videoOpts := translateVideoOptions{
+		SourceLang:            config.SourceLang,
+		TitleSourceLang:       opts.titleSource,
+		DescriptionSourceLang: opts.descSource,
+		Concurrency:           config.MaxConcurrency,
+		Fields:                fields,
+		State:                 state,
+		Verify:                opts.verify,
+		Truncate:              opts.truncate,
+		TitleMax:              opts.titleMax,
+	}
+	if opts.titleMax > 0 {
+		videoOpts.ShortTitles = newTitleHintTranslator(config, opts.titleMax)
+	}
+	if opts.progress {
+		videoOpts.Progress = newProgressPrinter(a.stderr, len(videos)*len(config.TargetLangs)).report
+	}
+
+	// A playlist always produces a list, even when it holds a single video
+	if manyVideos && isJSONLPath(config.OutputPath) && opts.outputDir == "" {
+		return a.streamPlaylist(ctx, config, videos, translator, videoOpts)
+	}
+	if manyVideos {
+		translated, err := translateVideos(ctx, videos, translator, config.TargetLangs, videoOpts)
+		logSummary(billedCharacters(translated))
+		if writeErr := a.writeOutput(config, opts, translated, true); writeErr != nil {
+			return fmt.Errorf("failed to write output: %w", writeErr)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to translate playlist: %w", err)
+		}
+		return nil
+	}
+
+	translated, err := translateVideo(ctx, videos[0], translator, config.TargetLangs, videoOpts)
+	logSummary(translated.BilledCharacters)
+	if errors.Is(err, context.DeadlineExceeded) {
+		// Keep what was done before the deadline
+		if writeErr := a.writeOutput(config, opts, []TranslatedVideo{translated}, false); writeErr != nil {
+			return fmt.Errorf("failed to write output: %w", writeErr)
+		}
+		return fmt.Errorf("batch deadline exceeded, the output only holds the finished languages: %w", err)
+	}
+	if err != nil {
+		// Keep the languages and fields that did work, nothing is uploaded
+		if len(translated.Translations) > 0 {
+			if writeErr := a.writeOutput(config, opts, []TranslatedVideo{translated}, false); writeErr != nil {
+				return fmt.Errorf("failed to write output: %w", writeErr)
+			}
+		}
+		return fmt.Errorf("failed to translate video: %w", err)
+	}
+
+	if opts.upload {
+		token, err := youtubeAccessToken(config)
+		if err != nil {
+			return &configError{err}
+		}
+		added, err := uploadLocalizations(ctx, translated.VideoID, token, translated.localizations(), fields, opts.force)
+		if err != nil {
+			return fmt.Errorf("failed to upload localizations: %w", err)
+		}
+		slog.Info("uploaded localizations", "video", translated.VideoID, "languages", strings.Join(added, ", "))
+	}
+
+	if err := a.writeOutput(config, opts, []TranslatedVideo{translated}, false); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return nil
+}
+
+// allTargetLangs returns the codes of languages, minus sourceLang. Unless
+// includeVariants is set, regional variants collapse into their base
+// language, e.g. EN-GB and EN-US become a single EN.
+func allTargetLangs(languages []DeeplLanguage, includeVariants bool, sourceLang string) []string {
+	seen := make(map[string]bool, len(languages))
+	var targets []string
+	for _, lang := range languages {
+		code := strings.ToUpper(lang.Code)
+		if !includeVariants {
+			code = baseLanguage(code)
+		}
+		if seen[code] || (sourceLang != "" && strings.EqualFold(baseLanguage(code), baseLanguage(sourceLang))) {
+			continue
+		}
+		seen[code] = true
+		targets = append(targets, code)
+	}
+	return targets
+}
+
+// skipUnsupportedLangs keeps the targets the provider supports and warns
+// about the rest.
+func skipUnsupportedLangs(targetLangs []string, languages []DeeplLanguage) []string {
+	supported, unsupported := filterTargetLangs(targetLangs, languages)
+	if len(unsupported) > 0 {
+		slog.Warn("skipping unsupported target languages", "languages", strings.Join(unsupported, ", "))
+	}
+	return supported
+}
+
+// batchContext limits a translate run to batch_deadline_seconds, when set.
+func batchContext(config Config) (context.Context, context.CancelFunc) {
+	if config.BatchDeadlineSeconds <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), time.Duration(config.BatchDeadlineSeconds)*time.Second)
+}
+
+// writeOutput writes results to the -output-dir when one was given, and to
+// output_path otherwise.
+func (a *app) writeOutput(config Config, opts translateOptions, results []TranslatedVideo, asList bool) error {
+	if opts.outputDir != "" {
+		return writeLanguageFiles(opts.outputDir, results, opts.force)
+	}
+	return writeResults(a.stdout, config.OutputPath, results, asList)
+}
+
+var languageCodePattern = regexp.MustCompile(`^[A-Z]{2,3}(-[A-Z0-9]{2,4})?$`)
+
+// parseTargetLangs splits a comma separated list of language codes, e.g.
+// " de , FR ,ja " becomes DE, FR and JA. Whether the provider supports them
+// is checked later against its language list.
+func parseTargetLangs(list string) ([]string, error) {
+	var targets []string
+	for i, token := range strings.Split(list, ",") {
+		code := strings.ToUpper(strings.TrimSpace(token))
+		if code == "" {
+			return nil, fmt.Errorf("entry %d is empty", i+1)
+		}
+		if !languageCodePattern.MatchString(code) {
+			return nil, fmt.Errorf("%q is not a language code", strings.TrimSpace(token))
+		}
+		targets = append(targets, code)
+	}
+	return targets, nil
+}
+
+// streamPlaylist writes each translated video of the playlist to the JSON
+// Lines output as soon as it is done.
+func (a *app) streamPlaylist(ctx context.Context, config Config, videos []YouTubeVideo, translator Translator, videoOpts translateVideoOptions) error {
+	file, err := os.Create(config.OutputPath)
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	defer file.Close()
+
+	results := make(chan TranslatedVideo)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- streamVideos(ctx, videos, translator, config.TargetLangs, videoOpts, results)
+	}()
+
+	// Count the billed characters on the way to the writer
+	counted := make(chan TranslatedVideo)
+	billed := 0
+	go func() {
+		defer close(counted)
+		for result := range results {
+			billed += result.BilledCharacters
+			counted <- result
+		}
+	}()
+
+	writeErr := writeJSONL(file, counted)
+	err = <-errc
+	logSummary(billed)
+	if writeErr == nil {
+		writeErr = file.Close()
+	}
+	if writeErr != nil {
+		return fmt.Errorf("failed to write output: %w", writeErr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to translate playlist: %w", err)
+	}
+	return nil
+}
+
+// fetchVideos fetches the configured playlist, or the single configured video.
+func (a *app) fetchVideos(config Config, since time.Time) ([]YouTubeVideo, error) {
+	if config.ChannelId != "" {
+		videoIDs, err := fetchChannelUploadsSince(context.Background(), config.ChannelId, config.YoutubeApiKey, 0, since)
+		if err != nil {
+			return nil, err
+		}
+		if len(videoIDs) == 0 {
+			slog.Warn("channel has no uploads to translate", "channel", config.ChannelId)
+			return nil, nil
+		}
+		return fetchYouTubeVideos(videoIDs, config.YoutubeApiKey)
+	}
+	if config.PlaylistId == "" {
+		video, err := fetchYouTubeVideoInfo(config.YoutubeVideoId, config.YoutubeApiKey)
+		if err != nil {
+			return nil, err
+		}
+		return []YouTubeVideo{video}, nil
+	}
+
+	videoIDs, err := fetchPlaylistVideoIDs(config.PlaylistId, config.YoutubeApiKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(videoIDs) == 0 {
+		slog.Warn("playlist is empty", "playlist", config.PlaylistId)
+		return nil, nil
+	}
+
+	return fetchYouTubeVideos(videoIDs, config.YoutubeApiKey)
+}
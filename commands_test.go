@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// testConfig is a valid config for a single video, quiet unless a test
+// fails.
+func testConfig() Config {
+	return Config{
+		Provider:       providerDeepl,
+		DeeplApiKey:    "deepl-key",
+		YoutubeApiKey:  "youtube-key",
+		YoutubeVideoId: "dQw4w9WgXcQ",
+		TargetLangs:    []string{"DE", "FR"},
+		LogLevel:       "error",
+	}
+}
+
+// newTestApp runs commands with config and the real translators. Their
+// output ends up in stdout and stderr.
+func newTestApp(config Config) (a *app, stdout *bytes.Buffer, stderr *bytes.Buffer) {
+	stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+	return &app{config: config, stdout: stdout, stderr: stderr, newTranslator: newTranslator}, stdout, stderr
+}
+
+// useAPIStub serves the video of testConfig and the DeepL endpoints a
+// translate run needs, and returns the recorder of the translate requests.
+func useAPIStub(t *testing.T) *deeplRecorder {
+	return useAPIStubWithUsage(t, DeeplUsage{CharacterCount: 1000, CharacterLimit: 500000})
+}
+
+// useAPIStubWithUsage is useAPIStub reporting usage as the DeepL quota.
+func useAPIStubWithUsage(t *testing.T, usage DeeplUsage) *deeplRecorder {
+	recorder := &deeplRecorder{}
+	mux := http.NewServeMux()
+	mux.Handle("/youtube/v3/videos", youtubeVideoHandler("dQw4w9WgXcQ", "Title"))
+	mux.Handle("/v2/translate", recorder)
+	mux.HandleFunc("/v2/languages", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"language":"DE","name":"German"},{"language":"EN-GB","name":"English (British)"},` +
+			`{"language":"EN-US","name":"English (American)"},{"language":"FR","name":"French"}]`))
+	})
+	mux.HandleFunc("/v2/usage", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(usage)
+	})
+	useStubServer(t, mux)
+	return recorder
+}
+
+func TestTranslateDryRun(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/youtube/v3/videos", youtubeVideoHandler("dQw4w9WgXcQ", "Title"))
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run called DeepL: %s", r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	useStubServer(t, mux)
+
+	a, stdout, _ := newTestApp(testConfig())
+	if err := a.run([]string{"translate", "-dry-run"}); err != nil {
+		t.Fatalf("translate -dry-run error = %v", err)
+	}
+
+	for _, want := range []string{
+		"Title: Title\n",
+		"Description: Description\n",
+		"Dry run: 16 characters per target language, 32 in total for 2 target languages\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output = %q, want it to contain %q", stdout.String(), want)
+		}
+	}
+}
+
+func TestTranslateRejectsUnknownTarget(t *testing.T) {
+	recorder := useAPIStub(t)
+
+	a, _, _ := newTestApp(testConfig())
+	err := a.runTranslate([]string{"-target", "EN-GB,EN-XX"})
+	if exitCode(err) != exitConfig || !strings.Contains(err.Error(), "EN-XX") {
+		t.Errorf("translate -target EN-XX error = %v, want a config error naming EN-XX", err)
+	}
+	if len(recorder.requests) != 0 {
+		t.Errorf("sent %d translate requests for an invalid target", len(recorder.requests))
+	}
+}
+
+func TestTranslateSkipUnsupported(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		jobs        []BatchJob
+		wantErr     bool
+		wantTargets map[string]bool
+	}{
+		{
+			name:    "fails without the flag",
+			args:    []string{"-target", "DE,XX,FR"},
+			wantErr: true,
+		},
+		{
+			name:        "drops unsupported targets",
+			args:        []string{"-skip-unsupported", "-target", "DE,XX,FR"},
+			wantTargets: map[string]bool{"DE": true, "FR": true},
+		},
+		{
+			name: "drops jobs left without targets",
+			args: []string{"-skip-unsupported"},
+			jobs: []BatchJob{
+				{Video: "dQw4w9WgXcQ", TargetLangs: []string{"XX"}},
+				{Video: "dQw4w9WgXcQ", TargetLangs: []string{"FR", "YY"}},
+			},
+			wantTargets: map[string]bool{"FR": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := useAPIStub(t)
+			config := testConfig()
+			config.Jobs = tt.jobs
+			a, _, _ := newTestApp(config)
+
+			err := a.runTranslate(tt.args)
+			if tt.wantErr {
+				if exitCode(err) != exitConfig || !strings.Contains(err.Error(), "XX") {
+					t.Errorf("translate error = %v, want a config error naming XX", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("translate error = %v", err)
+			}
+			sent := map[string]bool{}
+			for _, request := range recorder.requests {
+				sent[request["target_lang"].(string)] = true
+			}
+			if !reflect.DeepEqual(sent, tt.wantTargets) {
+				t.Errorf("translated into %v, want %v", sent, tt.wantTargets)
+			}
+		})
+	}
+}
+
+func TestTranslateNeverPrintsKeys(t *testing.T) {
+	useAPIStub(t)
+	config := testConfig()
+	config.DeeplApiKey = "deepl-key"
+	config.YoutubeApiKey = googleAPIKeyPrefix + "SyYoutubeSecretKey"
+
+	a, stdout, stderr := newTestApp(config)
+	if err := a.runTranslate([]string{"-v"}); err != nil {
+		t.Fatalf("translate -v error = %v", err)
+	}
+	for _, key := range []string{config.DeeplApiKey, config.YoutubeApiKey} {
+		if strings.Contains(stdout.String()+stderr.String(), key) {
+			t.Errorf("output holds the key %q:\n%s%s", key, stdout, stderr)
+		}
+	}
+	if !strings.Contains(stderr.String(), redactKey(config.DeeplApiKey)) {
+		t.Errorf("debug log lacks the redacted DeepL key:\n%s", stderr)
+	}
+}
+
+func TestRunDispatch(t *testing.T) {
+	fake := fakeTranslator{languages: []DeeplLanguage{{Code: "DE", Name: "German"}, {Code: "EN-GB", Name: "English (British)"}}}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantOutput string
+		wantCode   int
+	}{
+		{"languages", []string{"languages"}, "DE\tGerman\nEN-GB\tEnglish (British)\n", 0},
+		{"help", []string{"help"}, commandUsage, 0},
+		{"unknown command", []string{"translat"}, "", exitConfig},
+	}
+	for _, tt := range tests {
+		a, stdout, _ := newTestApp(testConfig())
+		a.newTranslator = func(Config) (Translator, error) { return fake, nil }
+
+		err := a.run(tt.args)
+		if tt.wantCode == 0 && err != nil {
+			t.Errorf("%s: run() error = %v", tt.name, err)
+		}
+		if tt.wantCode != 0 && exitCode(err) != tt.wantCode {
+			t.Errorf("%s: run() error = %v, want exit code %d", tt.name, err, tt.wantCode)
+		}
+		if stdout.String() != tt.wantOutput {
+			t.Errorf("%s: output = %q, want %q", tt.name, stdout.String(), tt.wantOutput)
+		}
+	}
+}
+
+func TestTranslateCheckQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		usage   DeeplUsage
+		wantErr bool
+	}{
+		{"enough left", DeeplUsage{CharacterCount: 100, CharacterLimit: 132}, false},
+		{"over the quota", DeeplUsage{CharacterCount: 100, CharacterLimit: 131}, true},
+		{"unlimited", DeeplUsage{CharacterCount: 100}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := useAPIStubWithUsage(t, tt.usage)
+
+			a, _, _ := newTestApp(testConfig())
+			err := a.runTranslate([]string{"-check-quota"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("translate -check-quota error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && len(recorder.requests) != 0 {
+				t.Errorf("sent %d translate requests over the quota", len(recorder.requests))
+			}
+		})
+	}
+}
+
+func TestRunLanguagesOutput(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "DE\tGerman\nEN-GB\tEnglish (British)\nEN-US\tEnglish (American)\nFR\tFrench\n"},
+		{[]string{"-json"}, `[
+  {
+    "code": "DE",
+    "name": "German"
+  },
+  {
+    "code": "EN-GB",
+    "name": "English (British)"
+  },
+  {
+    "code": "EN-US",
+    "name": "English (American)"
+  },
+  {
+    "code": "FR",
+    "name": "French"
+  }
+]
+`},
+	}
+	for _, tt := range tests {
+		useAPIStub(t)
+		a, stdout, _ := newTestApp(testConfig())
+		if err := a.runLanguages(tt.args); err != nil {
+			t.Fatalf("languages %v error = %v", tt.args, err)
+		}
+		if stdout.String() != tt.want {
+			t.Errorf("languages %v output = %q, want %q", tt.args, stdout.String(), tt.want)
+		}
+	}
+}
+
+func TestRunLanguagesJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		languages []DeeplLanguage
+	}{
+		{"several", []DeeplLanguage{{Code: "DE", Name: "German"}, {Code: "PT-BR", Name: "Portuguese (Brazilian)"}, {Code: "ZH", Name: "中文 \"Chinese\""}}},
+		{"none", []DeeplLanguage{}},
+	}
+	for _, tt := range tests {
+		a, stdout, _ := newTestApp(testConfig())
+		a.newTranslator = func(Config) (Translator, error) { return fakeTranslator{languages: tt.languages}, nil }
+
+		if err := a.runLanguages([]string{"-json"}); err != nil {
+			t.Fatalf("%s: languages -json error = %v", tt.name, err)
+		}
+		var got []DeeplLanguage
+		if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("%s: output %q isn't JSON: %v", tt.name, stdout.String(), err)
+		}
+		if !reflect.DeepEqual(got, tt.languages) {
+			t.Errorf("%s: languages -json = %v, want %v", tt.name, got, tt.languages)
+		}
+	}
+}
+
+func TestParseTargetLangs(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr string
+	}{
+		{" de , FR ,ja ", []string{"DE", "FR", "JA"}, ""},
+		{"en-gb", []string{"EN-GB"}, ""},
+		{"DE,,FR", nil, "entry 2 is empty"},
+		{"", nil, "entry 1 is empty"},
+		{"DE, fr!", nil, `"fr!"`},
+	}
+	for _, tt := range tests {
+		got, err := parseTargetLangs(tt.list)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseTargetLangs(%q) error = %v, want one naming %s", tt.list, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTargetLangs(%q) = %v, %v, want %v", tt.list, got, err, tt.want)
+		}
+	}
+}
+
+func TestTranslateTargetFlag(t *testing.T) {
+	recorder := useAPIStub(t)
+
+	a, _, _ := newTestApp(testConfig())
+	if err := a.runTranslate([]string{"-target", " en-gb , FR "}); err != nil {
+		t.Fatalf("translate -target error = %v", err)
+	}
+	sent := map[string]bool{}
+	for _, request := range recorder.requests {
+		sent[request["target_lang"].(string)] = true
+	}
+	if want := map[string]bool{"EN-GB": true, "FR": true}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("translated into %v, want %v", sent, want)
+	}
+}
+
+func TestCheckKeys(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/usage", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key deepl-key" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"Wrong endpoint"}`))
+			return
+		}
+		w.Write([]byte(`{"character_count":1,"character_limit":500000}`))
+	})
+	mux.HandleFunc("/v2/translate", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("check spent DeepL characters on a translation")
+	})
+	mux.HandleFunc("/youtube/v3/videos", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "youtube-key" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"message":"API key not valid","errors":[{"reason":"forbidden"}]}}`))
+			return
+		}
+		youtubeVideoHandler(checkVideoID, "Me at the zoo").ServeHTTP(w, r)
+	})
+	useStubServer(t, mux)
+
+	tests := []struct {
+		name       string
+		deeplKey   string
+		youtubeKey string
+		wantOutput []string
+		wantCode   int
+	}{
+		{"both valid", "deepl-key", "youtube-key", []string{"DeepL: OK", "YouTube: OK"}, 0},
+		{"youtube forbidden", "deepl-key", "wrong", []string{"DeepL: OK", "YouTube: FAILED: failed to fetch video information: YouTube request failed with status code: 403"}, exitAPI},
+		{"deepl forbidden", "wrong", "youtube-key", []string{"DeepL: FAILED: DeepL request failed with status code: 403", "YouTube: OK"}, exitAPI},
+		{"youtube key missing", "deepl-key", "", []string{"DeepL: OK", "YouTube: FAILED: missing required config value youtube_api_key"}, exitFailure},
+	}
+	for _, tt := range tests {
+		config := testConfig()
+		config.DeeplApiKey, config.YoutubeApiKey = tt.deeplKey, tt.youtubeKey
+		a, stdout, _ := newTestApp(config)
+
+		err := a.runCheck(nil)
+		if tt.wantCode == 0 && err != nil {
+			t.Errorf("%s: check error = %v", tt.name, err)
+		}
+		if tt.wantCode != 0 && exitCode(err) != tt.wantCode {
+			t.Errorf("%s: check error = %v, want exit code %d", tt.name, err, tt.wantCode)
+		}
+		for _, want := range tt.wantOutput {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("%s: output = %q, want it to contain %q", tt.name, stdout.String(), want)
+			}
+		}
+	}
+}
+
+// localizationsStub is a video on YouTube whose localizations are read and
+// replaced through the videos endpoint, as localize does.
+type localizationsStub struct {
+	t    *testing.T
+	mu   sync.Mutex
+	locs map[string]YouTubeLocalization
+	puts int
+}
+
+func (s *localizationsStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == "PUT":
+		if r.Header.Get("Authorization") != "Bearer ya29.token" {
+			s.t.Errorf("PUT with %q, want the OAuth token", r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Localizations map[string]YouTubeLocalization `json:"localizations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			s.t.Errorf("failed to decode request: %v", err)
+		}
+		s.locs = body.Localizations
+		s.puts++
+		w.Write([]byte(`{}`))
+	case r.URL.Query().Get("part") == "localizations":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{"localizations": s.locs}},
+		})
+	default:
+		youtubeVideoHandler("dQw4w9WgXcQ", "Title").ServeHTTP(w, r)
+	}
+}
+
+func TestRunLocalize(t *testing.T) {
+	// de was fixed by hand and must survive unless -force is given
+	video := &localizationsStub{t: t, locs: map[string]YouTubeLocalization{"de": {Title: "Von Hand"}}}
+	mux := http.NewServeMux()
+	mux.Handle("/youtube/v3/videos", video)
+	mux.Handle("/v2/translate", &deeplRecorder{})
+	useStubServer(t, mux)
+
+	config := testConfig()
+	config.OAuthToken = "ya29.token"
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantOutput string
+		wantPuts   int
+		wantDE     string
+	}{
+		{"first run", nil, "Wrote localizations for dQw4w9WgXcQ: fr\n", 1, "Von Hand"},
+		{"again", nil, "dQw4w9WgXcQ already has every target language, use -force to overwrite them\n", 1, "Von Hand"},
+		{"new language", []string{"-target", "DE,JA"}, "Wrote localizations for dQw4w9WgXcQ: ja\n", 2, "Von Hand"},
+		{"force", []string{"-force", "-target", "DE"}, "Wrote localizations for dQw4w9WgXcQ: de\n", 3, "DE:Title"},
+	}
+	for _, tt := range tests {
+		a, stdout, _ := newTestApp(config)
+		if err := a.runLocalize(tt.args); err != nil {
+			t.Fatalf("%s: localize error = %v", tt.name, err)
+		}
+		if stdout.String() != tt.wantOutput {
+			t.Errorf("%s: output = %q, want %q", tt.name, stdout.String(), tt.wantOutput)
+		}
+		if video.puts != tt.wantPuts {
+			t.Errorf("%s: wrote the localizations %d times, want %d", tt.name, video.puts, tt.wantPuts)
+		}
+		if got := video.locs["de"].Title; got != tt.wantDE {
+			t.Errorf("%s: de title = %q, want %q", tt.name, got, tt.wantDE)
+		}
+	}
+	for _, lang := range []string{"fr", "ja"} {
+		if _, ok := video.locs[lang]; !ok {
+			t.Errorf("localizations = %v, want %s kept", video.locs, lang)
+		}
+	}
+}
+
+func TestRunLocalizeNeedsOAuth(t *testing.T) {
+	recorder := useAPIStub(t)
+
+	a, _, _ := newTestApp(testConfig())
+	if err := a.runLocalize(nil); exitCode(err) != exitConfig || !errors.Is(err, errOAuthRequired) {
+		t.Errorf("localize without a token error = %v, want errOAuthRequired as a config error", err)
+	}
+	if len(recorder.requests) != 0 {
+		t.Errorf("sent %d translate requests before failing", len(recorder.requests))
+	}
+}
+
+func TestRunLocalizePlaylist(t *testing.T) {
+	var puts []playlistUpdate
+	recorder := &deeplRecorder{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/youtube/v3/playlists", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			var update playlistUpdate
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+			}
+			puts = append(puts, update)
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Write([]byte(`{"items":[{"id":"PL123","snippet":{"title":"Tutorials","description":"All of them"},` +
+			`"localizations":{"de":{"title":"Von Hand"}}}]}`))
+	})
+	mux.Handle("/v2/translate", recorder)
+	useStubServer(t, mux)
+
+	config := testConfig()
+	config.OAuthToken = "ya29.token"
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantOutput string
+		wantPuts   int
+		wantErr    bool
+	}{
+		{"new language", []string{"-playlist", "PL123"}, "Wrote localizations for PL123: fr\n", 1, false},
+		{"nothing new", []string{"-playlist", "PL123", "-target", "DE"}, "PL123 already has every target language, use -force to overwrite them\n", 1, false},
+		{"with a video", []string{"-playlist", "PL123", "-video", "dQw4w9WgXcQ"}, "", 1, true},
+	}
+	for _, tt := range tests {
+		a, stdout, _ := newTestApp(config)
+		err := a.runLocalize(tt.args)
+		if tt.wantErr {
+			if exitCode(err) != exitConfig {
+				t.Errorf("%s: localize error = %v, want a config error", tt.name, err)
+			}
+		} else if err != nil {
+			t.Fatalf("%s: localize error = %v", tt.name, err)
+		}
+		if stdout.String() != tt.wantOutput {
+			t.Errorf("%s: output = %q, want %q", tt.name, stdout.String(), tt.wantOutput)
+		}
+		if len(puts) != tt.wantPuts {
+			t.Errorf("%s: updated the playlist %d times, want %d", tt.name, len(puts), tt.wantPuts)
+		}
+	}
+
+	update := puts[0]
+	if update.ID != "PL123" || update.Snippet.Title != "Tutorials" || update.Snippet.Description != "All of them" {
+		t.Errorf("update = %+v, want the playlist snippet unchanged", update)
+	}
+	want := map[string]YouTubeLocalization{
+		"de": {Title: "Von Hand"},
+		"fr": {Title: "FR:Tutorials", Description: "FR:All of them"},
+	}
+	if !reflect.DeepEqual(update.Localizations, want) {
+		t.Errorf("localizations = %v, want %v", update.Localizations, want)
+	}
+}
+
+func TestTranslateBatchDeadline(t *testing.T) {
+	useAPIStub(t)
+	var cancelled atomic.Int64
+	slow := blockingTranslator("FR", &cancelled)
+	config := testConfig()
+	config.BatchDeadlineSeconds = 1
+	config.OutputPath = filepath.Join(t.TempDir(), "out.json")
+
+	a, _, _ := newTestApp(config)
+	a.newTranslator = func(Config) (Translator, error) { return slow, nil }
+	err := a.runTranslate(nil)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "batch deadline exceeded") {
+		t.Fatalf("translate error = %v, want the batch deadline exceeded", err)
+	}
+	if cancelled.Load() == 0 {
+		t.Error("the translator never saw the deadline")
+	}
+
+	data, err := os.ReadFile(config.OutputPath)
+	if err != nil {
+		t.Fatalf("no partial output written: %v", err)
+	}
+	var result TranslatedVideo
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Translations["DE"].Title != "DE:Title" || result.Translations["FR"].Title != "" {
+		t.Errorf("output translations = %+v, want only DE", result.Translations)
+	}
+}
+
+func TestAllTargetLangs(t *testing.T) {
+	languages := []DeeplLanguage{{Code: "DE"}, {Code: "EN-GB"}, {Code: "EN-US"}, {Code: "PT-BR"}, {Code: "PT-PT"}, {Code: "fr"}}
+
+	tests := []struct {
+		includeVariants bool
+		sourceLang      string
+		want            []string
+	}{
+		{false, "", []string{"DE", "EN", "PT", "FR"}},
+		{true, "", []string{"DE", "EN-GB", "EN-US", "PT-BR", "PT-PT", "FR"}},
+		{false, "EN", []string{"DE", "PT", "FR"}},
+		{true, "pt", []string{"DE", "EN-GB", "EN-US", "FR"}},
+	}
+	for _, tt := range tests {
+		got := allTargetLangs(languages, tt.includeVariants, tt.sourceLang)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("allTargetLangs(variants %v, source %q) = %v, want %v", tt.includeVariants, tt.sourceLang, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateAllLanguages(t *testing.T) {
+	tests := []struct {
+		args        []string
+		wantErr     bool
+		wantTargets map[string]bool
+	}{
+		{[]string{"-all-languages"}, false, map[string]bool{"DE": true, "EN": true, "FR": true}},
+		{[]string{"-all-languages", "-include-variants"}, false, map[string]bool{"DE": true, "EN-GB": true, "EN-US": true, "FR": true}},
+		{[]string{"-all-languages", "-target", "DE"}, true, nil},
+	}
+	for _, tt := range tests {
+		recorder := useAPIStub(t)
+		a, _, _ := newTestApp(testConfig())
+
+		err := a.runTranslate(tt.args)
+		if tt.wantErr {
+			if exitCode(err) != exitConfig {
+				t.Errorf("translate %v error = %v, want a config error", tt.args, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("translate %v error = %v", tt.args, err)
+		}
+		sent := map[string]bool{}
+		for _, request := range recorder.requests {
+			sent[request["target_lang"].(string)] = true
+		}
+		if !reflect.DeepEqual(sent, tt.wantTargets) {
+			t.Errorf("translate %v translated into %v, want %v", tt.args, sent, tt.wantTargets)
+		}
+	}
+}
+
+func TestTranslateFieldSourceLangs(t *testing.T) {
+	tests := []struct {
+		name            string
+		sourceLang      string
+		args            []string
+		wantTitle       string
+		wantDescription string
+		wantErr         bool
+	}{
+		{name: "auto-detect"},
+		{name: "per field", args: []string{"-title-source", "en", "-desc-source", " ES "}, wantTitle: "EN", wantDescription: "ES"},
+		{name: "title only", args: []string{"-title-source", "EN"}, wantTitle: "EN"},
+		{name: "falls back to source_lang", sourceLang: "FR", args: []string{"-desc-source", "ES"}, wantTitle: "FR", wantDescription: "ES"},
+		{name: "invalid code", args: []string{"-title-source", "english"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := useAPIStub(t)
+			config := testConfig()
+			config.SourceLang = tt.sourceLang
+			config.TargetLangs = []string{"DE"}
+			a, _, _ := newTestApp(config)
+
+			err := a.runTranslate(tt.args)
+			if tt.wantErr {
+				if exitCode(err) != exitConfig || len(recorder.requests) != 0 {
+					t.Errorf("translate error = %v after %d requests, want a config error before any", err, len(recorder.requests))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("translate error = %v", err)
+			}
+
+			want := map[string]string{"Title": tt.wantTitle, "Description": tt.wantDescription}
+			for _, request := range recorder.requests {
+				text := request["text"].([]interface{})[0].(string)
+				got, _ := request["source_lang"].(string)
+				if got != want[text] {
+					t.Errorf("%s sent with source_lang %q, want %q", text, got, want[text])
+				}
+			}
+			if len(recorder.requests) != 2 {
+				t.Errorf("sent %d translate requests, want 2", len(recorder.requests))
+			}
+		})
+	}
+}
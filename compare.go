@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// providerOutput is what one provider made of a text.
+type providerOutput struct {
+	Provider string
+	Text     string
+	Err      error
+}
+
+// parseProviders splits a comma separated list of provider names, e.g.
+// "deepl,google". At least two different providers are needed to compare.
+func parseProviders(list string) ([]string, error) {
+	var providers []string
+	seen := make(map[string]bool)
+	for _, token := range strings.Split(list, ",") {
+		provider := strings.ToLower(strings.TrimSpace(token))
+		switch provider {
+		case providerDeepl, providerGoogle, providerAzure:
+		default:
+			return nil, fmt.Errorf("unknown provider %q, expected %q, %q or %q", strings.TrimSpace(token), providerDeepl, providerGoogle, providerAzure)
+		}
+		if !seen[provider] {
+			seen[provider] = true
+			providers = append(providers, provider)
+		}
+	}
+	if len(providers) < 2 {
+		return nil, errors.New("name at least two different providers")
+	}
+	return providers, nil
+}
+
+// compareTranslations translates text with every translator. A failing
+// provider only fills in its own Err.
+func compareTranslations(providers []string, translators []Translator, text string, sourceLang string, targetLang string) []providerOutput {
+	outputs := make([]providerOutput, len(providers))
+	for i, provider := range providers {
+		outputs[i].Provider = provider
+		if translators[i] == nil {
+			outputs[i].Err = errors.New("provider is not configured")
+			continue
+		}
+		outputs[i].Text, outputs[i].Err = translators[i].Translate(text, sourceLang, targetLang)
+	}
+	return outputs
+}
+
+// outputsAgree reports whether every provider that succeeded produced the
+// same text, ignoring surrounding whitespace. Less than two successful
+// outputs never agree.
+func outputsAgree(outputs []providerOutput) bool {
+	var texts []string
+	for _, output := range outputs {
+		if output.Err == nil {
+			texts = append(texts, strings.TrimSpace(output.Text))
+		}
+	}
+	if len(texts) < 2 {
+		return false
+	}
+	for _, text := range texts[1:] {
+		if text != texts[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeComparison prints the outputs of one text labeled by provider.
+func writeComparison(w io.Writer, label string, outputs []providerOutput) {
+	fmt.Fprintf(w, "%s:\n", label)
+	for _, output := range outputs {
+		if output.Err != nil {
+			fmt.Fprintf(w, "  %s: error: %v\n", output.Provider, output.Err)
+			continue
+		}
+		fmt.Fprintf(w, "  %s: %s\n", output.Provider, output.Text)
+	}
+	if outputsAgree(outputs) {
+		fmt.Fprintln(w, "  agree: yes")
+	} else {
+		fmt.Fprintln(w, "  agree: no")
+	}
+}
+
+// runCompare translates every video with each of providers and prints the
+// outputs side by side. The error joins what every provider failed with.
+func (a *app) runCompare(config Config, providers []string, fields videoFields) error {
+	var errs []error
+	translators := make([]Translator, len(providers))
+	for i, provider := range providers {
+		providerConfig := config
+		providerConfig.Provider = provider
+		if err := providerConfig.ValidateProvider(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider, err))
+			continue
+		}
+		translator, err := a.newTranslator(providerConfig)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider, err))
+			continue
+		}
+		translators[i] = translator
+	}
+
+	videos, err := a.fetchVideos(config, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	fields = fields.orAll()
+	for _, video := range videos {
+		fmt.Fprintln(a.stdout, "Video:", video.ID)
+		for _, targetLang := range config.TargetLangs {
+			texts := []struct {
+				name string
+				text string
+				pick bool
+			}{
+				{"Title", video.Title, fields.Title},
+				{"Description", video.Description, fields.Description},
+			}
+			for _, text := range texts {
+				if !text.pick || strings.TrimSpace(text.text) == "" {
+					continue
+				}
+				outputs := compareTranslations(providers, translators, text.text, config.SourceLang, targetLang)
+				writeComparison(a.stdout, text.name+" → "+targetLang, outputs)
+				for i, output := range outputs {
+					// Unconfigured providers were reported once already
+					if output.Err != nil && translators[i] != nil {
+						errs = append(errs, fmt.Errorf("%s: %s to %s: %w", output.Provider, strings.ToLower(text.name), targetLang, output.Err))
+					}
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProviders(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{"deepl,google", []string{"deepl", "google"}, false},
+		{" Google , azure,google ", []string{"google", "azure"}, false},
+		{"deepl,deepl", nil, true},
+		{"deepl", nil, true},
+		{"deepl,bing", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseProviders(tt.list)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseProviders(%q) = %v, %v, want %v", tt.list, got, err, tt.want)
+		}
+	}
+}
+
+func TestWriteComparison(t *testing.T) {
+	constant := func(text string) Translator {
+		return fakeTranslator{translate: func(string, string, string) (string, error) { return text, nil }}
+	}
+	failing := fakeTranslator{translate: func(string, string, string) (string, error) {
+		return "", errors.New("quota exceeded")
+	}}
+
+	tests := []struct {
+		name        string
+		providers   []string
+		translators []Translator
+		want        []string
+	}{
+		{
+			name:        "different outputs",
+			providers:   []string{providerDeepl, providerGoogle},
+			translators: []Translator{constant("Hallo Welt"), constant("Hallo, Welt")},
+			want:        []string{"  deepl: Hallo Welt\n", "  google: Hallo, Welt\n", "  agree: no\n"},
+		},
+		{
+			name:        "same outputs",
+			providers:   []string{providerDeepl, providerGoogle},
+			translators: []Translator{constant("Hallo Welt"), constant(" Hallo Welt\n")},
+			want:        []string{"  agree: yes\n"},
+		},
+		{
+			name:        "one provider fails",
+			providers:   []string{providerDeepl, providerGoogle, providerAzure},
+			translators: []Translator{failing, constant("Hallo Welt"), nil},
+			want: []string{"  deepl: error: quota exceeded\n", "  google: Hallo Welt\n",
+				"  azure: error: provider is not configured\n", "  agree: no\n"},
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		outputs := compareTranslations(tt.providers, tt.translators, "Hello world", "", "DE")
+		writeComparison(&buf, "Title → DE", outputs)
+		if !strings.HasPrefix(buf.String(), "Title → DE:\n") {
+			t.Errorf("%s: output = %q, want the label first", tt.name, buf.String())
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s: output = %q, want it to contain %q", tt.name, buf.String(), want)
+			}
+		}
+	}
+}
+
+func TestTranslateCompare(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/youtube/v3/videos", youtubeVideoHandler("dQw4w9WgXcQ", "Title"))
+	useStubServer(t, mux)
+
+	config := testConfig()
+	config.TargetLangs = []string{"DE"}
+	config.GoogleApiKey = "google-key"
+	a, stdout, _ := newTestApp(config)
+	a.newTranslator = func(config Config) (Translator, error) {
+		provider := config.Provider
+		return fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+			if provider == providerGoogle && text == "Description" {
+				return "", errors.New("backend error")
+			}
+			return provider + ":" + text, nil
+		}}, nil
+	}
+
+	err := a.runTranslate([]string{"-compare", "deepl,google,azure"})
+	if err == nil {
+		t.Fatal("translate -compare succeeded, want the provider errors")
+	}
+	for _, want := range []string{"azure: missing required config value azure_api_key", "google: description to DE: backend error"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("translate -compare error = %v, want it to contain %q", err, want)
+		}
+	}
+	for _, want := range []string{
+		"Video: dQw4w9WgXcQ\n",
+		"Title → DE:\n  deepl: deepl:Title\n  google: google:Title\n  azure: error: provider is not configured\n  agree: no\n",
+		"Description → DE:\n  deepl: deepl:Description\n  google: error: backend error\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output = %q, want it to contain %q", stdout.String(), want)
+		}
+	}
+}
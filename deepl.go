@@ -0,0 +1,552 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	deeplFreeBaseURL = "https://api-free.deepl.com"
+	deeplProBaseURL  = "https://api.deepl.com"
+
+	deeplLanguageTypeSource = "source"
+	deeplLanguageTypeTarget = "target"
+)
+
+type TranslationResponse struct {
+	Translations []struct {
+		DetectedSourceLanguage string `json:"detected_source_language"`
+		Text                   string `json:"text"`
+		// BilledCharacters is only sent when show_billed_characters was
+		// requested
+		BilledCharacters *int `json:"billed_characters,omitempty"`
+	} `json:"translations"`
+}
+
+// DeeplLanguage is a language every provider reports. Its JSON field names
+// are what `languages -json` prints and stay the same across providers.
+type DeeplLanguage struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+type DeeplLanguagesResponse struct {
+	LanguageList []struct {
+		Code string `json:"language"`
+		Name string `json:"name"`
+	} `json:"languages"`
+}
+
+type DeeplUsage struct {
+	CharacterCount int64 `json:"character_count"`
+	CharacterLimit int64 `json:"character_limit"`
+}
+
+// deeplStatusQuotaExceeded is the non-standard status DeepL answers with once
+// the character quota is used up.
+const deeplStatusQuotaExceeded = 456
+
+// ErrQuotaExceeded matches, using errors.Is, the DeeplError DeepL returns
+// once the character quota is used up.
+var ErrQuotaExceeded = errors.New("DeepL character quota exceeded, check the usage command or upgrade your DeepL plan")
+
+// DeeplError is returned when the DeepL API answers with a non-200 status.
+// Message holds the "message" field of the error body when DeepL sent one.
+type DeeplError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *DeeplError) Error() string {
+	if e.StatusCode == deeplStatusQuotaExceeded {
+		return ErrQuotaExceeded.Error()
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("DeepL request failed with status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("DeepL request failed with status code: %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *DeeplError) Is(target error) bool {
+	return target == ErrQuotaExceeded && e.StatusCode == deeplStatusQuotaExceeded
+}
+
+func newDeeplError(resp *http.Response) *DeeplError {
+	deeplErr := &DeeplError{StatusCode: resp.StatusCode}
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
+		deeplErr.Message = body.Message
+	}
+
+	return deeplErr
+}
+
+// deeplBaseURLOverride replaces the DeepL host for every request, e.g. to go
+// through a proxy. main sets it from the loaded config.
+var deeplBaseURLOverride string
+
+// deeplBaseURL returns explicit when set. Otherwise free API keys, which end
+// in ":fx", use the free endpoint and all other keys the Pro one.
+func deeplBaseURL(explicit string, apiKey string) string {
+	if explicit = strings.TrimRight(explicit, "/"); explicit != "" {
+		return explicit
+	}
+	if strings.HasSuffix(apiKey, ":fx") {
+		return deeplFreeBaseURL
+	}
+	return deeplProBaseURL
+}
+
+// DeeplTranslator implements Translator on top of the DeepL API. Options are
+// applied to every Translate call. An empty BaseURL picks the endpoint from
+// the API key, a nil Limiter doesn't limit the request rate.
+type DeeplTranslator struct {
+	APIKey  string
+	BaseURL string
+	Client  HTTPDoer
+	Limiter *rateLimiter
+	// Retry is nil to retry as maxRetries and backoff say
+	Retry   *retryPolicy
+	Options TranslateOptions
+}
+
+func newDeeplTranslator(apiKey string) *DeeplTranslator {
+	return &DeeplTranslator{
+		APIKey:  apiKey,
+		BaseURL: deeplBaseURLOverride,
+		Client:  httpClient,
+		Limiter: deeplLimiter,
+	}
+}
+
+func (t *DeeplTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	return t.translate(context.Background(), text, sourceLang, targetLang, t.Options)
+}
+
+// Languages returns the languages DeepL can translate into, including
+// regional variants such as EN-GB.
+func (t *DeeplTranslator) Languages() ([]DeeplLanguage, error) {
+	return t.languages(context.Background(), deeplLanguageTypeTarget)
+}
+
+func (t *DeeplTranslator) newRequest(ctx context.Context, method string, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, deeplBaseURL(t.BaseURL, t.APIKey)+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+t.APIKey)
+	return req, nil
+}
+
+// deeplLanguagesPath builds the languages endpoint path for langType, which
+// must be "source" or "target". Without a type DeepL returns source languages.
+func deeplLanguagesPath(langType string) (string, error) {
+	switch langType {
+	case "":
+		return "/v2/languages", nil
+	case deeplLanguageTypeSource, deeplLanguageTypeTarget:
+		return "/v2/languages?type=" + url.QueryEscape(langType), nil
+	default:
+		return "", fmt.Errorf("unknown DeepL language type %q, expected %q or %q",
+			langType, deeplLanguageTypeSource, deeplLanguageTypeTarget)
+	}
+}
+
+func (t *DeeplTranslator) languages(ctx context.Context, langType string) ([]DeeplLanguage, error) {
+	path, err := deeplLanguagesPath(langType)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := t.newRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.Retry.do(t.Client, t.Limiter, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newDeeplError(resp)
+	}
+
+	var response []struct {
+		Code string `json:"language"`
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	languages := make([]DeeplLanguage, 0, len(response))
+	for _, lang := range response {
+		languages = append(languages, DeeplLanguage{Code: lang.Code, Name: lang.Name})
+	}
+	return languages, nil
+}
+
+func (t *DeeplTranslator) usage(ctx context.Context) (DeeplUsage, error) {
+	req, err := t.newRequest(ctx, "GET", "/v2/usage", nil)
+	if err != nil {
+		return DeeplUsage{}, err
+	}
+
+	resp, err := t.Retry.do(t.Client, t.Limiter, req)
+	if err != nil {
+		return DeeplUsage{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return DeeplUsage{}, newDeeplError(resp)
+	}
+
+	var usage DeeplUsage
+	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+		return DeeplUsage{}, err
+	}
+
+	return usage, nil
+}
+
+// TranslationResult is a translated text along with the source language
+// the provider detected, or the one it was given. BilledCharacters is what
+// the request cost as DeepL reports it, or the characters of the source text
+// for the other providers and when DeepL doesn't.
+type TranslationResult struct {
+	Text                   string
+	DetectedSourceLanguage string
+	BilledCharacters       int
+}
+
+// TranslateDetailed is Translate that also reports the detected source
+// language.
+func (t *DeeplTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.TranslateDetailedCtx(context.Background(), text, sourceLang, targetLang)
+}
+
+// TranslateDetailedCtx is TranslateDetailed with the request cancelled once
+// ctx is done.
+func (t *DeeplTranslator) TranslateDetailedCtx(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.translateDetailed(ctx, text, sourceLang, targetLang, t.Options)
+}
+
+func (t *DeeplTranslator) translate(ctx context.Context, text string, sourceLang string, targetLang string, opts TranslateOptions) (string, error) {
+	result, err := t.translateDetailed(ctx, text, sourceLang, targetLang, opts)
+	return result.Text, err
+}
+
+func (t *DeeplTranslator) translateDetailed(ctx context.Context, text string, sourceLang string, targetLang string, opts TranslateOptions) (TranslationResult, error) {
+	if err := opts.check(sourceLang); err != nil {
+		return TranslationResult{}, err
+	}
+
+	translationResponse, err := t.sendTranslateRequest(ctx, buildTranslateRequest(text, sourceLang, targetLang, opts))
+	if err != nil {
+		return TranslationResult{}, err
+	}
+
+	// Check if translations are available
+	if len(translationResponse.Translations) == 0 {
+		return TranslationResult{}, errors.New("no translations found")
+	}
+
+	translation := translationResponse.Translations[0]
+	result := TranslationResult{
+		Text:                   translation.Text,
+		DetectedSourceLanguage: translation.DetectedSourceLanguage,
+		BilledCharacters:       utf8.RuneCountInString(text),
+	}
+	// Older API versions ignore show_billed_characters, then the local count
+	// has to do
+	if translation.BilledCharacters != nil {
+		result.BilledCharacters = *translation.BilledCharacters
+	}
+	return result, nil
+}
+
+// check reports options DeepL would reject or silently ignore.
+func (opts TranslateOptions) check(sourceLang string) error {
+	// DeepL only applies a glossary when the source language is known
+	if opts.GlossaryID != "" && sourceLang == "" {
+		return errors.New("a source language is required when translating with a glossary")
+	}
+	if opts.TagHandling != "" && opts.TagHandling != "html" && opts.TagHandling != "xml" {
+		return fmt.Errorf("unknown tag handling %q, expected \"html\" or \"xml\"", opts.TagHandling)
+	}
+	if opts.OutlineDetection != nil && opts.TagHandling != "xml" {
+		return errors.New("outline_detection only works with tag_handling \"xml\"")
+	}
+	switch opts.SplitSentences {
+	case "", "0", "1", "nonewlines":
+	default:
+		return fmt.Errorf("unknown split_sentences %q, expected \"0\", \"1\" or \"nonewlines\"", opts.SplitSentences)
+	}
+	return nil
+}
+
+// sendTranslateRequest posts data, built by buildTranslateRequest or
+// buildBatchTranslateRequest, to the translate endpoint.
+func (t *DeeplTranslator) sendTranslateRequest(ctx context.Context, data map[string]interface{}) (TranslationResponse, error) {
+	// Prepare translation request
+	requestData, err := json.Marshal(data)
+	if err != nil {
+		return TranslationResponse{}, fmt.Errorf("failed to marshal request data: %v", err)
+	}
+
+	// Send request to DeepL API
+	req, err := t.newRequest(ctx, "POST", "/v2/translate", bytes.NewBuffer(requestData))
+	if err != nil {
+		return TranslationResponse{}, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.Retry.do(t.Client, t.Limiter, req)
+	if err != nil {
+		return TranslationResponse{}, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check HTTP response status code
+	if resp.StatusCode != http.StatusOK {
+		return TranslationResponse{}, newDeeplError(resp)
+	}
+
+	// Parse response
+	var translationResponse TranslationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&translationResponse); err != nil {
+		return TranslationResponse{}, fmt.Errorf("failed to parse response body: %v", err)
+	}
+	return translationResponse, nil
+}
+
+// deeplMaxTextsPerRequest is the most texts DeepL translates in one request.
+const deeplMaxTextsPerRequest = 50
+
+// translateBatch translates texts with as few requests as DeepL allows and
+// returns the translations in the order of texts.
+func (t *DeeplTranslator) translateBatch(ctx context.Context, texts []string, sourceLang string, targetLang string, opts TranslateOptions) ([]string, error) {
+	if err := opts.check(sourceLang); err != nil {
+		return nil, err
+	}
+
+	translated := make([]string, 0, len(texts))
+	for start := 0; start < len(texts); start += deeplMaxTextsPerRequest {
+		end := start + deeplMaxTextsPerRequest
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch := texts[start:end]
+
+		response, err := t.sendTranslateRequest(ctx, buildBatchTranslateRequest(batch, sourceLang, targetLang, opts))
+		if err != nil {
+			return nil, err
+		}
+		// DeepL answers in the order of the request, a different count means
+		// the translations can't be matched to their texts
+		if len(response.Translations) != len(batch) {
+			return nil, fmt.Errorf("DeepL returned %d translations for %d texts", len(response.Translations), len(batch))
+		}
+		for _, translation := range response.Translations {
+			translated = append(translated, translation.Text)
+		}
+	}
+	return translated, nil
+}
+
+// getDeeplSourceLanguages is the original single-argument form of
+// getDeeplLanguages and lists source languages.
+func getDeeplSourceLanguages(apiKey string) ([]DeeplLanguage, error) {
+	return getDeeplLanguages(apiKey, deeplLanguageTypeSource)
+}
+
+func getDeeplLanguages(apiKey string, langType string) ([]DeeplLanguage, error) {
+	return getDeeplLanguagesCtx(context.Background(), apiKey, langType)
+}
+
+func getDeeplLanguagesCtx(ctx context.Context, apiKey string, langType string) ([]DeeplLanguage, error) {
+	return newDeeplTranslator(apiKey).languages(ctx, langType)
+}
+
+func getDeeplUsage(apiKey string) (DeeplUsage, error) {
+	return getDeeplUsageCtx(context.Background(), apiKey)
+}
+
+func getDeeplUsageCtx(ctx context.Context, apiKey string) (DeeplUsage, error) {
+	return newDeeplTranslator(apiKey).usage(ctx)
+}
+
+// TranslateOptions holds optional DeepL translate parameters. Zero values are
+// left out of the request.
+type TranslateOptions struct {
+	Formality  string
+	GlossaryID string
+	// TagHandling is "html" or "xml". IgnoreTags and SplittingTags are only
+	// sent along with it.
+	TagHandling   string
+	IgnoreTags    []string
+	SplittingTags []string
+	// OutlineDetection, when set, turns DeepL's structure inference for XML
+	// on or off. It needs TagHandling "xml".
+	OutlineDetection *bool
+	// PreserveFormatting stops DeepL from correcting punctuation and
+	// capitalization, which keeps formatted descriptions intact.
+	PreserveFormatting bool
+	// SplitSentences is "0", "1" or "nonewlines". Subtitle cues usually want
+	// "nonewlines" or "0" since each cue is already a fragment.
+	SplitSentences string
+	// Context is extra text, e.g. the description for a title, that helps
+	// DeepL disambiguate without being translated itself.
+	Context string
+}
+
+// DeepL rejects the formality parameter for targets outside this set.
+var formalityTargetLangs = map[string]bool{
+	"DE":    true,
+	"FR":    true,
+	"IT":    true,
+	"ES":    true,
+	"NL":    true,
+	"PL":    true,
+	"PT-BR": true,
+	"PT-PT": true,
+	"JA":    true,
+	"RU":    true,
+}
+
+func supportsFormality(targetLang string) bool {
+	return formalityTargetLangs[strings.ToUpper(targetLang)]
+}
+
+func buildTranslateRequest(text string, sourceLang string, targetLang string, opts TranslateOptions) map[string]interface{} {
+	return buildBatchTranslateRequest([]string{text}, sourceLang, targetLang, opts)
+}
+
+// buildBatchTranslateRequest is buildTranslateRequest for several texts in one
+// request.
+func buildBatchTranslateRequest(texts []string, sourceLang string, targetLang string, opts TranslateOptions) map[string]interface{} {
+	data := map[string]interface{}{
+		"text":        texts,
+		"target_lang": targetLang,
+		// Report what DeepL actually bills instead of estimating it
+		"show_billed_characters": true,
+	}
+	// Without source_lang DeepL auto-detects the source language
+	if sourceLang != "" {
+		data["source_lang"] = sourceLang
+	}
+	if opts.Formality != "" && supportsFormality(targetLang) {
+		data["formality"] = opts.Formality
+	}
+	if opts.GlossaryID != "" {
+		data["glossary_id"] = opts.GlossaryID
+	}
+	if opts.TagHandling != "" {
+		data["tag_handling"] = opts.TagHandling
+		if len(opts.IgnoreTags) > 0 {
+			data["ignore_tags"] = opts.IgnoreTags
+		}
+		if len(opts.SplittingTags) > 0 {
+			data["splitting_tags"] = opts.SplittingTags
+		}
+		if opts.OutlineDetection != nil && opts.TagHandling == "xml" {
+			data["outline_detection"] = *opts.OutlineDetection
+		}
+	}
+	if opts.PreserveFormatting {
+		data["preserve_formatting"] = true
+	}
+	if opts.Context != "" {
+		data["context"] = opts.Context
+	}
+	if opts.SplitSentences != "" {
+		data["split_sentences"] = opts.SplitSentences
+	}
+	return data
+}
+
+func translateText(text string, apiKey string, sourceLang string, targetLang string) (string, error) {
+	return translateTextWithOptions(text, apiKey, sourceLang, targetLang, TranslateOptions{})
+}
+
+func translateTextWithOptions(text string, apiKey string, sourceLang string, targetLang string, opts TranslateOptions) (string, error) {
+	return translateTextCtx(context.Background(), text, apiKey, sourceLang, targetLang, opts)
+}
+
+func translateTextCtx(ctx context.Context, text string, apiKey string, sourceLang string, targetLang string, opts TranslateOptions) (string, error) {
+	return newDeeplTranslator(apiKey).translate(ctx, text, sourceLang, targetLang, opts)
+}
+
+func translateBatch(texts []string, apiKey string, targetLang string) ([]string, error) {
+	return translateBatchCtx(context.Background(), texts, apiKey, "", targetLang, TranslateOptions{})
+}
+
+func translateBatchCtx(ctx context.Context, texts []string, apiKey string, sourceLang string, targetLang string, opts TranslateOptions) ([]string, error) {
+	return newDeeplTranslator(apiKey).translateBatch(ctx, texts, sourceLang, targetLang, opts)
+}
+
+// validateTargetLangs checks every target against the supported languages,
+// ignoring case. The error lists the valid codes so typos are easy to fix.
+func validateTargetLangs(targetLangs []string, languages []DeeplLanguage) error {
+	supported := make(map[string]bool, len(languages))
+	codes := make([]string, 0, len(languages))
+	for _, lang := range languages {
+		supported[strings.ToUpper(lang.Code)] = true
+		codes = append(codes, strings.ToUpper(lang.Code))
+	}
+	sort.Strings(codes)
+
+	var unknown []string
+	for _, targetLang := range targetLangs {
+		if !supported[strings.ToUpper(targetLang)] {
+			unknown = append(unknown, targetLang)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unsupported target language %s, valid codes are: %s",
+			strings.Join(unknown, ", "), strings.Join(codes, ", "))
+	}
+
+	return nil
+}
+
+// filterTargetLangs splits targetLangs into the ones languages supports and
+// the ones it doesn't, keeping their order.
+func filterTargetLangs(targetLangs []string, languages []DeeplLanguage) (supported []string, unsupported []string) {
+	known := make(map[string]bool, len(languages))
+	for _, lang := range languages {
+		known[strings.ToUpper(lang.Code)] = true
+	}
+
+	for _, targetLang := range targetLangs {
+		if known[strings.ToUpper(targetLang)] {
+			supported = append(supported, targetLang)
+		} else {
+			unsupported = append(unsupported, targetLang)
+		}
+	}
+	return supported, unsupported
+}
+
+func translateTextDetailed(text string, apiKey string, sourceLang string, targetLang string, opts TranslateOptions) (TranslationResult, error) {
+	return translateTextDetailedCtx(context.Background(), text, apiKey, sourceLang, targetLang, opts)
+}
+
+func translateTextDetailedCtx(ctx context.Context, text string, apiKey string, sourceLang string, targetLang string, opts TranslateOptions) (TranslationResult, error) {
+	return newDeeplTranslator(apiKey).translateDetailed(ctx, text, sourceLang, targetLang, opts)
+}
@@ -0,0 +1,781 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDeeplBaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		explicit string
+		apiKey   string
+		want     string
+	}{
+		{"free key", "", "abc-123:fx", deeplFreeBaseURL},
+		{"pro key", "", "abc-123", deeplProBaseURL},
+		{"fx inside the key", "", "abc:fx-123", deeplProBaseURL},
+		{"explicit", "http://localhost:8080/", "abc-123:fx", "http://localhost:8080"},
+		{"explicit over pro key", "https://proxy.example.com/deepl", "abc-123", "https://proxy.example.com/deepl"},
+		{"trailing slashes", "https://proxy.example.com/deepl//", "abc-123", "https://proxy.example.com/deepl"},
+	}
+	for _, tt := range tests {
+		if got := deeplBaseURL(tt.explicit, tt.apiKey); got != tt.want {
+			t.Errorf("%s: deeplBaseURL(%q, %q) = %q, want %q", tt.name, tt.explicit, tt.apiKey, got, tt.want)
+		}
+	}
+}
+
+// deeplRecorder is a DeepL translate endpoint that records the decoded
+// request bodies and translates by prefixing the target language. Targets in
+// fail are answered with their status instead.
+type deeplRecorder struct {
+	fail map[string]int
+
+	mu       sync.Mutex
+	requests []map[string]interface{}
+}
+
+func (d *deeplRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	d.mu.Lock()
+	d.requests = append(d.requests, body)
+	d.mu.Unlock()
+
+	targetLang, _ := body["target_lang"].(string)
+	if status, ok := d.fail[targetLang]; ok {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"message":"Value for 'target_lang' not supported."}`))
+		return
+	}
+	var translations []map[string]interface{}
+	for _, text := range body["text"].([]interface{}) {
+		translations = append(translations, map[string]interface{}{
+			"text":                     targetLang + ":" + text.(string),
+			"detected_source_language": "EN",
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"translations": translations})
+}
+
+// last returns the most recent request body.
+func (d *deeplRecorder) last() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.requests) == 0 {
+		return nil
+	}
+	return d.requests[len(d.requests)-1]
+}
+
+// useDeeplStub points every DeepL request of the test at handler, checking
+// the API key, and restores the client afterwards.
+func useDeeplStub(t *testing.T, handler http.Handler) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key deepl-key" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"Wrong endpoint"}`))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+
+	previousClient, previousURL := httpClient, deeplBaseURLOverride
+	httpClient, deeplBaseURLOverride = server.Client(), server.URL
+	t.Cleanup(func() {
+		httpClient, deeplBaseURLOverride = previousClient, previousURL
+		server.Close()
+	})
+}
+
+func TestTranslateTextUsesKey(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+
+	got, err := translateText("Hello", "deepl-key", "", "DE")
+	if err != nil || got != "DE:Hello" {
+		t.Fatalf("translateText() = %q, %v, want DE:Hello", got, err)
+	}
+	if _, err := translateText("Hello", "other-key", "", "DE"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("translateText() with a wrong key error = %v, want status 403", err)
+	}
+}
+
+func TestTranslateTextSourceLang(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+
+	tests := []struct {
+		sourceLang string
+		wantSent   bool
+	}{
+		{"", false},
+		{"EN", true},
+	}
+	for _, tt := range tests {
+		if _, err := translateText("Hello", "deepl-key", tt.sourceLang, "DE"); err != nil {
+			t.Fatalf("translateText(%q) error = %v", tt.sourceLang, err)
+		}
+		got, sent := recorder.last()["source_lang"]
+		if sent != tt.wantSent || (sent && got != tt.sourceLang) {
+			t.Errorf("translateText(%q) sent source_lang %v (%v), want %v", tt.sourceLang, got, sent, tt.wantSent)
+		}
+	}
+}
+
+func TestGetDeeplUsage(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/usage", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"character_count":180118,"character_limit":500000}`))
+	})
+	useDeeplStub(t, mux)
+
+	usage, err := getDeeplUsage("deepl-key")
+	if err != nil {
+		t.Fatalf("getDeeplUsage() error = %v", err)
+	}
+	if want := (DeeplUsage{CharacterCount: 180118, CharacterLimit: 500000}); usage != want {
+		t.Errorf("getDeeplUsage() = %+v, want %+v", usage, want)
+	}
+
+	_, err = getDeeplUsage("wrong-key")
+	var deeplErr *DeeplError
+	if !errors.As(err, &deeplErr) || deeplErr.StatusCode != http.StatusForbidden {
+		t.Errorf("getDeeplUsage() with a wrong key error = %v, want a DeeplError with status 403", err)
+	}
+}
+
+func TestDeeplErrors(t *testing.T) {
+	useDeeplStub(t, http.NotFoundHandler())
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"translateText", func() error { _, err := translateText("Hello", "wrong-key", "", "DE"); return err }},
+		{"getDeeplLanguages", func() error { _, err := getDeeplLanguages("wrong-key", deeplLanguageTypeTarget); return err }},
+		{"getDeeplUsage", func() error { _, err := getDeeplUsage("wrong-key"); return err }},
+	}
+	for _, tt := range tests {
+		err := tt.call()
+		var deeplErr *DeeplError
+		if !errors.As(err, &deeplErr) {
+			t.Fatalf("%s: error = %v, want a DeeplError", tt.name, err)
+		}
+		if deeplErr.StatusCode != http.StatusForbidden || !strings.Contains(err.Error(), "Wrong endpoint") {
+			t.Errorf("%s: error = %v, want status 403 and the message of the body", tt.name, err)
+		}
+	}
+}
+
+func TestDeeplErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *DeeplError
+		want string
+	}{
+		{&DeeplError{StatusCode: 403, Message: "Wrong endpoint"}, "DeepL request failed with status code: 403: Wrong endpoint"},
+		{&DeeplError{StatusCode: 500}, "DeepL request failed with status code: 500"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestTranslateFormality(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+
+	tests := []struct {
+		targetLang string
+		formality  string
+		wantSent   bool
+	}{
+		{"EN", "less", false},
+		{"DE", "less", true},
+		{"pt-br", "prefer_more", true},
+		{"DE", "", false},
+	}
+	for _, tt := range tests {
+		if _, err := translateTextWithOptions("Hello", "deepl-key", "", tt.targetLang, TranslateOptions{Formality: tt.formality}); err != nil {
+			t.Fatalf("translateTextWithOptions(%s) error = %v", tt.targetLang, err)
+		}
+		got, sent := recorder.last()["formality"]
+		if sent != tt.wantSent || (sent && got != tt.formality) {
+			t.Errorf("%s with formality %q sent %v (%v), want sent %v", tt.targetLang, tt.formality, got, sent, tt.wantSent)
+		}
+	}
+}
+
+func TestTranslateGlossary(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+
+	opts := TranslateOptions{GlossaryID: "g1"}
+	if _, err := translateTextWithOptions("Hello", "deepl-key", "EN", "DE", opts); err != nil {
+		t.Fatalf("translateTextWithOptions() error = %v", err)
+	}
+	if got := recorder.last()["glossary_id"]; got != "g1" {
+		t.Errorf("glossary_id = %v, want g1", got)
+	}
+
+	// DeepL ignores a glossary without source language
+	recorder.requests = nil
+	if _, err := translateTextWithOptions("Hello", "deepl-key", "", "DE", opts); err == nil {
+		t.Error("translating with a glossary but no source language succeeded")
+	}
+	if len(recorder.requests) != 0 {
+		t.Error("sent a glossary request without source language")
+	}
+}
+
+// blockingHandler signals started and then holds every request until the
+// client gives up on it.
+func blockingHandler(started chan<- struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client leaving once the body is read
+		io.Copy(io.Discard, r.Body)
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	})
+}
+
+func TestDeeplContextCancel(t *testing.T) {
+	started := make(chan struct{}, 1)
+	useDeeplStub(t, blockingHandler(started))
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"translateTextCtx", func(ctx context.Context) error {
+			_, err := translateTextCtx(ctx, "Hello", "deepl-key", "", "DE", TranslateOptions{})
+			return err
+		}},
+		{"getDeeplLanguagesCtx", func(ctx context.Context) error {
+			_, err := getDeeplLanguagesCtx(ctx, "deepl-key", deeplLanguageTypeTarget)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		// Cancelled before the request is sent
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := tt.call(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: error = %v before the request, want context.Canceled", tt.name, err)
+		}
+
+		// Cancelled while DeepL is answering
+		ctx, cancel = context.WithCancel(context.Background())
+		go func() {
+			<-started
+			cancel()
+		}()
+		if err := tt.call(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: error = %v mid-flight, want context.Canceled", tt.name, err)
+		}
+		cancel()
+	}
+}
+
+func TestDeeplTranslator(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/v2/translate", &deeplRecorder{})
+	mux.HandleFunc("/v2/languages", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"language":"DE","name":"German"},{"language":"EN-GB","name":"English (British)"}]`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var translator Translator = &DeeplTranslator{APIKey: "deepl-key", BaseURL: server.URL, Client: server.Client()}
+	got, err := translator.Translate("Hello", "EN", "DE")
+	if err != nil || got != "DE:Hello" {
+		t.Errorf("Translate() = %q, %v, want DE:Hello", got, err)
+	}
+	languages, err := translator.Languages()
+	if err != nil || len(languages) != 2 || languages[1] != (DeeplLanguage{Code: "EN-GB", Name: "English (British)"}) {
+		t.Errorf("Languages() = %v, %v, want DE and EN-GB", languages, err)
+	}
+}
+
+func TestValidateTargetLangs(t *testing.T) {
+	languages := []DeeplLanguage{{Code: "DE"}, {Code: "EN-GB"}, {Code: "EN-US"}, {Code: "PT-BR"}}
+
+	tests := []struct {
+		targets []string
+		wantErr string
+	}{
+		{[]string{"EN-GB", "de", "pt-br"}, ""},
+		{[]string{"EN-XX"}, "unsupported target language EN-XX, valid codes are: DE, EN-GB, EN-US, PT-BR"},
+		{[]string{"DE", "XX", "YY"}, "unsupported target language XX, YY"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		err := validateTargetLangs(tt.targets, languages)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("validateTargetLangs(%v) error = %v", tt.targets, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+			t.Errorf("validateTargetLangs(%v) error = %v, want %q", tt.targets, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFilterTargetLangs(t *testing.T) {
+	languages := []DeeplLanguage{{Code: "DE"}, {Code: "EN-GB"}, {Code: "fr"}}
+
+	tests := []struct {
+		targets         []string
+		wantSupported   []string
+		wantUnsupported []string
+	}{
+		{[]string{"DE", "fr", "en-gb"}, []string{"DE", "fr", "en-gb"}, nil},
+		{[]string{"DE", "XX", "FR", "EN"}, []string{"DE", "FR"}, []string{"XX", "EN"}},
+		{[]string{"XX"}, nil, []string{"XX"}},
+		{nil, nil, nil},
+	}
+	for _, tt := range tests {
+		supported, unsupported := filterTargetLangs(tt.targets, languages)
+		if !reflect.DeepEqual(supported, tt.wantSupported) || !reflect.DeepEqual(unsupported, tt.wantUnsupported) {
+			t.Errorf("filterTargetLangs(%v) = %v, %v, want %v, %v", tt.targets, supported, unsupported, tt.wantSupported, tt.wantUnsupported)
+		}
+	}
+}
+
+func TestDeeplLanguagesPath(t *testing.T) {
+	tests := []struct {
+		langType string
+		want     string
+		wantErr  bool
+	}{
+		{"", "/v2/languages", false},
+		{deeplLanguageTypeSource, "/v2/languages?type=source", false},
+		{deeplLanguageTypeTarget, "/v2/languages?type=target", false},
+		{"both", "", true},
+	}
+	for _, tt := range tests {
+		got, err := deeplLanguagesPath(tt.langType)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("deeplLanguagesPath(%q) = %q, %v, want %q", tt.langType, got, err, tt.want)
+		}
+	}
+}
+
+func TestGetDeeplLanguagesType(t *testing.T) {
+	var query string
+	useDeeplStub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`[{"language":"EN","name":"English"}]`))
+	}))
+
+	tests := []struct {
+		name string
+		call func() ([]DeeplLanguage, error)
+		want string
+	}{
+		{"source wrapper", func() ([]DeeplLanguage, error) { return getDeeplSourceLanguages("deepl-key") }, "type=source"},
+		{"target", func() ([]DeeplLanguage, error) { return getDeeplLanguages("deepl-key", deeplLanguageTypeTarget) }, "type=target"},
+	}
+	for _, tt := range tests {
+		languages, err := tt.call()
+		if err != nil || len(languages) != 1 {
+			t.Fatalf("%s: languages = %v, %v", tt.name, languages, err)
+		}
+		if query != tt.want {
+			t.Errorf("%s: query = %q, want %q", tt.name, query, tt.want)
+		}
+	}
+}
+
+func TestTranslateTagHandling(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+
+	tests := []struct {
+		name string
+		opts TranslateOptions
+		want map[string]interface{}
+	}{
+		{"unset", TranslateOptions{IgnoreTags: []string{"code"}}, map[string]interface{}{}},
+		{"html", TranslateOptions{TagHandling: "html"}, map[string]interface{}{"tag_handling": "html"}},
+		{
+			"xml with tags",
+			TranslateOptions{TagHandling: "xml", IgnoreTags: []string{"code"}, SplittingTags: []string{"p", "br"}},
+			map[string]interface{}{
+				"tag_handling":   "xml",
+				"ignore_tags":    []interface{}{"code"},
+				"splitting_tags": []interface{}{"p", "br"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if _, err := translateTextWithOptions("<p>Hello</p>", "deepl-key", "", "DE", tt.opts); err != nil {
+			t.Fatalf("%s: translateTextWithOptions() error = %v", tt.name, err)
+		}
+		got := map[string]interface{}{}
+		for _, key := range []string{"tag_handling", "ignore_tags", "splitting_tags"} {
+			if value, ok := recorder.last()[key]; ok {
+				got[key] = value
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: sent %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	recorder.requests = nil
+	if _, err := translateTextWithOptions("Hello", "deepl-key", "", "DE", TranslateOptions{TagHandling: "markdown"}); err == nil {
+		t.Error("translating with tag handling markdown succeeded, want an error")
+	}
+	if len(recorder.requests) != 0 {
+		t.Error("sent a request with an unknown tag handling")
+	}
+}
+
+func TestTranslateOutlineDetection(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+	yes, no := true, false
+
+	tests := []struct {
+		name     string
+		opts     TranslateOptions
+		wantSent interface{}
+		wantErr  bool
+	}{
+		{"xml without outline_detection", TranslateOptions{TagHandling: "xml"}, nil, false},
+		{"xml with outline_detection off", TranslateOptions{TagHandling: "xml", OutlineDetection: &no}, false, false},
+		{"xml with outline_detection on", TranslateOptions{TagHandling: "xml", OutlineDetection: &yes}, true, false},
+		{"html", TranslateOptions{TagHandling: "html", OutlineDetection: &no}, nil, true},
+		{"no tag handling", TranslateOptions{OutlineDetection: &no}, nil, true},
+	}
+	for _, tt := range tests {
+		recorder.requests = nil
+		_, err := translateTextWithOptions("<p>Hello</p>", "deepl-key", "", "DE", tt.opts)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "outline_detection only works with tag_handling") {
+				t.Errorf("%s: error = %v, want the tag_handling requirement", tt.name, err)
+			}
+			if len(recorder.requests) != 0 {
+				t.Errorf("%s: sent a request despite the error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: translateTextWithOptions() error = %v", tt.name, err)
+		}
+		if got := recorder.last()["outline_detection"]; got != tt.wantSent {
+			t.Errorf("%s: sent outline_detection %v, want %v", tt.name, got, tt.wantSent)
+		}
+	}
+}
+
+func TestTranslateTextDetailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     TranslationResult
+		wantErr  bool
+	}{
+		{
+			name:     "detected language",
+			response: `{"translations":[{"text":"Hallo","detected_source_language":"JA"}]}`,
+			want:     TranslationResult{Text: "Hallo", DetectedSourceLanguage: "JA", BilledCharacters: 5},
+		},
+		{
+			name:     "billed characters",
+			response: `{"translations":[{"text":"Hallo","detected_source_language":"EN","billed_characters":12}]}`,
+			want:     TranslationResult{Text: "Hallo", DetectedSourceLanguage: "EN", BilledCharacters: 12},
+		},
+		{
+			name:     "no translations",
+			response: `{"translations":[]}`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useDeeplStub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.response))
+			}))
+
+			got, err := translateTextDetailed("Hello", "deepl-key", "", "DE", TranslateOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("translateTextDetailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("translateTextDetailed() = %+v, want %+v", got, tt.want)
+			}
+			if !tt.wantErr {
+				if text, err := translateText("Hello", "deepl-key", "", "DE"); err != nil || text != tt.want.Text {
+					t.Errorf("translateText() = %q, %v, want %q", text, err, tt.want.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestDeeplBaseURLOverride(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/proxy/v2/translate":
+			w.Write([]byte(`{"translations":[{"text":"Hallo"}]}`))
+		case "/proxy/v2/usage":
+			w.Write([]byte(`{"character_count":1,"character_limit":10}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	previousClient, previousURL := httpClient, deeplBaseURLOverride
+	httpClient, deeplBaseURLOverride = server.Client(), server.URL+"/proxy/"
+	defer func() { httpClient, deeplBaseURLOverride = previousClient, previousURL }()
+
+	// A free key would otherwise go to api-free.deepl.com
+	if _, err := translateText("Hello", "abc-123:fx", "", "DE"); err != nil {
+		t.Fatalf("translateText() error = %v", err)
+	}
+	if _, err := getDeeplUsage("abc-123:fx"); err != nil {
+		t.Fatalf("getDeeplUsage() error = %v", err)
+	}
+	if want := []string{"/proxy/v2/translate", "/proxy/v2/usage"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("requested %v, want %v", paths, want)
+	}
+}
+
+func TestTranslatePreserveFormatting(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+
+	for _, enabled := range []bool{false, true} {
+		config := testConfig()
+		config.PreserveFormatting = enabled
+		translator, err := newTranslator(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := translator.Translate("hello  world", "", "DE"); err != nil {
+			t.Fatalf("Translate() error = %v", err)
+		}
+		got, sent := recorder.last()["preserve_formatting"]
+		if sent != enabled || (sent && got != true) {
+			t.Errorf("preserve_formatting %v sent %v (%v)", enabled, got, sent)
+		}
+	}
+}
+
+func TestDeeplQuotaExceeded(t *testing.T) {
+	recorder := &deeplRecorder{fail: map[string]int{"DE": 456, "FR": http.StatusForbidden}}
+	useDeeplStub(t, recorder)
+
+	tests := []struct {
+		targetLang string
+		want       bool
+	}{
+		{"DE", true},
+		{"FR", false},
+	}
+	for _, tt := range tests {
+		_, err := translateText("Hello", "deepl-key", "", tt.targetLang)
+		if err == nil {
+			t.Fatalf("translateText(%s) succeeded, want an error", tt.targetLang)
+		}
+		if got := errors.Is(err, ErrQuotaExceeded); got != tt.want {
+			t.Errorf("translateText(%s) error = %v, errors.Is(ErrQuotaExceeded) = %v, want %v", tt.targetLang, err, got, tt.want)
+		}
+	}
+	if len(recorder.requests) != 2 {
+		t.Errorf("sent %d requests, want 2 without retries", len(recorder.requests))
+	}
+}
+
+func TestTranslateContext(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+
+	tests := []struct {
+		context  string
+		wantSent bool
+	}{
+		{"", false},
+		{"A video about cooking pasta", true},
+	}
+	for _, tt := range tests {
+		result, err := translateTextDetailed("Sauce", "deepl-key", "EN", "DE", TranslateOptions{Context: tt.context})
+		if err != nil {
+			t.Fatalf("translateTextDetailed() error = %v", err)
+		}
+		if result.Text != "DE:Sauce" {
+			t.Errorf("translateTextDetailed() = %q, want only the text translated", result.Text)
+		}
+		got, sent := recorder.last()["context"]
+		if sent != tt.wantSent || (sent && got != tt.context) {
+			t.Errorf("context %q sent %v (%v), want sent %v", tt.context, got, sent, tt.wantSent)
+		}
+		if text := recorder.last()["text"]; !reflect.DeepEqual(text, []interface{}{"Sauce"}) {
+			t.Errorf("text = %v, want the context kept out of it", text)
+		}
+	}
+}
+
+func TestTranslateTextBilledCharacters(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"Hello", 5},
+		{"Grüße", 5},
+		{"こんにちは 🎉", 7},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		result, err := translateTextDetailed(tt.text, "deepl-key", "", "DE", TranslateOptions{})
+		if err != nil {
+			t.Fatalf("translateTextDetailed(%q) error = %v", tt.text, err)
+		}
+		if result.BilledCharacters != tt.want {
+			t.Errorf("translateTextDetailed(%q) billed %d characters, want %d", tt.text, result.BilledCharacters, tt.want)
+		}
+		if recorder.last()["show_billed_characters"] != true {
+			t.Errorf("request lacks show_billed_characters: %v", recorder.last())
+		}
+	}
+}
+
+func TestTranslateTextReportedBilledCharacters(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     int
+	}{
+		{"reported", `{"translations":[{"detected_source_language":"EN","text":"Hallo","billed_characters":42}]}`, 42},
+		{"reported as zero", `{"translations":[{"detected_source_language":"EN","text":"Hallo","billed_characters":0}]}`, 0},
+		{"not reported", `{"translations":[{"detected_source_language":"EN","text":"Hallo"}]}`, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useDeeplStub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.response))
+			}))
+
+			result, err := translateTextDetailed("Hello", "deepl-key", "", "DE", TranslateOptions{})
+			if err != nil {
+				t.Fatalf("translateTextDetailed() error = %v", err)
+			}
+			if result.Text != "Hallo" || result.BilledCharacters != tt.want {
+				t.Errorf("translateTextDetailed() = %+v, want Hallo billed %d characters", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslateBatch(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+
+	many := make([]string, 120)
+	for i := range many {
+		many[i] = fmt.Sprint("text ", i)
+	}
+
+	tests := []struct {
+		name         string
+		texts        []string
+		wantRequests []int
+	}{
+		{"three texts in order", []string{"one", "two", "three"}, []int{3}},
+		{"split at 50 texts", many, []int{50, 50, 20}},
+		{"nothing to translate", nil, nil},
+	}
+	for _, tt := range tests {
+		recorder.requests = nil
+		got, err := translateBatch(tt.texts, "deepl-key", "DE")
+		if err != nil {
+			t.Fatalf("%s: translateBatch() error = %v", tt.name, err)
+		}
+		if len(got) != len(tt.texts) {
+			t.Fatalf("%s: translateBatch() returned %d texts, want %d", tt.name, len(got), len(tt.texts))
+		}
+		for i, text := range tt.texts {
+			if got[i] != "DE:"+text {
+				t.Errorf("%s: text %d = %q, want %q", tt.name, i, got[i], "DE:"+text)
+			}
+		}
+		var sizes []int
+		for _, request := range recorder.requests {
+			sizes = append(sizes, len(request["text"].([]interface{})))
+		}
+		if !reflect.DeepEqual(sizes, tt.wantRequests) {
+			t.Errorf("%s: request sizes = %v, want %v", tt.name, sizes, tt.wantRequests)
+		}
+	}
+}
+
+func TestTranslateBatchMismatchedResponse(t *testing.T) {
+	useDeeplStub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"translations":[{"text":"eins"},{"text":"zwei"}]}`))
+	}))
+
+	_, err := translateBatch([]string{"one", "two", "three"}, "deepl-key", "DE")
+	if err == nil || !strings.Contains(err.Error(), "2 translations for 3 texts") {
+		t.Errorf("translateBatch() error = %v, want the mismatched counts", err)
+	}
+}
+
+func TestTranslateSplitSentences(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"", false},
+		{"0", false},
+		{"1", false},
+		{"nonewlines", false},
+		{"never", true},
+	}
+	for _, tt := range tests {
+		recorder.requests = nil
+		_, err := translateTextWithOptions("One line\nanother", "deepl-key", "", "DE", TranslateOptions{SplitSentences: tt.value})
+		if tt.wantErr {
+			if err == nil || len(recorder.requests) != 0 {
+				t.Errorf("split_sentences %q: error = %v after %d requests, want an error before sending", tt.value, err, len(recorder.requests))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("split_sentences %q: error = %v", tt.value, err)
+		}
+		// DeepL's default applies when the parameter is left out
+		got, sent := recorder.last()["split_sentences"]
+		if sent != (tt.value != "") || (sent && got != tt.value) {
+			t.Errorf("split_sentences %q sent %v (%v)", tt.value, got, sent)
+		}
+	}
+}
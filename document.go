@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const documentTimeout = 5 * time.Minute
+
+// documentPollInterval is how long to wait between two status checks of a
+// document DeepL is still translating.
+var documentPollInterval = 2 * time.Second
+
+// documentHandle identifies an uploaded document, DeepL needs both parts for
+// every later request.
+type documentHandle struct {
+	DocumentID  string `json:"document_id"`
+	DocumentKey string `json:"document_key"`
+}
+
+type documentStatus struct {
+	Status           string `json:"status"`
+	SecondsRemaining int    `json:"seconds_remaining"`
+	Message          string `json:"message"`
+}
+
+// translateDocument translates a whole file, e.g. subtitles, with the DeepL
+// document API. filename tells DeepL the file format. The call blocks until
+// DeepL is done, or fails after documentTimeout.
+func translateDocument(apiKey string, content []byte, filename string, targetLang string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), documentTimeout)
+	defer cancel()
+	return translateDocumentCtx(ctx, apiKey, content, filename, targetLang)
+}
+
+func translateDocumentCtx(ctx context.Context, apiKey string, content []byte, filename string, targetLang string) ([]byte, error) {
+	return newDeeplTranslator(apiKey).translateDocument(ctx, content, filename, targetLang)
+}
+
+func (t *DeeplTranslator) translateDocument(ctx context.Context, content []byte, filename string, targetLang string) ([]byte, error) {
+	handle, err := t.uploadDocument(ctx, content, filename, targetLang)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload document: %w", err)
+	}
+
+	for {
+		status, err := t.documentStatus(ctx, handle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check document status: %w", err)
+		}
+
+		switch status.Status {
+		case "done":
+			return t.downloadDocument(ctx, handle)
+		case "error":
+			return nil, fmt.Errorf("DeepL failed to translate the document: %s", status.Message)
+		}
+
+		timer := time.NewTimer(documentPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("document translation did not finish: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func (t *DeeplTranslator) uploadDocument(ctx context.Context, content []byte, filename string, targetLang string) (documentHandle, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("target_lang", targetLang); err != nil {
+		return documentHandle{}, err
+	}
+	file, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return documentHandle{}, err
+	}
+	if _, err := file.Write(content); err != nil {
+		return documentHandle{}, err
+	}
+	if err := form.Close(); err != nil {
+		return documentHandle{}, err
+	}
+
+	req, err := t.newRequest(ctx, "POST", "/v2/document", bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return documentHandle{}, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := t.Retry.do(t.Client, t.Limiter, req)
+	if err != nil {
+		return documentHandle{}, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return documentHandle{}, newDeeplError(resp)
+	}
+
+	var handle documentHandle
+	if err := json.NewDecoder(resp.Body).Decode(&handle); err != nil {
+		return documentHandle{}, fmt.Errorf("failed to parse response body: %v", err)
+	}
+	if handle.DocumentID == "" || handle.DocumentKey == "" {
+		return documentHandle{}, errors.New("DeepL did not return a document ID and key")
+	}
+
+	return handle, nil
+}
+
+// documentRequest sends the document key to path, which is the status or the
+// result endpoint of the document.
+func (t *DeeplTranslator) documentRequest(ctx context.Context, handle documentHandle, path string) (*http.Response, error) {
+	requestData, err := json.Marshal(map[string]string{"document_key": handle.DocumentKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request data: %v", err)
+	}
+
+	req, err := t.newRequest(ctx, "POST", path, bytes.NewBuffer(requestData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.Retry.do(t.Client, t.Limiter, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newDeeplError(resp)
+	}
+	return resp, nil
+}
+
+func (t *DeeplTranslator) documentStatus(ctx context.Context, handle documentHandle) (documentStatus, error) {
+	resp, err := t.documentRequest(ctx, handle, "/v2/document/"+url.PathEscape(handle.DocumentID))
+	if err != nil {
+		return documentStatus{}, err
+	}
+	defer resp.Body.Close()
+
+	var status documentStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return documentStatus{}, fmt.Errorf("failed to parse response body: %v", err)
+	}
+	return status, nil
+}
+
+func (t *DeeplTranslator) downloadDocument(ctx context.Context, handle documentHandle) ([]byte, error) {
+	resp, err := t.documentRequest(ctx, handle, "/v2/document/"+url.PathEscape(handle.DocumentID)+"/result")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// documentStub is the DeepL document API for a single document, reporting
+// statuses in turn on every status request and then translating the upload
+// by upper casing it.
+type documentStub struct {
+	t        *testing.T
+	statuses []string
+
+	mu       sync.Mutex
+	calls    []string
+	uploaded string
+}
+
+func (d *documentStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, r.URL.Path)
+
+	if r.URL.Path == "/v2/document" {
+		if r.FormValue("target_lang") != "DE" {
+			d.t.Errorf("target_lang = %q, want DE", r.FormValue("target_lang"))
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			d.t.Errorf("upload holds no file: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		if header.Filename != "captions.srt" {
+			d.t.Errorf("filename = %q, want captions.srt", header.Filename)
+		}
+		d.uploaded = string(content)
+		w.Write([]byte(`{"document_id":"doc1","document_key":"key1"}`))
+		return
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["document_key"] != "key1" {
+		d.t.Errorf("%s sent %v, want the document key", r.URL.Path, body)
+	}
+	switch r.URL.Path {
+	case "/v2/document/doc1":
+		status := d.statuses[0]
+		if len(d.statuses) > 1 {
+			d.statuses = d.statuses[1:]
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": status, "message": "Unsupported file"})
+	case "/v2/document/doc1/result":
+		w.Write([]byte(strings.ToUpper(d.uploaded)))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestTranslateDocument(t *testing.T) {
+	previous := documentPollInterval
+	documentPollInterval = time.Millisecond
+	defer func() { documentPollInterval = previous }()
+
+	tests := []struct {
+		name      string
+		statuses  []string
+		want      string
+		wantErr   string
+		wantCalls []string
+	}{
+		{
+			name:      "done after polling",
+			statuses:  []string{"queued", "translating", "done"},
+			want:      "1\n00:00:01,000 --> 00:00:02,000\nHELLO\n",
+			wantCalls: []string{"/v2/document", "/v2/document/doc1", "/v2/document/doc1", "/v2/document/doc1", "/v2/document/doc1/result"},
+		},
+		{
+			name:      "error status",
+			statuses:  []string{"translating", "error"},
+			wantErr:   "Unsupported file",
+			wantCalls: []string{"/v2/document", "/v2/document/doc1", "/v2/document/doc1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &documentStub{t: t, statuses: tt.statuses}
+			useDeeplStub(t, stub)
+
+			got, err := translateDocument("deepl-key", []byte("1\n00:00:01,000 --> 00:00:02,000\nHello\n"), "captions.srt", "DE")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("translateDocument() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || string(got) != tt.want {
+				t.Fatalf("translateDocument() = %q, %v, want %q", got, err, tt.want)
+			}
+			if !reflect.DeepEqual(stub.calls, tt.wantCalls) {
+				t.Errorf("requests = %v, want %v", stub.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestTranslateDocumentTimeout(t *testing.T) {
+	useDeeplStub(t, &documentStub{t: t, statuses: []string{"translating"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := translateDocumentCtx(ctx, "deepl-key", []byte("Hello"), "captions.srt", "DE")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("translateDocumentCtx() error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixtureKey(t *testing.T) {
+	request := func(method string, url string, body string) *http.Request {
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		req, err := http.NewRequest(method, url, reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	conditional := func(req *http.Request) *http.Request {
+		req.Header.Set("If-None-Match", `"v1"`)
+		return req
+	}
+	const videos = "https://www.googleapis.com/youtube/v3/videos?id=dQw4w9WgXcQ&part=snippet"
+	base, _, err := fixtureKey(request("GET", videos+"&key=one", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		wantSame bool
+	}{
+		{"other API key", request("GET", videos+"&key=two", ""), true},
+		{"reordered query", request("GET", "https://www.googleapis.com/youtube/v3/videos?part=snippet&key=one&id=dQw4w9WgXcQ", ""), true},
+		{"other video", request("GET", "https://www.googleapis.com/youtube/v3/videos?id=9bZkp7q19f0&part=snippet", ""), false},
+		{"other method", request("PUT", videos, ""), false},
+		{"with a body", request("GET", videos, `{"text":["Hi"]}`), false},
+		{"conditional", conditional(request("GET", videos, "")), false},
+	}
+	for _, tt := range tests {
+		key, redactedURL, err := fixtureKey(tt.req)
+		if err != nil {
+			t.Fatalf("%s: fixtureKey() error = %v", tt.name, err)
+		}
+		if (key == base) != tt.wantSame {
+			t.Errorf("%s: same key = %v, want %v", tt.name, key == base, tt.wantSame)
+		}
+		if strings.Contains(redactedURL, "key=") {
+			t.Errorf("%s: fixture URL %q holds the API key", tt.name, redactedURL)
+		}
+	}
+
+	// The body stays readable for the request that is actually sent
+	req := request("POST", "https://api.deepl.com/v2/translate", `{"text":["Hi"]}`)
+	fixtureKey(req)
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"text":["Hi"]}` {
+		t.Errorf("body after fixtureKey() = %q", body)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("recorded " + r.URL.Query().Get("id")))
+	}))
+	dir := t.TempDir()
+	recorder := recordingDoer{HTTPDoer: server.Client(), dir: dir}
+
+	req, _ := http.NewRequest("GET", server.URL+"/youtube/v3/videos?id=dQw4w9WgXcQ&key=secret", nil)
+	resp, err := recorder.Do(req)
+	if err != nil {
+		t.Fatalf("recordingDoer.Do() error = %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "recorded dQw4w9WgXcQ" {
+		t.Errorf("recorded response body = %q, want it passed through", body)
+	}
+	server.Close()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("%s holds %d fixtures, %v, want 1", dir, len(entries), err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, entries[0].Name())); strings.Contains(string(data), "secret") {
+		t.Errorf("fixture holds the API key: %s", data)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{"recorded request with another key", server.URL + "/youtube/v3/videos?id=dQw4w9WgXcQ&key=other", ""},
+		{"unrecorded request", server.URL + "/youtube/v3/videos?id=9bZkp7q19f0", "no recorded response for GET"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		resp, err := replayDoer{dir: dir}.Do(req)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: replayDoer.Do() error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: replayDoer.Do() error = %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusTeapot || resp.Header.Get("X-Request-Id") != "abc" || string(body) != "recorded dQw4w9WgXcQ" {
+			t.Errorf("%s: replayed %d %v %q, want the recorded response", tt.name, resp.StatusCode, resp.Header, body)
+		}
+	}
+}
+
+func TestTranslateReplaysRecording(t *testing.T) {
+	recorder := useAPIStub(t)
+	dir := t.TempDir()
+
+	a, recorded, _ := newTestApp(testConfig())
+	if err := a.runTranslate([]string{"-record", dir}); err != nil {
+		t.Fatalf("translate -record error = %v", err)
+	}
+	sent := len(recorder.requests)
+
+	// Replaying must not need the network at all
+	httpClient = failingDoer{errors.New("network is down")}
+	a, replayed, _ := newTestApp(testConfig())
+	if err := a.runTranslate([]string{"-replay", dir}); err != nil {
+		t.Fatalf("translate -replay error = %v", err)
+	}
+	if replayed.String() != recorded.String() {
+		t.Errorf("replayed output = %q, want the recorded %q", replayed.String(), recorded.String())
+	}
+	if len(recorder.requests) != sent {
+		t.Errorf("replay sent %d translate requests", len(recorder.requests)-sent)
+	}
+
+	if err := a.runTranslate([]string{"-record", dir, "-replay", dir}); exitCode(err) != exitConfig {
+		t.Errorf("translate -record -replay error = %v, want a config error", err)
+	}
+}
+
+func TestReplayIgnoresETagCache(t *testing.T) {
+	var sentIfNoneMatch []string
+	recorder := &deeplRecorder{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/youtube/v3/videos", func(w http.ResponseWriter, r *http.Request) {
+		sentIfNoneMatch = append(sentIfNoneMatch, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		youtubeVideoHandler("dQw4w9WgXcQ", "Title").ServeHTTP(w, r)
+	})
+	mux.Handle("/v2/translate", recorder)
+	mux.HandleFunc("/v2/languages", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"language":"DE","name":"German"},{"language":"FR","name":"French"}]`))
+	})
+	mux.HandleFunc("/v2/usage", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DeeplUsage{CharacterCount: 1000, CharacterLimit: 500000})
+	})
+	useStubServer(t, mux)
+	dir := t.TempDir()
+
+	// A warm cache turns the next fetch into a conditional request, which
+	// the recording must not capture
+	youtubeCache = NewMemoryCache(10)
+	if _, err := fetchYouTubeVideoInfo("dQw4w9WgXcQ", "youtube-key"); err != nil {
+		t.Fatal(err)
+	}
+	sentIfNoneMatch = nil
+
+	a, recorded, _ := newTestApp(testConfig())
+	if err := a.runTranslate([]string{"-record", dir}); err != nil {
+		t.Fatalf("translate -record error = %v", err)
+	}
+	for _, sent := range sentIfNoneMatch {
+		if sent != "" {
+			t.Errorf("recorded a conditional request with If-None-Match %q", sent)
+		}
+	}
+
+	youtubeCache = NewMemoryCache(10)
+	httpClient = failingDoer{errors.New("network is down")}
+	a, replayed, _ := newTestApp(testConfig())
+	if err := a.runTranslate([]string{"-replay", dir}); err != nil {
+		t.Fatalf("translate -replay with a cold cache error = %v", err)
+	}
+	if replayed.String() != recorded.String() {
+		t.Errorf("replayed output = %q, want the recorded %q", replayed.String(), recorded.String())
+	}
+}
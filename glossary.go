@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// encodeGlossaryEntries renders entries in the tab separated format DeepL
+// expects, one "source\ttarget" pair per line, sorted by source term.
+func encodeGlossaryEntries(entries map[string]string) (string, error) {
+	sources := make([]string, 0, len(entries))
+	for source := range entries {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var b strings.Builder
+	for _, source := range sources {
+		target := entries[source]
+		if strings.TrimSpace(source) == "" || strings.TrimSpace(target) == "" {
+			return "", fmt.Errorf("glossary entry %q -> %q must not be empty", source, target)
+		}
+		if strings.ContainsAny(source, "\t\r\n") || strings.ContainsAny(target, "\t\r\n") {
+			return "", fmt.Errorf("glossary entry %q -> %q must not contain tabs or newlines", source, target)
+		}
+		b.WriteString(source)
+		b.WriteByte('\t')
+		b.WriteString(target)
+		b.WriteByte('\n')
+	}
+
+	return b.String(), nil
+}
+
+func supportsGlossary(ctx context.Context, apiKey string, sourceLang string, targetLang string) (bool, error) {
+	deepl := newDeeplTranslator(apiKey)
+	req, err := deepl.newRequest(ctx, "GET", "/v2/glossary-language-pairs", nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := deepl.Retry.do(deepl.Client, deepl.Limiter, req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, newDeeplError(resp)
+	}
+
+	var pairs struct {
+		SupportedLanguages []struct {
+			SourceLang string `json:"source_lang"`
+			TargetLang string `json:"target_lang"`
+		} `json:"supported_languages"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return false, err
+	}
+
+	// Glossaries are defined per language, not per regional variant
+	sourceLang = baseLanguage(sourceLang)
+	targetLang = baseLanguage(targetLang)
+	for _, pair := range pairs.SupportedLanguages {
+		if strings.EqualFold(pair.SourceLang, sourceLang) && strings.EqualFold(pair.TargetLang, targetLang) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// baseLanguage strips a regional variant, e.g. "EN-GB" becomes "EN".
+func baseLanguage(code string) string {
+	base, _, _ := strings.Cut(code, "-")
+	return base
+}
+
+func createGlossary(apiKey string, name string, sourceLang string, targetLang string, entries map[string]string) (string, error) {
+	return createGlossaryCtx(context.Background(), apiKey, name, sourceLang, targetLang, entries)
+}
+
+func createGlossaryCtx(ctx context.Context, apiKey string, name string, sourceLang string, targetLang string, entries map[string]string) (string, error) {
+	supported, err := supportsGlossary(ctx, apiKey, sourceLang, targetLang)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch glossary language pairs: %w", err)
+	}
+	if !supported {
+		return "", fmt.Errorf("DeepL does not support glossaries from %s to %s", sourceLang, targetLang)
+	}
+
+	tsv, err := encodeGlossaryEntries(entries)
+	if err != nil {
+		return "", err
+	}
+
+	data := map[string]interface{}{
+		"name":           name,
+		"source_lang":    baseLanguage(sourceLang),
+		"target_lang":    baseLanguage(targetLang),
+		"entries":        tsv,
+		"entries_format": "tsv",
+	}
+	requestData, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request data: %v", err)
+	}
+
+	deepl := newDeeplTranslator(apiKey)
+	req, err := deepl.newRequest(ctx, "POST", "/v2/glossaries", bytes.NewBuffer(requestData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := deepl.Retry.do(deepl.Client, deepl.Limiter, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", newDeeplError(resp)
+	}
+
+	var glossary struct {
+		GlossaryID string `json:"glossary_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&glossary); err != nil {
+		return "", fmt.Errorf("failed to parse response body: %v", err)
+	}
+
+	return glossary.GlossaryID, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEncodeGlossaryEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"sorted by source", map[string]string{"YouTube": "YouTube", "Go": "Go", "channel": "Kanal"}, "Go\tGo\nYouTube\tYouTube\nchannel\tKanal\n", false},
+		{"no entries", map[string]string{}, "", false},
+		{"empty target", map[string]string{"Go": " "}, "", true},
+		{"tab in source", map[string]string{"a\tb": "c"}, "", true},
+		{"newline in target", map[string]string{"a": "b\nc"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := encodeGlossaryEntries(tt.entries)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: encodeGlossaryEntries() = %q, %v, want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCreateGlossary(t *testing.T) {
+	var created map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/glossary-language-pairs", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"supported_languages":[{"source_lang":"en","target_lang":"de"}]}`))
+	})
+	mux.HandleFunc("/v2/glossaries", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"glossary_id":"def3a26b-3e84-45b3-84ae-0c0aaf3525f7"}`))
+	})
+	useDeeplStub(t, mux)
+
+	tests := []struct {
+		name       string
+		sourceLang string
+		targetLang string
+		wantID     string
+		wantErr    string
+	}{
+		{"supported pair", "EN", "DE", "def3a26b-3e84-45b3-84ae-0c0aaf3525f7", ""},
+		{"regional variant", "EN-GB", "DE", "def3a26b-3e84-45b3-84ae-0c0aaf3525f7", ""},
+		{"unsupported pair", "EN", "FR", "", "does not support glossaries from EN to FR"},
+	}
+	for _, tt := range tests {
+		created = nil
+		id, err := createGlossary("deepl-key", "Channel", tt.sourceLang, tt.targetLang, map[string]string{"channel": "Kanal"})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			if created != nil {
+				t.Errorf("%s: created a glossary for an unsupported pair", tt.name)
+			}
+			continue
+		}
+		if err != nil || id != tt.wantID {
+			t.Fatalf("%s: createGlossary() = %q, %v, want %q", tt.name, id, err, tt.wantID)
+		}
+		if created["entries"] != "channel\tKanal\n" || created["entries_format"] != "tsv" || created["source_lang"] != "EN" {
+			t.Errorf("%s: request = %v, want the TSV entries from EN", tt.name, created)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const googleTranslateBaseURL = "https://translation.googleapis.com/language/translate/v2"
+
+// GoogleTranslator implements Translator on top of the Google Cloud
+// Translation v2 REST API.
+type GoogleTranslator struct {
+	APIKey  string
+	BaseURL string
+	Client  HTTPDoer
+	// Retry is nil to retry as maxRetries and backoff say
+	Retry *retryPolicy
+}
+
+func newGoogleTranslator(apiKey string) *GoogleTranslator {
+	return &GoogleTranslator{APIKey: apiKey, BaseURL: googleTranslateBaseURL, Client: httpClient}
+}
+
+// Google uses lowercase ISO codes while the rest of the tool uses DeepL's
+// codes. These exceptions don't map by simply lowercasing the base language.
+var deeplToGoogleLangs = map[string]string{
+	"EN-GB":   "en",
+	"EN-US":   "en",
+	"PT-BR":   "pt",
+	"PT-PT":   "pt-PT",
+	"ZH":      "zh-CN",
+	"ZH-HANS": "zh-CN",
+	"ZH-HANT": "zh-TW",
+	"NB":      "no",
+}
+
+var googleToDeeplLangs = map[string]string{
+	"pt-PT": "PT-PT",
+	"zh-CN": "ZH",
+	"zh-TW": "ZH-HANT",
+	"zh":    "ZH",
+	"no":    "NB",
+}
+
+func toGoogleLang(code string) string {
+	if code == "" {
+		return ""
+	}
+	if google, ok := deeplToGoogleLangs[strings.ToUpper(code)]; ok {
+		return google
+	}
+	return strings.ToLower(baseLanguage(code))
+}
+
+func fromGoogleLang(code string) string {
+	if deepl, ok := googleToDeeplLangs[code]; ok {
+		return deepl
+	}
+	return strings.ToUpper(code)
+}
+
+type googleErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// GoogleError is returned when the Google Translate API answers with a
+// non-200 status.
+type GoogleError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *GoogleError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Google Translate request failed with status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("Google Translate request failed with status code: %d: %s", e.StatusCode, e.Message)
+}
+
+func googleError(resp *http.Response) error {
+	googleErr := &GoogleError{StatusCode: resp.StatusCode}
+	var body googleErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
+		googleErr.Message = body.Error.Message
+	}
+	return googleErr
+}
+
+func (t *GoogleTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	result, err := t.translate(context.Background(), text, sourceLang, targetLang)
+	return result.Text, err
+}
+
+// TranslateDetailed is Translate that also reports the detected source
+// language as a DeepL code.
+func (t *GoogleTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.translate(context.Background(), text, sourceLang, targetLang)
+}
+
+// TranslateDetailedCtx is TranslateDetailed with the request cancelled once
+// ctx is done.
+func (t *GoogleTranslator) TranslateDetailedCtx(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.translate(ctx, text, sourceLang, targetLang)
+}
+
+func (t *GoogleTranslator) translate(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	data := map[string]interface{}{
+		"q":      []string{text},
+		"target": toGoogleLang(targetLang),
+		"format": "text",
+	}
+	if sourceLang != "" {
+		data["source"] = toGoogleLang(sourceLang)
+	}
+	requestData, err := json.Marshal(data)
+	if err != nil {
+		return TranslationResult{}, fmt.Errorf("failed to marshal request data: %v", err)
+	}
+
+	endpoint := t.BaseURL + "?key=" + url.QueryEscape(t.APIKey)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(requestData))
+	if err != nil {
+		return TranslationResult{}, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.Retry.do(t.Client, nil, req)
+	if err != nil {
+		return TranslationResult{}, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return TranslationResult{}, googleError(resp)
+	}
+
+	var response struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText         string `json:"translatedText"`
+				DetectedSourceLanguage string `json:"detectedSourceLanguage"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return TranslationResult{}, fmt.Errorf("failed to parse response body: %v", err)
+	}
+
+	if len(response.Data.Translations) == 0 {
+		return TranslationResult{}, errors.New("no translations found")
+	}
+
+	translation := response.Data.Translations[0]
+	// Google bills every character of the source text
+	result := TranslationResult{Text: translation.TranslatedText, DetectedSourceLanguage: sourceLang, BilledCharacters: utf8.RuneCountInString(text)}
+	if translation.DetectedSourceLanguage != "" {
+		result.DetectedSourceLanguage = fromGoogleLang(translation.DetectedSourceLanguage)
+	}
+	return result, nil
+}
+
+func (t *GoogleTranslator) Languages() ([]DeeplLanguage, error) {
+	query := url.Values{}
+	query.Set("key", t.APIKey)
+	query.Set("target", "en")
+
+	req, err := http.NewRequest("GET", t.BaseURL+"/languages?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.Retry.do(t.Client, nil, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, googleError(resp)
+	}
+
+	var response struct {
+		Data struct {
+			Languages []struct {
+				Language string `json:"language"`
+				Name     string `json:"name"`
+			} `json:"languages"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	listed := make([]DeeplLanguage, 0, len(response.Data.Languages))
+	for _, lang := range response.Data.Languages {
+		listed = append(listed, DeeplLanguage{Code: lang.Language, Name: lang.Name})
+	}
+
+	return providerLanguages(listed, deeplToGoogleLangs, toGoogleLang, fromGoogleLang), nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGoogleLangMapping(t *testing.T) {
+	tests := []struct {
+		deepl  string
+		google string
+	}{
+		{"EN-GB", "en"},
+		{"en-us", "en"},
+		{"DE", "de"},
+		{"PT-BR", "pt"},
+		{"PT-PT", "pt-PT"},
+		{"ZH-HANT", "zh-TW"},
+		{"NB", "no"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := toGoogleLang(tt.deepl); got != tt.google {
+			t.Errorf("toGoogleLang(%q) = %q, want %q", tt.deepl, got, tt.google)
+		}
+	}
+
+	for google, deepl := range map[string]string{"de": "DE", "zh-CN": "ZH", "no": "NB", "pt-PT": "PT-PT"} {
+		if got := fromGoogleLang(google); got != deepl {
+			t.Errorf("fromGoogleLang(%q) = %q, want %q", google, got, deepl)
+		}
+	}
+}
+
+// newGoogleStub serves the translate and languages endpoints of Google
+// Translate, translating by prefixing the target language.
+func newGoogleStub(t *testing.T) *GoogleTranslator {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "google-key" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"message":"API key not valid"}}`))
+			return
+		}
+		var body struct {
+			Q      []string `json:"q"`
+			Target string   `json:"target"`
+			Source string   `json:"source"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		translation := map[string]string{"translatedText": body.Target + ":" + body.Q[0]}
+		if body.Source == "" {
+			translation["detectedSourceLanguage"] = "zh-CN"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"translations": []interface{}{translation}},
+		})
+	})
+	mux.HandleFunc("/languages", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"languages":[
+			{"language":"de","name":"German"},
+			{"language":"en","name":"English"},
+			{"language":"pt","name":"Portuguese"},
+			{"language":"pt-PT","name":"Portuguese (Portugal)"},
+			{"language":"zh-CN","name":"Chinese (Simplified)"},
+			{"language":"no","name":"Norwegian"}
+		]}}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &GoogleTranslator{APIKey: "google-key", BaseURL: server.URL, Client: server.Client()}
+}
+
+func TestGoogleTranslate(t *testing.T) {
+	translator := newGoogleStub(t)
+
+	tests := []struct {
+		sourceLang   string
+		targetLang   string
+		want         string
+		wantDetected string
+	}{
+		{"", "PT-BR", "pt:Hello", "ZH"},
+		{"EN", "EN-GB", "en:Hello", "EN"},
+		{"", "ZH-HANT", "zh-TW:Hello", "ZH"},
+	}
+	for _, tt := range tests {
+		result, err := translator.TranslateDetailed("Hello", tt.sourceLang, tt.targetLang)
+		if err != nil {
+			t.Fatalf("TranslateDetailed(%s) error = %v", tt.targetLang, err)
+		}
+		if result.Text != tt.want || result.DetectedSourceLanguage != tt.wantDetected {
+			t.Errorf("TranslateDetailed(%s) = %+v, want %q detected as %q", tt.targetLang, result, tt.want, tt.wantDetected)
+		}
+		if result.BilledCharacters != 5 {
+			t.Errorf("TranslateDetailed(%s) billed %d characters, want 5", tt.targetLang, result.BilledCharacters)
+		}
+	}
+}
+
+func TestGoogleTranslateError(t *testing.T) {
+	translator := newGoogleStub(t)
+	translator.APIKey = "wrong"
+
+	_, err := translator.Translate("Hello", "", "DE")
+	var googleErr *GoogleError
+	if !errors.As(err, &googleErr) || googleErr.StatusCode != http.StatusForbidden || googleErr.Message != "API key not valid" {
+		t.Fatalf("Translate() error = %v, want a GoogleError with the API message", err)
+	}
+}
+
+func TestGoogleLanguagesAcceptMappedTargets(t *testing.T) {
+	languages, err := newGoogleStub(t).Languages()
+	if err != nil {
+		t.Fatalf("Languages() error = %v", err)
+	}
+
+	// Everything toGoogleLang sends as a listed language must validate
+	accepted := []string{"DE", "EN", "EN-GB", "EN-US", "PT", "PT-BR", "PT-PT", "ZH", "ZH-HANS", "NB"}
+	if err := validateTargetLangs(accepted, languages); err != nil {
+		t.Errorf("validateTargetLangs() error = %v", err)
+	}
+	for _, target := range accepted {
+		if toGoogleLang(target) == "" {
+			t.Errorf("%s maps to no Google code", target)
+		}
+	}
+
+	// Traditional Chinese maps to zh-TW, which the stub doesn't list
+	if err := validateTargetLangs([]string{"ZH-HANT"}, languages); err == nil {
+		t.Error("validateTargetLangs(ZH-HANT) succeeded, want an error")
+	}
+}
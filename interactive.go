@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// promptLine writes prompt to w and reads one line from r. Running out of
+// input is an error, so a closed stdin never loops.
+func promptLine(r *bufio.Reader, w io.Writer, prompt string) (string, error) {
+	fmt.Fprint(w, prompt)
+	line, err := r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("no input, stdin was closed")
+		}
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// promptMissing asks for the video and the target languages when config
+// has none, repeating a question until the answer is valid.
+func promptMissing(r io.Reader, w io.Writer, config *Config) error {
+	in := bufio.NewReader(r)
+
+	if config.YoutubeVideoId == "" && config.PlaylistId == "" && config.ChannelId == "" && len(config.Jobs) == 0 {
+		for {
+			answer, err := promptLine(in, w, "YouTube video ID or URL: ")
+			if err != nil {
+				return err
+			}
+			id, err := extractVideoID(answer)
+			if err != nil {
+				fmt.Fprintln(w, err)
+				continue
+			}
+			config.YoutubeVideoId = id
+			break
+		}
+	}
+
+	if len(config.TargetLangs) == 0 {
+		for {
+			answer, err := promptLine(in, w, "Target languages, comma separated: ")
+			if err != nil {
+				return err
+			}
+			targets, err := parseTargetLangs(answer)
+			if err != nil {
+				fmt.Fprintln(w, err)
+				continue
+			}
+			config.TargetLangs = targets
+			break
+		}
+	}
+
+	return nil
+}
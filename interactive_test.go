@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPromptMissing(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		input       string
+		wantVideo   string
+		wantTargets []string
+		wantPrompts int
+	}{
+		{
+			name:        "asks for everything",
+			input:       "https://youtu.be/dQw4w9WgXcQ\nde, fr\n",
+			wantVideo:   "dQw4w9WgXcQ",
+			wantTargets: []string{"DE", "FR"},
+			wantPrompts: 2,
+		},
+		{
+			name:        "repeats invalid answers",
+			input:       "not a video\ndQw4w9WgXcQ\nde,,fr\nDE\n",
+			wantVideo:   "dQw4w9WgXcQ",
+			wantTargets: []string{"DE"},
+			wantPrompts: 4,
+		},
+		{
+			name:        "channel needs no video",
+			config:      Config{ChannelId: "UC123"},
+			input:       "DE\n",
+			wantTargets: []string{"DE"},
+			wantPrompts: 1,
+		},
+		{
+			name:        "playlist needs no video",
+			config:      Config{PlaylistId: "PL123"},
+			input:       "DE\n",
+			wantTargets: []string{"DE"},
+			wantPrompts: 1,
+		},
+		{
+			name:        "nothing missing",
+			config:      Config{YoutubeVideoId: "dQw4w9WgXcQ", TargetLangs: []string{"DE"}},
+			wantVideo:   "dQw4w9WgXcQ",
+			wantTargets: []string{"DE"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			var out bytes.Buffer
+			if err := promptMissing(strings.NewReader(tt.input), &out, &config); err != nil {
+				t.Fatalf("promptMissing() error = %v", err)
+			}
+			if config.YoutubeVideoId != tt.wantVideo {
+				t.Errorf("video = %q, want %q", config.YoutubeVideoId, tt.wantVideo)
+			}
+			if !reflect.DeepEqual(config.TargetLangs, tt.wantTargets) {
+				t.Errorf("targets = %v, want %v", config.TargetLangs, tt.wantTargets)
+			}
+			if prompts := strings.Count(out.String(), ": "); prompts != tt.wantPrompts {
+				t.Errorf("asked %d times, want %d: %q", prompts, tt.wantPrompts, out.String())
+			}
+		})
+	}
+}
+
+func TestPromptMissingClosedStdin(t *testing.T) {
+	var config Config
+	if err := promptMissing(strings.NewReader(""), &bytes.Buffer{}, &config); err == nil {
+		t.Fatal("promptMissing() succeeded on closed stdin, want an error")
+	}
+}
+
+func TestTranslateInteractive(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantErr    bool
+		wantTarget string
+	}{
+		{"prompted", []string{"-interactive"}, "https://youtu.be/dQw4w9WgXcQ\n fr \n", false, "FR"},
+		{"stdin closed", []string{"-interactive"}, "dQw4w9WgXcQ\n", true, ""},
+		{"not interactive", nil, "dQw4w9WgXcQ\nFR\n", true, ""},
+	}
+	for _, tt := range tests {
+		recorder := useAPIStub(t)
+		config := testConfig()
+		config.YoutubeVideoId, config.TargetLangs = "", nil
+		a, _, _ := newTestApp(config)
+		a.stdin = strings.NewReader(tt.stdin)
+
+		err := a.runTranslate(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: translate error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			if len(recorder.requests) != 0 {
+				t.Errorf("%s: sent %d translate requests", tt.name, len(recorder.requests))
+			}
+			continue
+		}
+		if len(recorder.requests) == 0 || recorder.last()["target_lang"] != tt.wantTarget {
+			t.Errorf("%s: translated into %v, want %s", tt.name, recorder.last()["target_lang"], tt.wantTarget)
+		}
+	}
+}
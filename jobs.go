@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// BatchJob is one entry of the jobs list in the config. Jobs without target
+// languages use the top level target_langs.
+type BatchJob struct {
+	Video       string   `json:"video"`
+	TargetLangs []string `json:"target_langs"`
+}
+
+// jobOutcome is what running a single job produced, Err is nil on success.
+type jobOutcome struct {
+	Job    BatchJob
+	Result TranslatedVideo
+	Err    error
+}
+
+// jobTargets returns the target languages of job, falling back to defaults.
+func jobTargets(job BatchJob, defaults []string) []string {
+	if len(job.TargetLangs) > 0 {
+		return job.TargetLangs
+	}
+	return defaults
+}
+
+// translateJobs runs every job in turn. A failing job doesn't stop the
+// others, except when a translation or YouTube quota is used up, after which
+// the remaining jobs fail with ctx's error or are left out.
+func translateJobs(ctx context.Context, jobs []BatchJob, fetch func(ctx context.Context, videoID string) (YouTubeVideo, error), t Translator, defaultTargets []string, opts translateVideoOptions) []jobOutcome {
+	outcomes := make([]jobOutcome, 0, len(jobs))
+
+	for _, job := range jobs {
+		if err := ctx.Err(); err != nil {
+			outcomes = append(outcomes, jobOutcome{Job: job, Err: err})
+			continue
+		}
+
+		outcome := jobOutcome{Job: job}
+		video, err := fetch(ctx, job.Video)
+		if err != nil {
+			outcome.Err = err
+		} else {
+			outcome.Result, outcome.Err = translateVideo(ctx, video, t, jobTargets(job, defaultTargets), opts)
+		}
+		outcomes = append(outcomes, outcome)
+
+		if errors.Is(outcome.Err, ErrQuotaExceeded) || errors.Is(outcome.Err, ErrYouTubeQuotaExceeded) {
+			break
+		}
+	}
+
+	return outcomes
+}
+
+// summarizeJobs logs how the batch went and returns the combined error of the
+// failed jobs.
+func summarizeJobs(outcomes []jobOutcome, total int) error {
+	var errs []error
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			slog.Error("job failed", "video", outcome.Job.Video, "error", outcome.Err)
+			errs = append(errs, fmt.Errorf("video %s: %w", outcome.Job.Video, outcome.Err))
+		}
+	}
+	skipped := total - len(outcomes)
+	slog.Info("batch finished", "succeeded", len(outcomes)-len(errs), "failed", len(errs), "skipped", skipped)
+
+	if len(errs) == 0 && skipped == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d jobs failed or were skipped: %w", len(errs)+skipped, total, errors.Join(errs...))
+}
+
+// runJobs handles the translate command for a config with a jobs list.
+func (a *app) runJobs(ctx context.Context, config Config, translator Translator, opts translateOptions, fields videoFields, state *runState) error {
+	fetch := func(ctx context.Context, videoID string) (YouTubeVideo, error) {
+		return fetchYouTubeVideoInfoCtx(ctx, videoID, config.YoutubeApiKey)
+	}
+
+	if opts.dryRun {
+		cost := 0
+		for _, job := range config.Jobs {
+			video, err := fetch(ctx, job.Video)
+			if err != nil {
+				return err
+			}
+			targets := jobTargets(job, config.TargetLangs)
+			fmt.Fprintln(a.stdout, "Video:", video.ID)
+			fmt.Fprintln(a.stdout, "Title:", video.Title)
+			fmt.Fprintln(a.stdout, "Targets:", strings.Join(targets, ", "))
+			cost += estimateCost(videoTexts([]YouTubeVideo{video}, fields), targets)
+		}
+		fmt.Fprintf(a.stdout, "Dry run: %d characters in total for %d jobs\n", cost, len(config.Jobs))
+		return nil
+	}
+
+	videoOpts := translateVideoOptions{
+		SourceLang:            config.SourceLang,
+		TitleSourceLang:       opts.titleSource,
+		DescriptionSourceLang: opts.descSource,
+		Concurrency:           config.MaxConcurrency,
+		Fields:                fields,
+		State:                 state,
+		Verify:                opts.verify,
+		Truncate:              opts.truncate,
+		TitleMax:              opts.titleMax,
+	}
+	if opts.titleMax > 0 {
+		videoOpts.ShortTitles = newTitleHintTranslator(config, opts.titleMax)
+	}
+	if opts.progress {
+		total := 0
+		for _, job := range config.Jobs {
+			total += len(jobTargets(job, config.TargetLangs))
+		}
+		videoOpts.Progress = newProgressPrinter(a.stderr, total).report
+	}
+	outcomes := translateJobs(ctx, config.Jobs, fetch, translator, config.TargetLangs, videoOpts)
+
+	results := make([]TranslatedVideo, 0, len(outcomes))
+	for _, outcome := range outcomes {
+		if outcome.Result.VideoID != "" {
+			results = append(results, outcome.Result)
+		}
+	}
+	logSummary(billedCharacters(results))
+
+	if err := a.writeOutput(config, opts, results, true); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return summarizeJobs(outcomes, len(config.Jobs))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTranslateJobs(t *testing.T) {
+	videos := map[string]YouTubeVideo{
+		"video1": {ID: "video1", Title: "One"},
+		"video3": {ID: "video3", Title: "Three"},
+	}
+	fetch := func(ctx context.Context, videoID string) (YouTubeVideo, error) {
+		if video, ok := videos[videoID]; ok {
+			return video, nil
+		}
+		return YouTubeVideo{}, &YouTubeError{StatusCode: 404}
+	}
+	translator := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		return targetLang + ":" + text, nil
+	}}
+	jobs := []BatchJob{
+		{Video: "video1", TargetLangs: []string{"FR"}},
+		{Video: "missing"},
+		{Video: "video3"},
+	}
+
+	outcomes := translateJobs(context.Background(), jobs, fetch, translator, []string{"DE", "JA"}, translateVideoOptions{})
+	if len(outcomes) != len(jobs) {
+		t.Fatalf("translateJobs() returned %d outcomes, want %d", len(outcomes), len(jobs))
+	}
+
+	tests := []struct {
+		wantErr   error
+		wantTitle map[string]string
+	}{
+		{nil, map[string]string{"FR": "FR:One"}},
+		{ErrYouTubeNotFound, nil},
+		{nil, map[string]string{"DE": "DE:Three", "JA": "JA:Three"}},
+	}
+	for i, tt := range tests {
+		outcome := outcomes[i]
+		if tt.wantErr != nil {
+			if !errors.Is(outcome.Err, tt.wantErr) {
+				t.Errorf("job %d error = %v, want %v", i, outcome.Err, tt.wantErr)
+			}
+			continue
+		}
+		if outcome.Err != nil {
+			t.Fatalf("job %d error = %v", i, outcome.Err)
+		}
+		if len(outcome.Result.Translations) != len(tt.wantTitle) {
+			t.Errorf("job %d translated into %d languages, want %d", i, len(outcome.Result.Translations), len(tt.wantTitle))
+		}
+		for lang, title := range tt.wantTitle {
+			if got := outcome.Result.Translations[lang].Title; got != title {
+				t.Errorf("job %d %s title = %q, want %q", i, lang, got, title)
+			}
+		}
+	}
+
+	err := summarizeJobs(outcomes, len(jobs))
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 jobs") || !strings.Contains(err.Error(), "video missing") {
+		t.Errorf("summarizeJobs() error = %v, want one naming the failed job", err)
+	}
+	if err := summarizeJobs([]jobOutcome{outcomes[0]}, 1); err != nil {
+		t.Errorf("summarizeJobs() of a successful batch error = %v", err)
+	}
+}
+
+func TestTranslateJobsStopOnQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		fetchErr error
+		transErr error
+	}{
+		{"youtube quota", &YouTubeError{StatusCode: 403, Reason: "quotaExceeded"}, nil},
+		{"deepl quota", nil, &DeeplError{StatusCode: deeplStatusQuotaExceeded}},
+	}
+	for _, tt := range tests {
+		fetches := 0
+		fetch := func(ctx context.Context, videoID string) (YouTubeVideo, error) {
+			fetches++
+			return YouTubeVideo{ID: videoID, Title: "Title"}, tt.fetchErr
+		}
+		translator := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+			return "", tt.transErr
+		}}
+		jobs := []BatchJob{{Video: "a"}, {Video: "b"}, {Video: "c"}}
+
+		outcomes := translateJobs(context.Background(), jobs, fetch, translator, []string{"DE"}, translateVideoOptions{})
+		if len(outcomes) != 1 || fetches != 1 {
+			t.Errorf("%s: ran %d jobs, want to stop after the first", tt.name, fetches)
+		}
+		if err := summarizeJobs(outcomes, len(jobs)); err == nil || !strings.Contains(err.Error(), "3 of 3 jobs") {
+			t.Errorf("%s: summarizeJobs() error = %v, want the skipped jobs counted", tt.name, err)
+		}
+	}
+}
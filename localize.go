@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// localizeSettings builds the settings of the requests Localize sends. Tests
+// send them to a stub server.
+var localizeSettings = newAPISettings
+
+// Localize fetches the video cfg.YoutubeVideoId and translates its title
+// and description into targets. The result is returned instead of printed or
+// written to an output file. cfg gets the same defaults as a config file,
+// and its HTTP, retry and rate limit settings apply to every request, not
+// the ones a command set up. Logs go to the default slog logger, and the
+// disk cache backend keeps translations and videos under cfg.CacheDir. Like
+// translateVideo, languages that fail are missing from the result and
+// reported in the error.
+func Localize(ctx context.Context, cfg Config, targets []string) (TranslatedVideo, error) {
+	cfg = normalizeConfig(cfg)
+	if cfg.YoutubeVideoId == "" {
+		return TranslatedVideo{}, &configError{errors.New("missing required config value youtube_video_id (YOUTUBE_VIDEO_ID)")}
+	}
+	if err := cfg.Validate(); err != nil {
+		return TranslatedVideo{}, &configError{fmt.Errorf("invalid config: %w", err)}
+	}
+	if len(targets) == 0 {
+		return TranslatedVideo{}, &configError{errors.New("missing target languages")}
+	}
+	targets, err := parseTargetLangs(strings.Join(targets, ","))
+	if err != nil {
+		return TranslatedVideo{}, &configError{fmt.Errorf("invalid target languages: %w", err)}
+	}
+
+	settings, err := localizeSettings(cfg)
+	if err != nil {
+		return TranslatedVideo{}, &configError{err}
+	}
+	translator, err := newTranslatorWith(cfg, settings, newCache(cfg, cfg.Provider))
+	if err != nil {
+		return TranslatedVideo{}, err
+	}
+
+	opts := translateVideoOptions{
+		SourceLang:  cfg.SourceLang,
+		Concurrency: cfg.MaxConcurrency,
+	}
+	return localizeVideo(ctx, cfg, settings, translator, targets, opts)
+}
+
+// localizeVideo is Localize with the settings, the translator and the
+// options already set up, e.g. by a command. Failing to fetch the video is
+// returned as is, a failed translation comes with what did get translated.
+func localizeVideo(ctx context.Context, cfg Config, settings apiSettings, translator Translator, targets []string, opts translateVideoOptions) (TranslatedVideo, error) {
+	video, err := fetchYouTubeVideoInfoWith(ctx, settings, cfg.YoutubeVideoId, cfg.YoutubeApiKey, "")
+	if err != nil {
+		return TranslatedVideo{}, err
+	}
+
+	translated, err := translateVideo(ctx, video, translator, targets, opts)
+	if err != nil {
+		return translated, fmt.Errorf("failed to translate video: %w", err)
+	}
+	return translated, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"unicode/utf8"
+)
+
+// localizeStub serves a video with description and translates on the DeepL
+// endpoint by prefixing the target language, failing every text sent for
+// failLang. It reports the longest text DeepL was sent into longest.
+func localizeStub(t *testing.T, description string, failLang string, longest *int) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/youtube/v3/videos", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{
+				"id":      r.URL.Query().Get("id"),
+				"snippet": map[string]string{"title": "Title", "description": description},
+			}},
+		})
+	})
+	mux.HandleFunc("/v2/translate", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text       []string `json:"text"`
+			TargetLang string   `json:"target_lang"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if body.TargetLang == failLang {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"Value for 'target_lang' not supported."}`))
+			return
+		}
+		var translations []map[string]string
+		for _, text := range body.Text {
+			if n := utf8.RuneCountInString(text); n > *longest {
+				*longest = n
+			}
+			translations = append(translations, map[string]string{"text": body.TargetLang + ":" + text, "detected_source_language": "EN"})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"translations": translations})
+	})
+	return mux
+}
+
+// useLocalizeStub sends the requests of Localize to handler, through the
+// client Localize builds from its config.
+func useLocalizeStub(t *testing.T, handler http.Handler) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := localizeSettings
+	localizeSettings = func(cfg Config) (apiSettings, error) {
+		settings, err := newAPISettings(cfg)
+		settings.Client = rewriteDoer{target: target, client: settings.Client}
+		return settings, err
+	}
+	t.Cleanup(func() {
+		localizeSettings = previous
+		server.Close()
+	})
+}
+
+func TestLocalize(t *testing.T) {
+	sentence := "This sentence is part of a long description. "
+	long := strings.Repeat(sentence, 2*defaultMaxChunkChars/len(sentence))
+
+	tests := []struct {
+		name        string
+		video       string
+		description string
+		targets     []string
+		failLang    string
+		wantLangs   []string
+		wantErr     bool
+	}{
+		{"video id", "dQw4w9WgXcQ", "Description", []string{"DE", "FR"}, "", []string{"DE", "FR"}, false},
+		{"pasted url", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", "Description", []string{"de"}, "", []string{"DE"}, false},
+		{"chunked by default", "dQw4w9WgXcQ", long, []string{"DE"}, "", []string{"DE"}, false},
+		{"failed language", "dQw4w9WgXcQ", "Description", []string{"DE", "XX"}, "XX", []string{"DE"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var longest int
+			useLocalizeStub(t, localizeStub(t, tt.description, tt.failLang, &longest))
+			cfg := Config{
+				DeeplApiKey:    "deepl-key",
+				YoutubeApiKey:  "youtube-key",
+				YoutubeVideoId: tt.video,
+				CacheDir:       t.TempDir(),
+			}
+
+			translated, err := Localize(context.Background(), cfg, tt.targets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Localize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if translated.VideoID != "dQw4w9WgXcQ" {
+				t.Errorf("VideoID = %q, want dQw4w9WgXcQ", translated.VideoID)
+			}
+			if len(translated.Translations) != len(tt.wantLangs) {
+				t.Errorf("translated into %d languages, want %v", len(translated.Translations), tt.wantLangs)
+			}
+			for _, lang := range tt.wantLangs {
+				if got := translated.Translations[lang].Title; got != lang+":Title" {
+					t.Errorf("%s title = %q, want %s:Title", lang, got, lang)
+				}
+			}
+			if longest > defaultMaxChunkChars {
+				t.Errorf("sent a %d character text, want at most %d", longest, defaultMaxChunkChars)
+			}
+		})
+	}
+}
+
+func TestLocalizeUsesConfig(t *testing.T) {
+	// What run set up for the commands must not matter
+	previousClient, previousRetries := httpClient, maxRetries
+	httpClient, maxRetries = failingDoer{errors.New("global client used")}, 0
+	defer func() { httpClient, maxRetries = previousClient, previousRetries }()
+
+	var longest int
+	var failed atomic.Bool
+	stub := localizeStub(t, "Description", "", &longest)
+	useLocalizeStub(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("User-Agent"); got != "embedder/1.0" {
+			t.Errorf("%s sent User-Agent %q, want the configured one", r.URL.Path, got)
+		}
+		if r.URL.Path == "/custom/v2/translate" && failed.CompareAndSwap(false, true) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/custom")
+		stub.ServeHTTP(w, r)
+	}))
+
+	retries := 1
+	cfg := Config{
+		DeeplApiKey:    "deepl-key",
+		DeeplBaseURL:   "https://deepl.example/custom",
+		YoutubeApiKey:  "youtube-key",
+		YoutubeVideoId: "dQw4w9WgXcQ",
+		CacheBackend:   cacheBackendNone,
+		MaxRetries:     &retries,
+		UserAgent:      "embedder/1.0",
+	}
+	translated, err := Localize(context.Background(), cfg, []string{"DE"})
+	if err != nil {
+		t.Fatalf("Localize() error = %v", err)
+	}
+	if !failed.Load() || translated.Translations["DE"].Title != "DE:Title" {
+		t.Errorf("Localize() = %+v, want DE translated after a retry at the configured base URL", translated)
+	}
+}
+
+func TestLocalizeConfigErrors(t *testing.T) {
+	valid := Config{DeeplApiKey: "deepl-key", YoutubeApiKey: "youtube-key", YoutubeVideoId: "dQw4w9WgXcQ"}
+
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		targets []string
+	}{
+		{"missing video", func(c *Config) { c.YoutubeVideoId = "" }, []string{"DE"}},
+		{"broken video", func(c *Config) { c.YoutubeVideoId = "not a video" }, []string{"DE"}},
+		{"missing key", func(c *Config) { c.DeeplApiKey = "" }, []string{"DE"}},
+		{"no targets", func(c *Config) {}, nil},
+		{"invalid target", func(c *Config) {}, []string{"DE", "not a language"}},
+	}
+	for _, tt := range tests {
+		cfg := valid
+		tt.change(&cfg)
+		_, err := Localize(context.Background(), cfg, tt.targets)
+		var configErr *configError
+		if !errors.As(err, &configErr) {
+			t.Errorf("%s: Localize() error = %v, want a configError", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const defaultLogLevel = "info"
+
+// redactKey masks all but the last four characters of a credential so it can
+// be recognised in diagnostics without being usable. Keys of four characters
+// or less are masked completely.
+func redactKey(s string) string {
+	runes := []rune(s)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:])
+}
+
+func parseLogLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(value))
+	return level, err
+}
+
+// newLogger returns a text logger writing to w. Every occurrence of a secret
+// in the message, in string attributes or in logged errors is redacted,
+// whatever the level.
+func newLogger(w io.Writer, level slog.Level, secrets []string) *slog.Logger {
+	var replacements []string
+	for _, secret := range secrets {
+		if secret != "" {
+			replacements = append(replacements, secret, redactKey(secret))
+		}
+	}
+	redactor := strings.NewReplacer(replacements...)
+
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(replacements) == 0 {
+				return attr
+			}
+			switch attr.Value.Kind() {
+			case slog.KindString:
+				attr.Value = slog.StringValue(redactor.Replace(attr.Value.String()))
+			case slog.KindAny:
+				// Errors often quote request URLs, keys included
+				switch value := attr.Value.Any().(type) {
+				case error:
+					attr.Value = slog.StringValue(redactor.Replace(value.Error()))
+				case fmt.Stringer:
+					attr.Value = slog.StringValue(redactor.Replace(value.String()))
+				}
+			}
+			return attr
+		},
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// configSecrets lists every credential in config that must never be logged.
+func configSecrets(config Config) []string {
+	return []string{
+		config.DeeplApiKey,
+		config.GoogleApiKey,
+		config.AzureApiKey,
+		config.YoutubeApiKey,
+		config.OAuthToken,
+		config.OAuthRefreshToken,
+		config.OAuthClientSecret,
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"", ""},
+		{"abc", "***"},
+		{"abcd", "****"},
+		{"abcdefgh", "****efgh"},
+		{strings.Repeat("k", 36) + "1234", strings.Repeat("*", 36) + "1234"},
+	}
+	for _, tt := range tests {
+		if got := redactKey(tt.key); got != tt.want {
+			t.Errorf("redactKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+type stringerValue string
+
+func (s stringerValue) String() string { return string(s) }
+
+func TestNewLoggerRedactsSecrets(t *testing.T) {
+	const secret = "AIzaSyTopSecretKey"
+	urlErr := &url.Error{Op: "Get", URL: "https://www.googleapis.com/youtube/v3/videos?id=x&key=" + secret, Err: errors.New("dial tcp: timeout")}
+
+	tests := []struct {
+		name  string
+		attrs []any
+	}{
+		{"message", nil},
+		{"string attribute", []any{"key", secret}},
+		{"error", []any{"error", errors.New("bad key " + secret)}},
+		{"wrapped url error", []any{"error", fmt.Errorf("failed to fetch video information: %w", urlErr)}},
+		{"stringer", []any{"value", stringerValue("key=" + secret)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := newLogger(&buf, slog.LevelDebug, []string{secret, ""})
+			logger.Info("using "+secret, tt.attrs...)
+
+			if strings.Contains(buf.String(), secret) {
+				t.Fatalf("log output holds the secret: %s", buf.String())
+			}
+			if !strings.Contains(buf.String(), redactKey(secret)) {
+				t.Errorf("log output lacks the redacted secret: %s", buf.String())
+			}
+		})
+	}
+}
+
+type failingDoer struct {
+	err error
+}
+
+func (d failingDoer) Do(req *http.Request) (*http.Response, error) {
+	return nil, &url.Error{Op: req.Method, URL: req.URL.String(), Err: d.err}
+}
+
+func TestRedactingDoerStripsQuery(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://www.googleapis.com/youtube/v3/videos?id=x&key=secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = redactingDoer{failingDoer{errors.New("timeout")}}.Do(req)
+	if err == nil {
+		t.Fatal("Do() succeeded, want an error")
+	}
+	if strings.Contains(err.Error(), "secret") || strings.Contains(err.Error(), "?") {
+		t.Errorf("Do() error = %q, want the query string stripped", err)
+	}
+	if !strings.Contains(err.Error(), "/youtube/v3/videos") {
+		t.Errorf("Do() error = %q, want the URL path kept", err)
+	}
+}
+
+func TestNewLoggerLevel(t *testing.T) {
+	tests := []struct {
+		level     string
+		wantDebug bool
+		wantInfo  bool
+		wantErr   bool
+	}{
+		{"debug", true, true, false},
+		{"info", false, true, false},
+		{"WARN", false, false, false},
+		{"error", false, false, false},
+		{"loud", false, false, true},
+	}
+	for _, tt := range tests {
+		level, err := parseLogLevel(tt.level)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLogLevel(%q) error = %v, wantErr %v", tt.level, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		var buf bytes.Buffer
+		logger := newLogger(&buf, level, nil)
+		logger.Debug("debug record")
+		logger.Info("info record")
+		if got := strings.Contains(buf.String(), "debug record"); got != tt.wantDebug {
+			t.Errorf("level %s logged debug = %v, want %v", tt.level, got, tt.wantDebug)
+		}
+		if got := strings.Contains(buf.String(), "info record"); got != tt.wantInfo {
+			t.Errorf("level %s logged info = %v, want %v", tt.level, got, tt.wantInfo)
+		}
+	}
+}
@@ -1,218 +1,543 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
-type Config struct {
-	DeeplApiKey    string `json:"deepl_api_key"`
-	YoutubeApiKey  string `json:"youtube_api_key"`
-	YoutubeVideoId string `json:"youtube_video_id"`
+const defaultRequestTimeoutSeconds = 30
+
+// HTTPDoer sends HTTP requests. *http.Client implements it, tests can pass
+// a fake that records requests and returns canned responses.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// version is reported in the User-Agent header. Release builds set it with
+// -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
+func defaultUserAgent() string {
+	return "go-translate-youtube/" + version
+}
+
+// userAgentDoer sets the User-Agent header on every request that has none.
+type userAgentDoer struct {
+	HTTPDoer
+	userAgent string
+}
+
+func (d userAgentDoer) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", d.userAgent)
+	}
+	return d.HTTPDoer.Do(req)
+}
+
+// redactingDoer strips the query string from the URL of transport errors.
+// YouTube and Google take the API key as a query parameter, which would
+// otherwise end up in every logged error.
+type redactingDoer struct {
+	HTTPDoer
 }
 
-type TranslationResponse struct {
-	Translations []struct {
-		DetectedSourceLanguage string `json:"detected_source_language"`
-		Text                   string `json:"text"`
-	} `json:"translations"`
+func (d redactingDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.HTTPDoer.Do(req)
+	return resp, redactURLError(err)
 }
 
-type DeeplLanguage struct {
-	Code string `json:"language"`
-	Name string `json:"name"`
+// redactURLError drops the query string of the *url.Error in err, if any.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil && u.RawQuery != "" {
+			u.RawQuery = ""
+			urlErr.URL = u.String()
+		}
+	}
+	return err
 }
 
-type DeeplLanguagesResponse struct {
-	LanguageList []struct {
-		Code string `json:"language"`
-		Name string `json:"name"`
-	} `json:"languages"`
+// httpClient is shared by every API call. main replaces it with one built
+// from the loaded config.
+var httpClient HTTPDoer = userAgentDoer{
+	HTTPDoer:  redactingDoer{&http.Client{Timeout: defaultRequestTimeoutSeconds * time.Second}},
+	userAgent: defaultUserAgent(),
 }
 
-type YouTubeVideo struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
+type Config struct {
+	// Version is the schema of the file, loadConfig migrates older ones
+	Version int `json:"version"`
+
+	Provider       string   `json:"provider"`
+	DeeplApiKey    string   `json:"deepl_api_key"`
+	DeeplBaseURL   string   `json:"deepl_base_url"`
+	GoogleApiKey   string   `json:"google_api_key"`
+	AzureApiKey    string   `json:"azure_api_key"`
+	AzureRegion    string   `json:"azure_region"`
+	YoutubeApiKey  string   `json:"youtube_api_key"`
+	YoutubeVideoId string   `json:"youtube_video_id"`
+	PlaylistId     string   `json:"youtube_playlist_id"`
+	ChannelId      string   `json:"youtube_channel_id"`
+	TargetLangs    []string `json:"target_langs"`
+	OutputPath     string   `json:"output_path"`
+	Formality      string   `json:"formality"`
+	GlossaryID     string   `json:"glossary_id"`
+	SourceLang     string   `json:"source_lang"`
+	TagHandling    string   `json:"tag_handling"`
+	IgnoreTags     []string `json:"ignore_tags"`
+	SplittingTags  []string `json:"splitting_tags"`
+	// OutlineDetection is only sent when set, DeepL infers the structure of
+	// XML unless it is false
+	OutlineDetection *bool `json:"outline_detection"`
+
+	// Jobs translates several videos, each into its own target languages
+	Jobs []BatchJob `json:"jobs"`
+
+	PreserveFormatting bool   `json:"preserve_formatting"`
+	SplitSentences     string `json:"split_sentences"`
+	// PreserveEmoji keeps emoji out of the translation, DeepL sometimes
+	// drops or reorders them
+	PreserveEmoji bool `json:"preserve_emoji"`
+
+	OAuthToken        string `json:"oauth_token"`
+	OAuthRefreshToken string `json:"oauth_refresh_token"`
+	OAuthClientID     string `json:"oauth_client_id"`
+	OAuthClientSecret string `json:"oauth_client_secret"`
+
+	RequestTimeoutSeconds int `json:"request_timeout_seconds"`
+	// MaxRetries is how often a failed request is retried, 3 when unset. 0
+	// turns retries off.
+	MaxRetries     *int `json:"max_retries"`
+	MaxChunkChars  int  `json:"max_chunk_chars"`
+	MaxConcurrency int  `json:"max_concurrency"`
+
+	MaxRequestsPerSecond float64 `json:"max_requests_per_second"`
+	BatchDeadlineSeconds int     `json:"batch_deadline_seconds"`
+	// RetryJitter is the share of the backoff delay added as random jitter,
+	// 0.5 when unset. 0 turns the jitter off.
+	RetryJitter *float64 `json:"retry_jitter"`
+
+	// StatePath is where translate records the videos and languages it
+	// finished, later runs skip them
+	StatePath string `json:"state_path"`
+
+	CacheDir string `json:"cache_dir"`
+	// CacheBackend is "disk", the default, to keep translations and video
+	// metadata under cache_dir between runs, "memory" to keep the cache_size
+	// most recently used entries for the current run only, or "none".
+	CacheBackend string `json:"cache_backend"`
+	CacheSize    int    `json:"cache_size"`
+
+	LogLevel  string `json:"log_level"`
+	UserAgent string `json:"user_agent"`
+	HTTPProxy string `json:"http_proxy"`
 }
 
+// loadConfig reads filename and applies environment overrides on top of it.
+// A missing file is not an error, Validate reports what is still missing.
 func loadConfig(filename string) (Config, error) {
 	var config Config
 
 	configFile, err := os.ReadFile(filename)
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return config, err
 	}
 
-	err = json.Unmarshal(configFile, &config)
-	if err != nil {
-		return config, err
+	if err == nil {
+		configFile, err = migrateConfig(configFile)
+		if err != nil {
+			return config, err
+		}
+		err = json.Unmarshal(configFile, &config)
+		if err != nil {
+			return config, err
+		}
 	}
 
-	return config, nil
-}
+	applyEnvOverrides(&config)
 
-func fetchYouTubeVideoInfo(videoID string, apiKey string) (YouTubeVideo, error) {
-	url := fmt.Sprintf("https://www.googleapis.com/youtube/v3/videos?id=%s&key=%s&part=snippet", videoID, apiKey)
+	return normalizeConfig(config), nil
+}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return YouTubeVideo{}, err
+// normalizeConfig accepts pasted video URLs and fills in the defaults of
+// unset values, for configs read from a file as well as built in code.
+func normalizeConfig(config Config) Config {
+	// Accept pasted video URLs, Validate reports values that aren't either
+	if id, err := extractVideoID(config.YoutubeVideoId); err == nil {
+		config.YoutubeVideoId = id
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return YouTubeVideo{}, fmt.Errorf("failed to fetch video information, status code: %d", resp.StatusCode)
+	// The jobs may belong to the caller
+	config.Jobs = append([]BatchJob(nil), config.Jobs...)
+	for i, job := range config.Jobs {
+		if id, err := extractVideoID(job.Video); err == nil {
+			config.Jobs[i].Video = id
+		}
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return YouTubeVideo{}, err
+	if config.Provider == "" {
+		config.Provider = providerDeepl
 	}
 
-	var response struct {
-		Items []struct {
-			Snippet struct {
-				Title       string `json:"title"`
-				Description string `json:"description"`
-			} `json:"snippet"`
-		} `json:"items"`
+	if config.RequestTimeoutSeconds == 0 {
+		config.RequestTimeoutSeconds = defaultRequestTimeoutSeconds
 	}
-
-	if err := json.Unmarshal(body, &response); err != nil {
-		return YouTubeVideo{}, err
+	if config.MaxChunkChars == 0 {
+		config.MaxChunkChars = defaultMaxChunkChars
 	}
-
-	if len(response.Items) == 0 {
-		return YouTubeVideo{}, fmt.Errorf("video with ID %s not found", videoID)
+	if config.MaxConcurrency == 0 {
+		config.MaxConcurrency = defaultMaxConcurrency
+	}
+	if config.CacheDir == "" {
+		config.CacheDir = defaultCacheDir()
+	}
+	if config.CacheBackend == "" {
+		config.CacheBackend = cacheBackendDisk
+	}
+	if config.CacheSize == 0 {
+		config.CacheSize = defaultMemoryCacheSize
+	}
+	if config.LogLevel == "" {
+		config.LogLevel = defaultLogLevel
+	}
+	if config.UserAgent == "" {
+		config.UserAgent = defaultUserAgent()
 	}
 
-	return YouTubeVideo{
-		ID:          videoID,
-		Title:       response.Items[0].Snippet.Title,
-		Description: response.Items[0].Snippet.Description,
-	}, nil
+	return config
 }
 
-func getDeeplLanguages(apiKey string) ([]DeeplLanguage, error) {
-	url := "https://api-free.deepl.com/v2/languages"
+// currentConfigVersion is the config schema this build writes and reads.
+const currentConfigVersion = 1
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
+// legacyConfigKeys maps keys of version 0 configs to their current names.
+var legacyConfigKeys = map[string]string{
+	"deepl_key": "deepl_api_key",
+}
+
+// migrateConfig upgrades the JSON of an older config file to the current
+// schema and logs every change. Configs from a newer version are an error,
+// guessing what they mean could send requests with the wrong settings.
+func migrateConfig(data []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "DeepL-Auth-Key "+apiKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	version := 0
+	if raw, ok := fields["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, fmt.Errorf("invalid version: %v", err)
+		}
+	}
+	if version > currentConfigVersion {
+		return nil, fmt.Errorf("config version %d is newer than the supported version %d, update go-translate-youtube", version, currentConfigVersion)
+	}
+	if version == currentConfigVersion {
+		return data, nil
 	}
-	defer resp.Body.Close()
 
-	var languages []DeeplLanguage
-	if err := json.NewDecoder(resp.Body).Decode(&languages); err != nil {
-		return nil, err
+	migrated := false
+	for legacy, current := range legacyConfigKeys {
+		value, ok := fields[legacy]
+		if !ok {
+			continue
+		}
+		delete(fields, legacy)
+		if _, ok := fields[current]; ok {
+			slog.Warn("config has both a legacy and a current key, ignoring the legacy one", "legacy", legacy, "current", current)
+			continue
+		}
+		fields[current] = value
+		migrated = true
+		slog.Info("migrated config key", "from", legacy, "to", current)
+	}
+	fields["version"] = json.RawMessage(strconv.Itoa(currentConfigVersion))
+	if migrated {
+		slog.Info("migrated config, rename the keys and set version to make this permanent", "from_version", version, "to_version", currentConfigVersion)
 	}
 
-	return languages, nil
+	return json.Marshal(fields)
 }
 
-func translateText(text string, apiKey string, targetLang string) (string, error) {
-	url := "https://api-free.deepl.com/v2/translate"
+var youtubeVideoIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
 
-	// Prepare translation request
-	data := map[string]interface{}{
-		"text":        []string{text},
-		"target_lang": targetLang,
-	}
-	requestData, err := json.Marshal(data)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request data: %v", err)
-	}
+// Validate reports every problem with the config at once.
+func (c Config) Validate() error {
+	errs := c.providerErrors()
 
-	// Send request to DeepL API
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create HTTP request: %v", err)
+	if c.YoutubeApiKey == "" {
+		errs = append(errs, errors.New("missing required config value youtube_api_key (YOUTUBE_API_KEY)"))
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "DeepL-Auth-Key "+apiKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send HTTP request: %v", err)
+	if c.YoutubeVideoId == "" && c.PlaylistId == "" && c.ChannelId == "" && len(c.Jobs) == 0 {
+		errs = append(errs, errors.New("missing required config value youtube_video_id (YOUTUBE_VIDEO_ID), youtube_playlist_id, youtube_channel_id or jobs"))
+	} else if c.YoutubeVideoId != "" && !youtubeVideoIDPattern.MatchString(c.YoutubeVideoId) {
+		errs = append(errs, fmt.Errorf("youtube_video_id %q is not an 11 character YouTube video ID", c.YoutubeVideoId))
+	}
+	if c.MaxRetries != nil && *c.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("max_retries must not be negative, got %d", *c.MaxRetries))
+	}
+	switch c.CacheBackend {
+	case "", cacheBackendDisk, cacheBackendMemory, cacheBackendNone:
+	default:
+		errs = append(errs, fmt.Errorf("cache_backend must be %q, %q or %q, got %q", cacheBackendDisk, cacheBackendMemory, cacheBackendNone, c.CacheBackend))
 	}
-	defer resp.Body.Close()
+	for i, job := range c.Jobs {
+		if !youtubeVideoIDPattern.MatchString(job.Video) {
+			errs = append(errs, fmt.Errorf("jobs[%d]: video %q is not a YouTube video ID or URL", i, job.Video))
+		}
+	}
+
+	return errors.Join(errs...)
+}
 
-	// Check HTTP response status code
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP request failed with status code: %d", resp.StatusCode)
+// ValidateProvider only checks what is needed to talk to the translation
+// provider, for commands that never touch YouTube.
+func (c Config) ValidateProvider() error {
+	return errors.Join(c.providerErrors()...)
+}
+
+func (c Config) providerErrors() []error {
+	var errs []error
+
+	switch c.Provider {
+	case providerDeepl, "":
+		if c.DeeplApiKey == "" {
+			errs = append(errs, errors.New("missing required config value deepl_api_key (DEEPL_API_KEY)"))
+		}
+	case providerGoogle:
+		if c.GoogleApiKey == "" {
+			errs = append(errs, errors.New("missing required config value google_api_key (GOOGLE_API_KEY)"))
+		}
+	case providerAzure:
+		if c.AzureApiKey == "" {
+			errs = append(errs, errors.New("missing required config value azure_api_key (AZURE_API_KEY)"))
+		}
+		if c.AzureRegion == "" {
+			errs = append(errs, errors.New("missing required config value azure_region (AZURE_REGION)"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown provider %q", c.Provider))
 	}
 
-	// Parse response
-	var translationResponse TranslationResponse
-	if err := json.NewDecoder(resp.Body).Decode(&translationResponse); err != nil {
-		return "", fmt.Errorf("failed to parse response body: %v", err)
+	return errs
+}
+
+func applyEnvOverrides(config *Config) {
+	if value := os.Getenv("DEEPL_API_KEY"); value != "" {
+		config.DeeplApiKey = value
+	}
+	if value := os.Getenv("GOOGLE_API_KEY"); value != "" {
+		config.GoogleApiKey = value
 	}
+	if value := os.Getenv("AZURE_API_KEY"); value != "" {
+		config.AzureApiKey = value
+	}
+	if value := os.Getenv("AZURE_REGION"); value != "" {
+		config.AzureRegion = value
+	}
+	if value := os.Getenv("YOUTUBE_API_KEY"); value != "" {
+		config.YoutubeApiKey = value
+	}
+	if value := os.Getenv("YOUTUBE_VIDEO_ID"); value != "" {
+		config.YoutubeVideoId = value
+	}
+	if value := os.Getenv("YOUTUBE_OAUTH_TOKEN"); value != "" {
+		config.OAuthToken = value
+	}
+}
 
-	// Check if translations are available
-	if len(translationResponse.Translations) == 0 {
-		return "", errors.New("no translations found")
+// retryLimit returns max_retries, or the default when it is unset.
+func retryLimit(config Config) int {
+	if config.MaxRetries != nil {
+		return *config.MaxRetries
 	}
+	return defaultMaxRetries
+}
 
-	// Extract translated text
-	translatedText := translationResponse.Translations[0].Text
+// newBackoff builds the retry backoff from retry_jitter.
+func newBackoff(config Config) BackoffStrategy {
+	jitter := defaultRetryJitter
+	if config.RetryJitter != nil {
+		jitter = *config.RetryJitter
+	}
+	return exponentialBackoff{Base: retryBaseDelay, Max: retryMaxDelay, Jitter: jitter}
+}
 
-	return translatedText, nil
+// apiSettings are what a config says about talking to the APIs. run puts
+// them into the package variables every command uses, Localize hands them to
+// the translator and the YouTube client it builds instead.
+type apiSettings struct {
+	Client       HTTPDoer
+	Retry        *retryPolicy
+	Limiter      *rateLimiter
+	DeeplBaseURL string
+	YouTubeCache TranslationCache
 }
 
-func main() {
-	fmt.Println("Starting...")
-	config, err := loadConfig("config.json")
+// newAPISettings builds the settings of config.
+func newAPISettings(config Config) (apiSettings, error) {
+	client, err := newHTTPClient(config)
 	if err != nil {
-		fmt.Println("Error loading config:", err)
-		return
+		return apiSettings{}, err
 	}
+	return apiSettings{
+		Client:       client,
+		Retry:        &retryPolicy{MaxRetries: retryLimit(config), Backoff: newBackoff(config)},
+		Limiter:      newRateLimiter(config.MaxRequestsPerSecond),
+		DeeplBaseURL: config.DeeplBaseURL,
+		YouTubeCache: newCache(config, "youtube"),
+	}, nil
+}
 
-	apiKey := config.DeeplApiKey
-	fmt.Println("apiKey:", apiKey)
-	// textToTranslate := "Hello, world!"
+// globalAPISettings are the settings in the package variables.
+func globalAPISettings() apiSettings {
+	return apiSettings{
+		Client:       httpClient,
+		Limiter:      deeplLimiter,
+		DeeplBaseURL: deeplBaseURLOverride,
+		YouTubeCache: youtubeCache,
+	}
+}
 
-	// translatedText, err := translateText(textToTranslate, apiKey, "DE")
-	// if err != nil {
-	// 	fmt.Println("Error:", err)
-	// 	return
-	// }
+// newHTTPClient builds the shared client. A configured http_proxy is used
+// for every request, whatever the HTTP_PROXY environment variables say.
+func newHTTPClient(config Config) (HTTPDoer, error) {
+	client := &http.Client{Timeout: time.Duration(config.RequestTimeoutSeconds) * time.Second}
+
+	if config.HTTPProxy != "" {
+		proxyURL, err := url.Parse(config.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http_proxy %q: %w", config.HTTPProxy, err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("invalid http_proxy %q: scheme must be http, https or socks5", config.HTTPProxy)
+		}
+		if proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid http_proxy %q: missing host", config.HTTPProxy)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxyURL)
+		client.Transport = transport
+	}
 
-	// fmt.Println("Translated text:", translatedText)
+	return userAgentDoer{HTTPDoer: redactingDoer{client}, userAgent: config.UserAgent}, nil
+}
 
-	deepLLanguages, err := getDeeplLanguages(apiKey)
+// Exit codes, scripts can tell a broken setup apart from a failing API.
+const (
+	exitFailure = 1
+	exitConfig  = 2
+	exitAPI     = 3
+)
+
+// configError marks errors caused by the config file or the command line.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string { return e.err.Error() }
+func (e *configError) Unwrap() error { return e.err }
+
+// exitCode maps err to the exit code of the program.
+func exitCode(err error) int {
+	var cfgErr *configError
+	var deeplErr *DeeplError
+	var googleErr *GoogleError
+	var azureErr *AzureError
+	var youtubeErr *YouTubeError
+	switch {
+	case errors.As(err, &cfgErr):
+		return exitConfig
+	case errors.As(err, &deeplErr), errors.As(err, &googleErr), errors.As(err, &azureErr),
+		errors.As(err, &youtubeErr):
+		return exitAPI
+	default:
+		return exitFailure
+	}
+}
+
+const defaultConfigPath = "config.json"
+
+// splitConfigFlag removes the global -config flag from args, wherever it
+// appears, and returns its value. explicit is false when the flag is absent.
+func splitConfigFlag(args []string) (path string, explicit bool, rest []string, err error) {
+	path = defaultConfigPath
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return "", false, nil, errors.New("flag needs an argument: -config")
+			}
+			i++
+			value = args[i]
+		}
+		path, explicit = value, true
+	}
+	return path, explicit, rest, nil
+}
+
+// run loads the config and runs the command named in args. The config is
+// read from config.json unless args hold a -config flag.
+func run(args []string) error {
+	path, explicit, args, err := splitConfigFlag(args)
 	if err != nil {
-		fmt.Println("Error fetching DeepL languages:", err)
-		return
+		return &configError{err}
+	}
+
+	// Only the default file may be missing, a path given on purpose must exist
+	if explicit {
+		if _, err := os.Stat(path); err != nil {
+			return &configError{fmt.Errorf("failed to load config %s: %w", path, err)}
+		}
 	}
 
-	fmt.Println("DeepL Supported Languages:")
-	for _, lang := range deepLLanguages {
-		fmt.Printf("Code: %s, Name: %s\n", lang.Code, lang.Name)
+	config, err := loadConfig(path)
+	if err != nil {
+		return &configError{fmt.Errorf("failed to load config %s: %w", path, err)}
 	}
 
-	youtubeApiKey := config.YoutubeApiKey
-	videoID := config.YoutubeVideoId
-	videoInfo, err := fetchYouTubeVideoInfo(videoID, youtubeApiKey)
+	settings, err := newAPISettings(config)
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		return &configError{err}
 	}
+	httpClient = settings.Client
+	maxRetries = settings.Retry.MaxRetries
+	backoff = settings.Retry.Backoff
+	deeplBaseURLOverride = settings.DeeplBaseURL
+	deeplLimiter = settings.Limiter
+	youtubeCache = settings.YouTubeCache
+
+	a := &app{config: config, stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, newTranslator: newTranslator}
+	return a.run(args)
+}
 
-	fmt.Println("Title:", videoInfo.Title)
-	fmt.Println("Description:", videoInfo.Description)
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		slog.Error("command failed", "error", err)
+		os.Exit(exitCode(err))
+	}
 }
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clientOf unwraps the *http.Client newHTTPClient built.
+func clientOf(t *testing.T, doer HTTPDoer) *http.Client {
+	t.Helper()
+	for {
+		switch d := doer.(type) {
+		case *http.Client:
+			return d
+		case userAgentDoer:
+			doer = d.HTTPDoer
+		case redactingDoer:
+			doer = d.HTTPDoer
+		default:
+			t.Fatalf("unexpected HTTPDoer %T", doer)
+		}
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	config, err := loadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		seconds int
+		want    time.Duration
+	}{
+		{config.RequestTimeoutSeconds, 30 * time.Second},
+		{5, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		doer, err := newHTTPClient(Config{RequestTimeoutSeconds: tt.seconds})
+		if err != nil {
+			t.Fatalf("newHTTPClient() error = %v", err)
+		}
+		if got := clientOf(t, doer).Timeout; got != tt.want {
+			t.Errorf("timeout of %d seconds = %v, want %v", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+// writeConfig writes content as config.json into a new directory and returns
+// its path. An empty content leaves the file out.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if content != "" {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	const file = `{"deepl_api_key":"file-deepl","youtube_api_key":"file-youtube","youtube_video_id":"dQw4w9WgXcQ"}`
+
+	tests := []struct {
+		name        string
+		file        string
+		env         map[string]string
+		wantDeepl   string
+		wantYoutube string
+		wantVideo   string
+	}{
+		{"file only", file, nil, "file-deepl", "file-youtube", "dQw4w9WgXcQ"},
+		{
+			name:        "env only",
+			env:         map[string]string{"DEEPL_API_KEY": "env-deepl", "YOUTUBE_API_KEY": "env-youtube", "YOUTUBE_VIDEO_ID": "9bZkp7q19f0"},
+			wantDeepl:   "env-deepl",
+			wantYoutube: "env-youtube",
+			wantVideo:   "9bZkp7q19f0",
+		},
+		{
+			name:        "env overrides the file",
+			file:        file,
+			env:         map[string]string{"DEEPL_API_KEY": "env-deepl"},
+			wantDeepl:   "env-deepl",
+			wantYoutube: "file-youtube",
+			wantVideo:   "dQw4w9WgXcQ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"DEEPL_API_KEY", "YOUTUBE_API_KEY", "YOUTUBE_VIDEO_ID"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			config, err := loadConfig(writeConfig(t, tt.file))
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			if config.DeeplApiKey != tt.wantDeepl || config.YoutubeApiKey != tt.wantYoutube || config.YoutubeVideoId != tt.wantVideo {
+				t.Errorf("keys = %q, %q, %q, want %q, %q, %q", config.DeeplApiKey, config.YoutubeApiKey, config.YoutubeVideoId,
+					tt.wantDeepl, tt.wantYoutube, tt.wantVideo)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMissingKeys(t *testing.T) {
+	for _, name := range []string{"DEEPL_API_KEY", "YOUTUBE_API_KEY", "YOUTUBE_VIDEO_ID"} {
+		t.Setenv(name, "")
+	}
+
+	config, err := loadConfig(writeConfig(t, ""))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	err = config.Validate()
+	if err == nil {
+		t.Fatal("Validate() succeeded without any keys")
+	}
+	for _, name := range []string{"DEEPL_API_KEY", "YOUTUBE_API_KEY", "YOUTUBE_VIDEO_ID"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Validate() error = %q, want it to name %s", err, name)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{DeeplApiKey: "deepl-key", YoutubeApiKey: "youtube-key", YoutubeVideoId: "dQw4w9WgXcQ"}
+
+	tests := []struct {
+		name     string
+		change   func(c *Config)
+		wantErrs []string
+	}{
+		{"valid", func(c *Config) {}, nil},
+		{"blank", func(c *Config) { *c = Config{} }, []string{"deepl_api_key", "youtube_api_key", "youtube_video_id"}},
+		{"bad video id", func(c *Config) { c.YoutubeVideoId = "dQw4w9WgXc" }, []string{`youtube_video_id "dQw4w9WgXc" is not an 11 character YouTube video ID`}},
+		{"playlist instead of video", func(c *Config) { c.YoutubeVideoId, c.PlaylistId = "", "PL123" }, nil},
+		{"bad job", func(c *Config) { c.Jobs = []BatchJob{{Video: "nope"}} }, []string{"jobs[0]"}},
+		{"google without key", func(c *Config) { c.Provider = providerGoogle }, []string{"google_api_key"}},
+	}
+	for _, tt := range tests {
+		config := valid
+		tt.change(&config)
+		err := config.Validate()
+		if len(tt.wantErrs) == 0 {
+			if err != nil {
+				t.Errorf("%s: Validate() error = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: Validate() succeeded, want %q", tt.name, tt.wantErrs)
+			continue
+		}
+		for _, want := range tt.wantErrs {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: Validate() error = %q, want it to contain %q", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestLoadConfigNormalizesVideoURLs(t *testing.T) {
+	t.Setenv("YOUTUBE_VIDEO_ID", "")
+	path := writeConfig(t, `{"youtube_video_id":"https://youtu.be/dQw4w9WgXcQ","jobs":[{"video":"https://www.youtube.com/watch?v=9bZkp7q19f0"}]}`)
+
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.YoutubeVideoId != "dQw4w9WgXcQ" || config.Jobs[0].Video != "9bZkp7q19f0" {
+		t.Errorf("video = %q, job video = %q, want the bare IDs", config.YoutubeVideoId, config.Jobs[0].Video)
+	}
+}
+
+func TestRunConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"missing config file", []string{"-config", writeConfig(t, ""), "translate"}, "failed to load config"},
+		{"invalid JSON", []string{"-config", writeConfig(t, "{"), "translate"}, "failed to load config"},
+		{"config flag without value", []string{"translate", "-config"}, "flag needs an argument"},
+	}
+	for _, tt := range tests {
+		err := run(tt.args)
+		if err == nil || exitCode(err) != exitConfig || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: run() error = %v, want a config error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"config", &configError{errors.New("bad")}, exitConfig},
+		{"wrapped config", fmt.Errorf("loading: %w", &configError{errors.New("bad")}), exitConfig},
+		{"deepl", fmt.Errorf("failed to translate: %w", &DeeplError{StatusCode: 403}), exitAPI},
+		{"google", &GoogleError{StatusCode: 403}, exitAPI},
+		{"azure", &AzureError{StatusCode: 401}, exitAPI},
+		{"youtube", &YouTubeError{StatusCode: 404}, exitAPI},
+		{"other", errors.New("disk full"), exitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+// cannedDoer records every request and answers it with the body responses
+// holds for its path, or a 404 without one.
+type cannedDoer struct {
+	responses map[string]string
+	requests  []*http.Request
+}
+
+func (d *cannedDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requests = append(d.requests, req)
+	body, ok := d.responses[req.URL.Path]
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusNotFound
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestHTTPDoerRoutesRequests(t *testing.T) {
+	doer := &cannedDoer{responses: map[string]string{
+		"/v2/translate":      `{"translations":[{"text":"Hallo","detected_source_language":"EN"}]}`,
+		"/youtube/v3/videos": `{"items":[{"id":"dQw4w9WgXcQ","snippet":{"title":"Title","description":"Description"}}]}`,
+	}}
+
+	deepl := &DeeplTranslator{APIKey: "deepl-key", Client: doer}
+	if got, err := deepl.Translate("Hello", "", "DE"); err != nil || got != "Hallo" {
+		t.Fatalf("Translate() = %q, %v, want Hallo", got, err)
+	}
+
+	previous, previousCache := httpClient, youtubeCache
+	httpClient, youtubeCache = doer, noopCache{}
+	defer func() { httpClient, youtubeCache = previous, previousCache }()
+	video, err := fetchYouTubeVideoInfo("dQw4w9WgXcQ", "youtube-key")
+	if err != nil || video.Title != "Title" {
+		t.Fatalf("fetchYouTubeVideoInfo() = %+v, %v, want the canned video", video, err)
+	}
+
+	tests := []struct {
+		method string
+		url    string
+	}{
+		{"POST", deeplProBaseURL + "/v2/translate"},
+		{"GET", youtubeAPIBaseURL + "/videos"},
+	}
+	if len(doer.requests) != len(tests) {
+		t.Fatalf("doer got %d requests, want %d", len(doer.requests), len(tests))
+	}
+	for i, tt := range tests {
+		req := doer.requests[i]
+		if got := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path; req.Method != tt.method || got != tt.url {
+			t.Errorf("request %d = %s %s, want %s %s", i, req.Method, got, tt.method, tt.url)
+		}
+	}
+}
+
+func TestSplitConfigFlag(t *testing.T) {
+	tests := []struct {
+		args         []string
+		wantPath     string
+		wantExplicit bool
+		wantRest     []string
+	}{
+		{[]string{"translate", "-v"}, defaultConfigPath, false, []string{"translate", "-v"}},
+		{[]string{"-config", "a.json", "translate"}, "a.json", true, []string{"translate"}},
+		{[]string{"translate", "--config=b.json", "-v"}, "b.json", true, []string{"translate", "-v"}},
+		{[]string{"translate", "--", "-config", "c.json"}, defaultConfigPath, false, []string{"translate", "--", "-config", "c.json"}},
+	}
+	for _, tt := range tests {
+		path, explicit, rest, err := splitConfigFlag(tt.args)
+		if err != nil {
+			t.Fatalf("splitConfigFlag(%q) error = %v", tt.args, err)
+		}
+		if path != tt.wantPath || explicit != tt.wantExplicit || !reflect.DeepEqual(rest, tt.wantRest) {
+			t.Errorf("splitConfigFlag(%q) = %q, %v, %q, want %q, %v, %q", tt.args, path, explicit, rest, tt.wantPath, tt.wantExplicit, tt.wantRest)
+		}
+	}
+}
+
+func TestLoadConfigCustomPath(t *testing.T) {
+	for _, name := range []string{"DEEPL_API_KEY", "YOUTUBE_API_KEY", "YOUTUBE_VIDEO_ID"} {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(t.TempDir(), "profiles", "channel-a.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := `{"deepl_api_key":"a-deepl","youtube_api_key":"a-youtube","youtube_video_id":"dQw4w9WgXcQ","target_langs":["DE","JA"]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig(%s) error = %v", path, err)
+	}
+	if config.DeeplApiKey != "a-deepl" || config.YoutubeApiKey != "a-youtube" || !reflect.DeepEqual(config.TargetLangs, []string{"DE", "JA"}) {
+		t.Errorf("loadConfig(%s) = %+v, want the profile's values", path, config)
+	}
+
+	missing := filepath.Join(t.TempDir(), "nope.json")
+	if err := run([]string{"-config", missing, "check"}); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("run() with a missing -config error = %v, want it to name %s", err, missing)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{"default", "", "go-translate-youtube/" + version},
+		{"configured", "my-pipeline/2.0", "my-pipeline/2.0"},
+	}
+	for _, tt := range tests {
+		config := normalizeConfig(Config{UserAgent: tt.userAgent})
+		client, err := newHTTPClient(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doer := &cannedDoer{responses: map[string]string{
+			"/v2/translate":      `{"translations":[{"text":"Hallo"}]}`,
+			"/v2/languages":      `[]`,
+			"/youtube/v3/videos": `{"items":[{"id":"dQw4w9WgXcQ","snippet":{"title":"Title"}}]}`,
+		}}
+
+		previous, previousCache := httpClient, youtubeCache
+		httpClient, youtubeCache = userAgentDoer{HTTPDoer: doer, userAgent: client.(userAgentDoer).userAgent}, noopCache{}
+		translateText("Hello", "deepl-key", "", "DE")
+		getDeeplLanguages("deepl-key", deeplLanguageTypeTarget)
+		fetchYouTubeVideoInfo("dQw4w9WgXcQ", "youtube-key")
+		httpClient, youtubeCache = previous, previousCache
+
+		if len(doer.requests) != 3 {
+			t.Fatalf("%s: sent %d requests, want 3", tt.name, len(doer.requests))
+		}
+		for _, req := range doer.requests {
+			if got := req.Header.Get("User-Agent"); got != tt.want {
+				t.Errorf("%s: %s has User-Agent %q, want %q", tt.name, req.URL.Path, got, tt.want)
+			}
+		}
+	}
+
+	// A header set on purpose is kept
+	doer := &cannedDoer{}
+	req, _ := http.NewRequest("GET", "https://example.com", nil)
+	req.Header.Set("User-Agent", "other")
+	userAgentDoer{HTTPDoer: doer, userAgent: defaultUserAgent()}.Do(req)
+	if got := doer.requests[0].Header.Get("User-Agent"); got != "other" {
+		t.Errorf("User-Agent = %q, want the one already set", got)
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://env-proxy.example.com:3128")
+
+	tests := []struct {
+		proxy     string
+		wantProxy string
+		wantErr   string
+	}{
+		{"http://proxy.corp.example.com:8080", "http://proxy.corp.example.com:8080", ""},
+		{"socks5://127.0.0.1:1080", "socks5://127.0.0.1:1080", ""},
+		{"ftp://proxy.corp.example.com", "", "scheme must be http, https or socks5"},
+		{"http://", "", "missing host"},
+		{"http://proxy\x7f", "", "invalid http_proxy"},
+	}
+	for _, tt := range tests {
+		doer, err := newHTTPClient(Config{HTTPProxy: tt.proxy})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newHTTPClient(%q) error = %v, want %q", tt.proxy, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("newHTTPClient(%q) error = %v", tt.proxy, err)
+		}
+
+		transport, ok := clientOf(t, doer).Transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("newHTTPClient(%q) has no *http.Transport", tt.proxy)
+		}
+		req, _ := http.NewRequest("GET", "https://api.deepl.com/v2/usage", nil)
+		proxyURL, err := transport.Proxy(req)
+		if err != nil || proxyURL == nil || proxyURL.String() != tt.wantProxy {
+			t.Errorf("newHTTPClient(%q) proxies through %v, %v, want %s", tt.proxy, proxyURL, err, tt.wantProxy)
+		}
+	}
+}
+
+func TestLoadConfigMigrates(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		wantDeepl string
+		wantErr   string
+	}{
+		{"v0 legacy key", `{"deepl_key":"old-deepl","youtube_api_key":"y"}`, "old-deepl", ""},
+		{"v0 current key wins", `{"deepl_key":"old-deepl","deepl_api_key":"new-deepl"}`, "new-deepl", ""},
+		{"v0 without legacy keys", `{"deepl_api_key":"new-deepl"}`, "new-deepl", ""},
+		{"current version", `{"version":1,"deepl_api_key":"new-deepl"}`, "new-deepl", ""},
+		{"future version", `{"version":2,"deepl_api_key":"new-deepl"}`, "", "config version 2 is newer than the supported version 1"},
+		{"invalid version", `{"version":"one"}`, "", "invalid version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEEPL_API_KEY", "")
+
+			config, err := loadConfig(writeConfig(t, tt.file))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			if config.DeeplApiKey != tt.wantDeepl || config.Version != currentConfigVersion {
+				t.Errorf("loadConfig() key %q version %d, want %q version %d", config.DeeplApiKey, config.Version, tt.wantDeepl, currentConfigVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats counts the translation requests of a run and the characters they
+// were billed. It is safe for concurrent use.
+type Stats struct {
+	requests         atomic.Int64
+	billedCharacters atomic.Int64
+}
+
+// StatsSnapshot is what Stats counted at one point in time.
+type StatsSnapshot struct {
+	// Requests counts the texts the provider translated, failed requests
+	// are counted by provider in the API error metric
+	Requests         int64
+	BilledCharacters int64
+}
+
+func (s *Stats) add(billedCharacters int) {
+	s.requests.Add(1)
+	s.billedCharacters.Add(int64(billedCharacters))
+}
+
+// Snapshot reads the counters. Requests still running may show up in the
+// next snapshot.
+func (s *Stats) Snapshot() StatsSnapshot {
+	return StatsSnapshot{Requests: s.requests.Load(), BilledCharacters: s.billedCharacters.Load()}
+}
+
+// metrics counts what the translate run did, for scraping in the Prometheus
+// text format. It is safe for concurrent use.
+type metrics struct {
+	Stats
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+
+	mu        sync.Mutex
+	apiErrors map[string]int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{apiErrors: make(map[string]int64)}
+}
+
+// runMetrics collects the metrics of the whole process.
+var runMetrics = newMetrics()
+
+func (m *metrics) apiError(provider string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiErrors[provider]++
+}
+
+// WriteTo writes every counter in the Prometheus text exposition format.
+func (m *metrics) WriteTo(w io.Writer) (int64, error) {
+	stats := m.Snapshot()
+	counters := []struct {
+		name  string
+		help  string
+		value int64
+	}{
+		{"translate_youtube_translations_total", "Texts sent to the translation provider.", stats.Requests},
+		{"translate_youtube_billed_characters_total", "Characters the translation provider billed.", stats.BilledCharacters},
+		{"translate_youtube_cache_hits_total", "Translations answered from the cache.", m.cacheHits.Load()},
+		{"translate_youtube_cache_misses_total", "Translations missing from the cache.", m.cacheMisses.Load()},
+	}
+
+	cw := &countingWriter{w: w}
+	for _, c := range counters {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.value)
+	}
+
+	m.mu.Lock()
+	providers := make([]string, 0, len(m.apiErrors))
+	for provider := range m.apiErrors {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	fmt.Fprint(cw, "# HELP translate_youtube_api_errors_total Failed translation requests by provider.\n# TYPE translate_youtube_api_errors_total counter\n")
+	for _, provider := range providers {
+		fmt.Fprintf(cw, "translate_youtube_api_errors_total{provider=%q} %d\n", provider, m.apiErrors[provider])
+	}
+	m.mu.Unlock()
+
+	return cw.n, cw.err
+}
+
+// countingWriter remembers the first write error, so WriteTo can ignore the
+// errors of the single writes.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// ServeHTTP exposes the metrics, e.g. under /metrics.
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// serveMetrics serves runMetrics on addr under /metrics in the background.
+// The returned function shuts the server down.
+func serveMetrics(addr string) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", runMetrics)
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Warn("metrics server stopped", "error", err)
+		}
+	}()
+	slog.Info("serving metrics", "address", listener.Addr().String())
+
+	return func() { server.Close() }, nil
+}
+
+// logSummary logs what the translated results were billed, along with the
+// requests and characters of the whole run. These also count -verify round
+// trips and -title-max retries. Cached texts count in neither.
+func logSummary(billedCharacters int) {
+	stats := runMetrics.Snapshot()
+	slog.Info("billed characters", "total", billedCharacters,
+		"requests", stats.Requests, "run_billed_characters", stats.BilledCharacters)
+}
+
+// metricsTranslator counts every request to the provider below it.
+type metricsTranslator struct {
+	Translator
+	provider string
+	metrics  *metrics
+}
+
+func (t metricsTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	result, err := t.TranslateDetailed(text, sourceLang, targetLang)
+	return result.Text, err
+}
+
+func (t metricsTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.TranslateDetailedCtx(context.Background(), text, sourceLang, targetLang)
+}
+
+func (t metricsTranslator) TranslateDetailedCtx(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	result, err := translateWithDetectionCtx(ctx, t.Translator, text, sourceLang, targetLang)
+	if err != nil {
+		t.metrics.apiError(t.provider)
+		return result, err
+	}
+	t.metrics.add(result.BilledCharacters)
+	return result, nil
+}
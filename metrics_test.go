@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// useMetrics points runMetrics at fresh counters for the test.
+func useMetrics(t *testing.T) *metrics {
+	t.Helper()
+	previous := runMetrics
+	runMetrics = newMetrics()
+	t.Cleanup(func() { runMetrics = previous })
+	return runMetrics
+}
+
+func TestMetricsCountTranslations(t *testing.T) {
+	m := useMetrics(t)
+	translator := cachedTranslator{
+		Translator: metricsTranslator{Translator: billingTranslator{}, provider: providerDeepl, metrics: m},
+		cache:      NewMemoryCache(10),
+	}
+	failing := metricsTranslator{
+		Translator: fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+			return "", errors.New("provider unavailable")
+		}},
+		provider: providerDeepl,
+		metrics:  m,
+	}
+
+	for _, text := range []string{"Hello", "World", "Hello"} {
+		if _, err := translator.Translate(text, "", "DE"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := failing.Translate("Hello", "", "DE"); err == nil {
+		t.Fatal("Translate() succeeded, want the provider error")
+	}
+
+	server := httptest.NewServer(m)
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+
+	tests := []struct {
+		line string
+	}{
+		{"translate_youtube_translations_total 2\n"},
+		{"translate_youtube_billed_characters_total 10\n"},
+		{"translate_youtube_cache_hits_total 1\n"},
+		{"translate_youtube_cache_misses_total 2\n"},
+		{`translate_youtube_api_errors_total{provider="deepl"} 1` + "\n"},
+		{"# TYPE translate_youtube_api_errors_total counter\n"},
+	}
+	for _, tt := range tests {
+		if !strings.Contains(string(body), tt.line) {
+			t.Errorf("metrics lack %q:\n%s", tt.line, body)
+		}
+	}
+}
+
+func TestMetricsWriteToSortsProviders(t *testing.T) {
+	m := newMetrics()
+	for _, provider := range []string{providerGoogle, providerDeepl, providerAzure, providerDeepl} {
+		m.apiError(provider)
+	}
+
+	var buf strings.Builder
+	n, err := m.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo() = %d, %v, wrote %d bytes", n, err, buf.Len())
+	}
+	azure := strings.Index(buf.String(), `{provider="azure"} 1`)
+	deepl := strings.Index(buf.String(), `{provider="deepl"} 2`)
+	google := strings.Index(buf.String(), `{provider="google"} 1`)
+	if azure < 0 || deepl < azure || google < deepl {
+		t.Errorf("api errors out of order or miscounted:\n%s", buf.String())
+	}
+}
+
+func TestServeMetricsBadAddress(t *testing.T) {
+	if _, err := serveMetrics("not an address"); err == nil {
+		t.Error("serveMetrics() succeeded on an invalid address, want an error")
+	}
+}
+
+func TestStatsConcurrent(t *testing.T) {
+	tests := []struct {
+		provider   string
+		translator func(t *testing.T) Translator
+	}{
+		{providerDeepl, func(t *testing.T) Translator { return billingTranslator{} }},
+		{providerGoogle, func(t *testing.T) Translator { return newGoogleStub(t) }},
+		{providerAzure, func(t *testing.T) Translator { return newAzureStub(t) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			m := newMetrics()
+			translator := metricsTranslator{Translator: tt.translator(t), provider: tt.provider, metrics: m}
+
+			const workers, perWorker = 10, 20
+			texts := []string{"Hi", "Hello", "Grüße", "こんにちは"}
+			var wantBilled int64
+			for i := 0; i < workers*perWorker; i++ {
+				wantBilled += int64(len([]rune(texts[i%len(texts)])))
+			}
+
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < perWorker; i++ {
+						if _, err := translator.Translate(texts[(w*perWorker+i)%len(texts)], "", "DE"); err != nil {
+							t.Error(err)
+						}
+						m.Snapshot()
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			want := StatsSnapshot{Requests: workers * perWorker, BilledCharacters: wantBilled}
+			if got := m.Snapshot(); got != want {
+				t.Errorf("Snapshot() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLogSummary(t *testing.T) {
+	m := useMetrics(t)
+	m.add(12)
+	m.add(30)
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	logSummary(40)
+	slog.SetDefault(previous)
+
+	for _, want := range []string{"total=40", "requests=2", "run_billed_characters=42"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("summary = %q, want it to contain %q", buf.String(), want)
+		}
+	}
+}
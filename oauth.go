@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const googleTokenURL = "https://oauth2.googleapis.com/token"
+
+var errOAuthRequired = errors.New("modifying YouTube videos requires OAuth: set oauth_token (YOUTUBE_OAUTH_TOKEN), " +
+	"or oauth_refresh_token together with oauth_client_id and oauth_client_secret")
+
+// youtubeAuthHeader returns the Authorization header value for YouTube write
+// operations. Read-only calls keep using the API key query parameter.
+func youtubeAuthHeader(cfg Config) (string, error) {
+	token, err := youtubeAccessToken(cfg)
+	if err != nil {
+		return "", err
+	}
+	return "Bearer " + token, nil
+}
+
+// youtubeAccessToken returns the configured OAuth access token, requesting a
+// fresh one when only a refresh token is configured.
+func youtubeAccessToken(cfg Config) (string, error) {
+	token := cfg.OAuthToken
+	if token == "" && cfg.OAuthRefreshToken != "" {
+		refreshed, err := refreshOAuthToken(context.Background(), cfg)
+		if err != nil {
+			return "", err
+		}
+		token = refreshed
+	}
+
+	if err := checkOAuthToken(token); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func checkOAuthToken(token string) error {
+	if token == "" {
+		return errOAuthRequired
+	}
+	if strings.HasPrefix(token, googleAPIKeyPrefix) {
+		return fmt.Errorf("got a YouTube API key where an OAuth access token was expected: %w", errOAuthRequired)
+	}
+	return nil
+}
+
+func refreshOAuthToken(ctx context.Context, cfg Config) (string, error) {
+	if cfg.OAuthClientID == "" || cfg.OAuthClientSecret == "" {
+		return "", fmt.Errorf("oauth_refresh_token needs oauth_client_id and oauth_client_secret: %w", errOAuthRequired)
+	}
+
+	form := url.Values{}
+	form.Set("client_id", cfg.OAuthClientID)
+	form.Set("client_secret", cfg.OAuthClientSecret)
+	form.Set("refresh_token", cfg.OAuthRefreshToken)
+	form.Set("grant_type", "refresh_token")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", googleTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to refresh OAuth token, status code: %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse response body: %v", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("OAuth token response did not contain an access token")
+	}
+
+	return token.AccessToken, nil
+}
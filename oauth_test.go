@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestYoutubeAuthHeader(t *testing.T) {
+	useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" || r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"ya29.fresh","expires_in":3599}`))
+	}))
+
+	tests := []struct {
+		name    string
+		config  Config
+		want    string
+		wantErr bool
+	}{
+		{"access token", Config{OAuthToken: "ya29.token"}, "Bearer ya29.token", false},
+		{"refresh token", Config{OAuthRefreshToken: "refresh", OAuthClientID: "id", OAuthClientSecret: "secret"}, "Bearer ya29.fresh", false},
+		{"no token", Config{}, "", true},
+		{"api key", Config{OAuthToken: googleAPIKeyPrefix + "SyApiKey"}, "", true},
+		{"refresh token without client", Config{OAuthRefreshToken: "refresh"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := youtubeAuthHeader(tt.config)
+		if got != tt.want {
+			t.Errorf("%s: youtubeAuthHeader() = %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.wantErr && !errors.Is(err, errOAuthRequired) {
+			t.Errorf("%s: error = %v, want errOAuthRequired", tt.name, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestRefreshOAuthTokenFailure(t *testing.T) {
+	useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	config := Config{OAuthRefreshToken: "revoked", OAuthClientID: "id", OAuthClientSecret: "secret"}
+	if _, err := youtubeAuthHeader(config); err == nil {
+		t.Error("youtubeAuthHeader() succeeded with a revoked refresh token")
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type VideoTranslation struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+
+	// With -verify, how close translating back came to the original, from 0
+	// to 1
+	TitleSimilarity       *float64 `json:"title_similarity,omitempty"`
+	DescriptionSimilarity *float64 `json:"description_similarity,omitempty"`
+
+	// Why a field failed while the other one was translated, its text is
+	// empty then
+	TitleError       string `json:"title_error,omitempty"`
+	DescriptionError string `json:"description_error,omitempty"`
+}
+
+type TranslatedVideo struct {
+	VideoID      string                      `json:"video_id"`
+	Title        string                      `json:"title"`
+	Description  string                      `json:"description"`
+	Translations map[string]VideoTranslation `json:"translations"`
+
+	// BilledCharacters sums what translating the video cost, as far as the
+	// provider reports it.
+	BilledCharacters int `json:"-"`
+}
+
+// writeTranslationOutput writes result as indented JSON to path, or to w
+// when path is empty. encoding/json sorts map keys, so the language order is
+// stable between runs.
+func writeTranslationOutput(w io.Writer, path string, result TranslatedVideo) error {
+	return writeJSON(w, path, result)
+}
+
+// encodeJSON writes v as indented JSON to w. Map keys come out sorted, so the
+// same v always gives the same bytes and checked in output only changes where
+// the translations do.
+func encodeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	_, err = w.Write(data)
+	return err
+}
+
+// writeJSON writes v as indented JSON to path, or to w when path is empty.
+func writeJSON(w io.Writer, path string, v interface{}) error {
+	if path == "" {
+		return encodeJSON(w, v)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encodeJSON(file, v); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// localizations converts the translations of result into YouTube
+// localizations keyed by YouTube's language code.
+func (result TranslatedVideo) localizations() map[string]YouTubeLocalization {
+	locs := make(map[string]YouTubeLocalization, len(result.Translations))
+	for lang, translation := range result.Translations {
+		locs[deeplToYouTubeLang(lang)] = YouTubeLocalization{Title: translation.Title, Description: translation.Description}
+	}
+	return locs
+}
+
+// billedCharacters sums the billed characters of results.
+func billedCharacters(results []TranslatedVideo) int {
+	total := 0
+	for _, result := range results {
+		total += result.BilledCharacters
+	}
+	return total
+}
+
+// writeResults writes results to path, or to w when path is empty, as CSV
+// for a .csv path and as JSON otherwise. Unless asList is set a single
+// result is written as an object.
+func writeResults(w io.Writer, path string, results []TranslatedVideo, asList bool) error {
+	if isCSVPath(path) {
+		return writeCSV(w, path, translationRows(results))
+	}
+	if !asList && len(results) == 1 {
+		return writeTranslationOutput(w, path, results[0])
+	}
+	return writeJSON(w, path, results)
+}
+
+// LanguageFile is what writeLanguageFiles writes for each target language.
+type LanguageFile struct {
+	Language string              `json:"language"`
+	Videos   []LanguageFileVideo `json:"videos"`
+}
+
+type LanguageFileVideo struct {
+	VideoID     string `json:"video_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9-]`)
+
+// languageFilename turns a language code into a safe file name, e.g. "PT-BR"
+// becomes "PT-BR.json" and anything but letters, digits and dashes becomes
+// an underscore.
+func languageFilename(lang string) (string, error) {
+	name := unsafeFilenameChars.ReplaceAllString(strings.TrimSpace(lang), "_")
+	if strings.Trim(name, "_") == "" {
+		return "", fmt.Errorf("language code %q can't be used as a file name", lang)
+	}
+	return name + ".json", nil
+}
+
+// writeLanguageFiles writes one JSON file per target language into dir,
+// creating it when missing. Existing files are only replaced when force is
+// set.
+func writeLanguageFiles(dir string, results []TranslatedVideo, force bool) error {
+	files := make(map[string]*LanguageFile)
+	for _, result := range results {
+		for lang, translation := range result.Translations {
+			file, ok := files[lang]
+			if !ok {
+				file = &LanguageFile{Language: lang}
+				files[lang] = file
+			}
+			file.Videos = append(file.Videos, LanguageFileVideo{
+				VideoID:     result.VideoID,
+				Title:       translation.Title,
+				Description: translation.Description,
+			})
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Write in a fixed order so the errors are reported the same way every run
+	languages := make([]string, 0, len(files))
+	for lang := range files {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+
+	var errs []error
+	for _, lang := range languages {
+		file := files[lang]
+		name, err := languageFilename(lang)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if !force {
+			if _, err := os.Stat(path); err == nil {
+				errs = append(errs, fmt.Errorf("%s already exists, use -force to overwrite it", path))
+				continue
+			}
+		}
+		// path is never empty, so no fallback writer is needed
+		if err := writeJSON(nil, path, file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// writeJSONL writes every result received on results as one line of JSON to
+// w, as soon as it arrives. After a write error the remaining results are
+// drained so the sender never blocks.
+func writeJSONL(w io.Writer, results <-chan TranslatedVideo) error {
+	enc := json.NewEncoder(w)
+	var err error
+	for result := range results {
+		if err == nil {
+			err = enc.Encode(result)
+		}
+	}
+	return err
+}
+
+// isJSONLPath reports whether path asks for JSON Lines instead of one JSON
+// document.
+func isJSONLPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".jsonl")
+}
+
+// TranslationRow is one line of the CSV export, a single video in a single
+// target language.
+type TranslationRow struct {
+	VideoID               string
+	Language              string
+	OriginalTitle         string
+	TranslatedTitle       string
+	OriginalDescription   string
+	TranslatedDescription string
+}
+
+var translationCSVHeader = []string{
+	"video_id",
+	"language",
+	"original_title",
+	"translated_title",
+	"original_description",
+	"translated_description",
+}
+
+// translationRows flattens videos into one row per video and language, with
+// the languages of each video sorted.
+func translationRows(videos []TranslatedVideo) []TranslationRow {
+	var rows []TranslationRow
+	for _, video := range videos {
+		languages := make([]string, 0, len(video.Translations))
+		for language := range video.Translations {
+			languages = append(languages, language)
+		}
+		sort.Strings(languages)
+
+		for _, language := range languages {
+			translation := video.Translations[language]
+			rows = append(rows, TranslationRow{
+				VideoID:               video.VideoID,
+				Language:              language,
+				OriginalTitle:         video.Title,
+				TranslatedTitle:       translation.Title,
+				OriginalDescription:   video.Description,
+				TranslatedDescription: translation.Description,
+			})
+		}
+	}
+	return rows
+}
+
+// encodeCSV writes rows with a header line to w. Fields holding commas,
+// quotes or newlines are quoted.
+func encodeCSV(w io.Writer, rows []TranslationRow) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(translationCSVHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{
+			row.VideoID,
+			row.Language,
+			row.OriginalTitle,
+			row.TranslatedTitle,
+			row.OriginalDescription,
+			row.TranslatedDescription,
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// writeCSV writes rows as CSV to path, or to w when path is empty.
+func writeCSV(w io.Writer, path string, rows []TranslationRow) error {
+	if path == "" {
+		return encodeCSV(w, rows)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encodeCSV(file, rows); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// isCSVPath reports whether path asks for the CSV export instead of JSON.
+func isCSVPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".csv")
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sampleTranslatedVideo() TranslatedVideo {
+	return TranslatedVideo{
+		VideoID:     "dQw4w9WgXcQ",
+		Title:       "Title",
+		Description: "Description",
+		Translations: map[string]VideoTranslation{
+			"FR": {Title: "Titre", Description: "La description"},
+			"DE": {Title: "Titel", Description: "Beschreibung"},
+		},
+	}
+}
+
+func TestWriteTranslationOutput(t *testing.T) {
+	const want = `{
+  "video_id": "dQw4w9WgXcQ",
+  "title": "Title",
+  "description": "Description",
+  "translations": {
+    "DE": {
+      "title": "Titel",
+      "description": "Beschreibung"
+    },
+    "FR": {
+      "title": "Titre",
+      "description": "La description"
+    }
+  }
+}
+`
+	path := filepath.Join(t.TempDir(), "out.json")
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"stdout", ""},
+		{"file", path},
+	}
+	for _, tt := range tests {
+		var stdout bytes.Buffer
+		if err := writeTranslationOutput(&stdout, tt.path, sampleTranslatedVideo()); err != nil {
+			t.Fatalf("%s: writeTranslationOutput() error = %v", tt.name, err)
+		}
+		got := stdout.String()
+		if tt.path != "" {
+			data, err := os.ReadFile(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stdout.Len() != 0 {
+				t.Errorf("%s: wrote %q to stdout", tt.name, stdout.String())
+			}
+			got = string(data)
+		}
+		if got != want {
+			t.Errorf("%s: output = %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestWriteCSVRoundTrip(t *testing.T) {
+	rows := []TranslationRow{
+		{
+			VideoID:               "dQw4w9WgXcQ",
+			Language:              "DE",
+			OriginalTitle:         `Hello, "world"`,
+			TranslatedTitle:       `Hallo, "Welt"`,
+			OriginalDescription:   "Line one, with a comma\nLine two\r\n\nLine four",
+			TranslatedDescription: "Zeile eins, mit Komma\nZeile zwei",
+		},
+		{VideoID: "dQw4w9WgXcQ", Language: "FR", OriginalTitle: "Plain"},
+	}
+	path := filepath.Join(t.TempDir(), "out.csv")
+
+	if err := writeCSV(nil, path, rows); err != nil {
+		t.Fatalf("writeCSV() error = %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("reading the CSV back failed: %v", err)
+	}
+
+	if len(records) != len(rows)+1 || !reflect.DeepEqual(records[0], translationCSVHeader) {
+		t.Fatalf("read %d records starting with %v, want the header and %d rows", len(records), records[0], len(rows))
+	}
+	for i, row := range rows {
+		// encoding/csv reads \r\n inside a quoted field back as \n
+		row.OriginalDescription = strings.ReplaceAll(row.OriginalDescription, "\r\n", "\n")
+		want := []string{row.VideoID, row.Language, row.OriginalTitle, row.TranslatedTitle, row.OriginalDescription, row.TranslatedDescription}
+		if !reflect.DeepEqual(records[i+1], want) {
+			t.Errorf("row %d = %q, want %q", i, records[i+1], want)
+		}
+	}
+}
+
+func TestTranslationRows(t *testing.T) {
+	rows := translationRows([]TranslatedVideo{sampleTranslatedVideo()})
+	want := []TranslationRow{
+		{"dQw4w9WgXcQ", "DE", "Title", "Titel", "Description", "Beschreibung"},
+		{"dQw4w9WgXcQ", "FR", "Title", "Titre", "Description", "La description"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("translationRows() = %+v, want %+v", rows, want)
+	}
+}
+
+// lineWriter passes each write on to lines, so tests see when it happens.
+type lineWriter struct {
+	lines chan string
+	err   error
+}
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.lines <- string(p)
+	return len(p), nil
+}
+
+func TestWriteJSONL(t *testing.T) {
+	ids := []string{"video3", "video1", "video2"}
+	w := lineWriter{lines: make(chan string, len(ids))}
+	results := make(chan TranslatedVideo)
+	errc := make(chan error, 1)
+	go func() { errc <- writeJSONL(w, results) }()
+
+	for _, id := range ids {
+		results <- TranslatedVideo{VideoID: id, Title: "Title " + id}
+		// Each result is written before the next one arrives
+		line := <-w.lines
+		var got TranslatedVideo
+		if err := json.Unmarshal([]byte(line), &got); err != nil || got.VideoID != id {
+			t.Fatalf("line %q = %+v, %v, want video %s", line, got, err, id)
+		}
+		if !strings.HasSuffix(line, "}\n") || strings.Count(line, "\n") != 1 {
+			t.Errorf("line %q isn't a single line of JSON", line)
+		}
+	}
+	close(results)
+	if err := <-errc; err != nil {
+		t.Errorf("writeJSONL() error = %v", err)
+	}
+}
+
+func TestWriteJSONLDrainsAfterError(t *testing.T) {
+	results := make(chan TranslatedVideo)
+	errc := make(chan error, 1)
+	go func() { errc <- writeJSONL(lineWriter{err: errors.New("disk full")}, results) }()
+
+	for i := 0; i < 3; i++ {
+		results <- TranslatedVideo{VideoID: "video"}
+	}
+	close(results)
+	if err := <-errc; err == nil || err.Error() != "disk full" {
+		t.Errorf("writeJSONL() error = %v, want disk full", err)
+	}
+}
+
+func TestLocalizationsUseYouTubeCodes(t *testing.T) {
+	video := TranslatedVideo{Translations: map[string]VideoTranslation{
+		"EN-US": {Title: "Title"},
+		"PT-BR": {Title: "Título"},
+		"ZH":    {Title: "标题"},
+	}}
+	want := map[string]YouTubeLocalization{
+		"en":      {Title: "Title"},
+		"pt-BR":   {Title: "Título"},
+		"zh-Hans": {Title: "标题"},
+	}
+	if got := video.localizations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("localizations() = %v, want %v", got, want)
+	}
+}
+
+func TestLanguageFilename(t *testing.T) {
+	tests := []struct {
+		lang    string
+		want    string
+		wantErr bool
+	}{
+		{"DE", "DE.json", false},
+		{"pt-BR", "pt-BR.json", false},
+		{"../etc", "___etc.json", false},
+		{"a/b", "a_b.json", false},
+		{" ", "", true},
+		{"..", "", true},
+	}
+	for _, tt := range tests {
+		got, err := languageFilename(tt.lang)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("languageFilename(%q) = %q, %v, want %q", tt.lang, got, err, tt.want)
+		}
+	}
+}
+
+func TestTranslateOutputDir(t *testing.T) {
+	useAPIStub(t)
+	dir := filepath.Join(t.TempDir(), "out")
+
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"-output-dir", dir}, false},
+		{[]string{"-output-dir", dir}, true},
+		{[]string{"-output-dir", dir, "-force"}, false},
+	}
+	for _, tt := range tests {
+		a, _, _ := newTestApp(testConfig())
+		if err := a.runTranslate(tt.args); (err != nil) != tt.wantErr {
+			t.Fatalf("translate %v error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{"DE.json", "FR.json"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("%s holds %v, want %v", dir, names, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "FR.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file LanguageFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	want := LanguageFile{Language: "FR", Videos: []LanguageFileVideo{{VideoID: "dQw4w9WgXcQ", Title: "FR:Title", Description: "FR:Description"}}}
+	if !reflect.DeepEqual(file, want) {
+		t.Errorf("FR.json = %+v, want %+v", file, want)
+	}
+}
+
+func TestOutputIsByteIdentical(t *testing.T) {
+	// Enough languages that a random map order would show up
+	translations := map[string]VideoTranslation{}
+	for _, lang := range []string{"DE", "FR", "ES", "IT", "JA", "KO", "NL", "PL", "PT-BR", "SV", "TR", "UK"} {
+		translations[lang] = VideoTranslation{Title: lang + ":Title", Description: lang + ":Description"}
+	}
+	results := []TranslatedVideo{
+		{VideoID: "dQw4w9WgXcQ", Title: "Title", Description: "Description", Translations: translations},
+		{VideoID: "9bZkp7q1-ZQ", Title: "Other", Description: "Other", Translations: translations},
+	}
+
+	tests := []struct {
+		name  string
+		write func(dir string) ([]byte, error)
+	}{
+		{"results", func(dir string) ([]byte, error) {
+			var buf bytes.Buffer
+			err := writeResults(&buf, "", results, true)
+			return buf.Bytes(), err
+		}},
+		{"language file", func(dir string) ([]byte, error) {
+			if err := writeLanguageFiles(dir, results, false); err != nil {
+				return nil, err
+			}
+			return os.ReadFile(filepath.Join(dir, "PT-BR.json"))
+		}},
+	}
+	for _, tt := range tests {
+		first, err := tt.write(t.TempDir())
+		if err != nil {
+			t.Fatalf("%s: first write error = %v", tt.name, err)
+		}
+		for i := 0; i < 5; i++ {
+			again, err := tt.write(t.TempDir())
+			if err != nil {
+				t.Fatalf("%s: write error = %v", tt.name, err)
+			}
+			if !bytes.Equal(first, again) {
+				t.Fatalf("%s: output changed between writes:\n%s\n%s", tt.name, first, again)
+			}
+		}
+	}
+}
+
+func TestWriteLanguageFilesReportsInOrder(t *testing.T) {
+	dir := t.TempDir()
+	results := []TranslatedVideo{sampleTranslatedVideo()}
+	if err := writeLanguageFiles(dir, results, false); err != nil {
+		t.Fatal(err)
+	}
+
+	err := writeLanguageFiles(dir, results, false)
+	if err == nil {
+		t.Fatal("writeLanguageFiles() succeeded over existing files, want an error")
+	}
+	de, fr := strings.Index(err.Error(), "DE.json"), strings.Index(err.Error(), "FR.json")
+	if de < 0 || fr < 0 || de > fr {
+		t.Errorf("writeLanguageFiles() error = %q, want DE.json reported before FR.json", err)
+	}
+}
+
+func TestWriteJSONTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.json")
+
+	tests := []struct {
+		path       string
+		wantWriter bool
+	}{
+		{"", true},
+		{path, false},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, tt.path, map[string]string{"b": "2", "a": "1"}); err != nil {
+			t.Fatalf("writeJSON(%q) error = %v", tt.path, err)
+		}
+		const want = "{\n  \"a\": \"1\",\n  \"b\": \"2\"\n}\n"
+		got := buf.String()
+		if !tt.wantWriter {
+			if buf.Len() != 0 {
+				t.Errorf("writeJSON(%q) also wrote to the writer: %q", tt.path, buf.String())
+			}
+			data, err := os.ReadFile(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = string(data)
+		}
+		if got != want {
+			t.Errorf("writeJSON(%q) wrote %q, want %q", tt.path, got, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	urlPattern = regexp.MustCompile(`(?:https?://|www\.)[^\s<>"]+`)
+	// A hashtag starts at the beginning of the text or after a character that
+	// can't be part of a word, and must contain at least one letter.
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/])(#[\p{L}\p{N}_]*\p{L}[\p{L}\p{N}_]*)`)
+	// An emoji with its variation selectors, skin tones and zero width joined
+	// parts. A run of emoji becomes a single placeholder.
+	emojiPattern = regexp.MustCompile(`(?:` + emojiChar + `[\x{FE0F}\x{20E3}\x{1F3FB}-\x{1F3FF}]*(?:\x{200D}` + emojiChar + `[\x{FE0F}\x{1F3FB}-\x{1F3FF}]*)*)+`)
+)
+
+const emojiChar = `[\x{1F000}-\x{1FAFF}\x{2600}-\x{27BF}\x{2B00}-\x{2BFF}\x{2300}-\x{23FF}]`
+
+// placeholderMarkers are the bracket pairs used to build placeholder tokens.
+// The first pair that doesn't already occur in the text is used, so original
+// text can never be mistaken for a placeholder. None of them may match
+// emojiChar, or masking emoji would mask the tokens again.
+var placeholderMarkers = [][2]string{
+	{"⟦", "⟧"},
+	{"⟪", "⟫"},
+	// Private use code points, text practically never holds them
+	{"\uE000", "\uE001"},
+}
+
+type maskedText struct {
+	Text      string
+	Originals []string
+	open      string
+	close     string
+}
+
+// maskPattern replaces every match of pattern's group in text with a
+// placeholder token and records the original.
+func (m *maskedText) maskPattern(pattern *regexp.Regexp, group int, trim string) {
+	var b strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringSubmatchIndex(m.Text, -1) {
+		start, end := match[2*group], match[2*group+1]
+		original := strings.TrimRight(m.Text[start:end], trim)
+		end = start + len(original)
+		if original == "" {
+			continue
+		}
+
+		b.WriteString(m.Text[last:start])
+		b.WriteString(m.open + strconv.Itoa(len(m.Originals)) + m.close)
+		m.Originals = append(m.Originals, original)
+		last = end
+	}
+	b.WriteString(m.Text[last:])
+	m.Text = b.String()
+}
+
+// maskLinks replaces URLs and hashtags, and emoji when emoji is set, with
+// placeholder tokens that translation leaves alone. ok is false when no
+// unused marker was found.
+func maskLinks(text string, emoji bool) (masked maskedText, ok bool) {
+	for _, marker := range placeholderMarkers {
+		if strings.Contains(text, marker[0]) || strings.Contains(text, marker[1]) {
+			continue
+		}
+		masked = maskedText{Text: text, open: marker[0], close: marker[1]}
+		// Trailing punctuation almost always ends the sentence, not the URL
+		masked.maskPattern(urlPattern, 0, ".,;:!?)]}'")
+		masked.maskPattern(hashtagPattern, 1, "")
+		if emoji {
+			// The token moves with the words around it, so the emoji ends
+			// up where the translation put them
+			masked.maskPattern(emojiPattern, 0, "")
+		}
+		return masked, true
+	}
+	return maskedText{Text: text}, false
+}
+
+// restore puts the originals back into a translated text. Whitespace the
+// translation inserted inside a token is tolerated.
+func (m maskedText) restore(translated string) (string, error) {
+	if len(m.Originals) == 0 {
+		return translated, nil
+	}
+
+	token := regexp.MustCompile(regexp.QuoteMeta(m.open) + `\s*(\d+)\s*` + regexp.QuoteMeta(m.close))
+	seen := make([]bool, len(m.Originals))
+	var restoreErr error
+	restored := token.ReplaceAllStringFunc(translated, func(match string) string {
+		index, _ := strconv.Atoi(token.FindStringSubmatch(match)[1])
+		if index >= len(m.Originals) {
+			restoreErr = fmt.Errorf("translation contains unknown placeholder %q", match)
+			return match
+		}
+		seen[index] = true
+		return m.Originals[index]
+	})
+	if restoreErr != nil {
+		return "", restoreErr
+	}
+
+	for index, found := range seen {
+		if !found {
+			return "", fmt.Errorf("translation dropped %q", m.Originals[index])
+		}
+	}
+
+	return restored, nil
+}
+
+// placeholderTranslator keeps URLs and hashtags, and emoji when emoji is
+// set, out of the translation.
+type placeholderTranslator struct {
+	Translator
+	emoji bool
+}
+
+func (t placeholderTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	result, err := t.TranslateDetailed(text, sourceLang, targetLang)
+	return result.Text, err
+}
+
+func (t placeholderTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	return t.TranslateDetailedCtx(context.Background(), text, sourceLang, targetLang)
+}
+
+func (t placeholderTranslator) TranslateDetailedCtx(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	masked, ok := maskLinks(text, t.emoji)
+	if !ok || len(masked.Originals) == 0 {
+		return translateWithDetectionCtx(ctx, t.Translator, text, sourceLang, targetLang)
+	}
+
+	translated, err := translateWithDetectionCtx(ctx, t.Translator, masked.Text, sourceLang, targetLang)
+	if err != nil {
+		return TranslationResult{}, err
+	}
+
+	translated.Text, err = masked.restore(translated.Text)
+	if err != nil {
+		return TranslationResult{}, err
+	}
+	return translated, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPlaceholderMarkersAreNoEmoji(t *testing.T) {
+	for _, marker := range placeholderMarkers {
+		for _, rune := range marker {
+			if emojiPattern.MatchString(rune) {
+				t.Errorf("marker %q matches the emoji pattern", rune)
+			}
+		}
+	}
+}
+
+func TestMaskLinks(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		emoji     bool
+		wantText  string
+		originals []string
+	}{
+		{
+			name:      "url with trailing punctuation",
+			text:      "Watch https://example.com/a?b=1. Now",
+			wantText:  "Watch ⟦0⟧. Now",
+			originals: []string{"https://example.com/a?b=1"},
+		},
+		{
+			name:      "hashtags but no anchors",
+			text:      "#go and #2024tips but not a#b or #123",
+			wantText:  "⟦0⟧ and ⟦1⟧ but not a#b or #123",
+			originals: []string{"#go", "#2024tips"},
+		},
+		{
+			name:      "emoji only when asked",
+			text:      "Great 👍🏽 video",
+			wantText:  "Great 👍🏽 video",
+			originals: nil,
+		},
+		{
+			name:      "emoji run with joiner",
+			text:      "Family 👨‍👩‍👧❤️ time",
+			emoji:     true,
+			wantText:  "Family ⟦0⟧ time",
+			originals: []string{"👨‍👩‍👧❤️"},
+		},
+		{
+			name:      "next marker when the text holds the first",
+			text:      "⟦x⟧ www.example.com",
+			wantText:  "⟦x⟧ ⟪0⟫",
+			originals: []string{"www.example.com"},
+		},
+		{
+			name:      "private use markers with emoji",
+			text:      "⟦ ⟪ ☀ www.example.com",
+			emoji:     true,
+			wantText:  "⟦ ⟪ \ue0001\ue001 \ue0000\ue001",
+			originals: []string{"www.example.com", "☀"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			masked, ok := maskLinks(tt.text, tt.emoji)
+			if !ok {
+				t.Fatal("maskLinks() found no marker")
+			}
+			if masked.Text != tt.wantText {
+				t.Errorf("masked text = %q, want %q", masked.Text, tt.wantText)
+			}
+			if strings.Join(masked.Originals, "|") != strings.Join(tt.originals, "|") {
+				t.Errorf("originals = %q, want %q", masked.Originals, tt.originals)
+			}
+
+			restored, err := masked.restore(masked.Text)
+			if err != nil {
+				t.Fatalf("restore() error = %v", err)
+			}
+			if restored != tt.text {
+				t.Errorf("restore() = %q, want %q", restored, tt.text)
+			}
+		})
+	}
+}
+
+func TestMaskLinksNoFreeMarker(t *testing.T) {
+	text := "⟦ ⟪  https://example.com"
+	if _, ok := maskLinks(text, false); ok {
+		t.Fatal("maskLinks() = ok, want no free marker")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	masked := maskedText{Originals: []string{"https://a.example", "#tag"}, open: "⟦", close: "⟧"}
+	tests := []struct {
+		name       string
+		translated string
+		want       string
+		wantErr    bool
+	}{
+		{"reordered", "⟦1⟧ zuerst, dann ⟦0⟧", "#tag zuerst, dann https://a.example", false},
+		{"spaces inside tokens", "⟦ 0 ⟧ und ⟦1 ⟧", "https://a.example und #tag", false},
+		{"dropped token", "nur ⟦0⟧", "", true},
+		{"unknown token", "⟦0⟧ ⟦1⟧ ⟦7⟧", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := masked.restore(tt.translated)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("restore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("restore() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeTranslator answers from a function, for decorator tests.
+type fakeTranslator struct {
+	translate func(text string, sourceLang string, targetLang string) (string, error)
+	languages []DeeplLanguage
+}
+
+func (f fakeTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	return f.translate(text, sourceLang, targetLang)
+}
+
+func (f fakeTranslator) Languages() ([]DeeplLanguage, error) {
+	return f.languages, nil
+}
+
+func TestPlaceholderTranslator(t *testing.T) {
+	var sent string
+	inner := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		sent = text
+		return strings.Replace(text, "Watch", "Schau", 1), nil
+	}}
+
+	got, err := placeholderTranslator{Translator: inner, emoji: true}.Translate("Watch https://example.com 🎉", "", "DE")
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if strings.Contains(sent, "example.com") || strings.Contains(sent, "🎉") {
+		t.Errorf("sent %q to the provider, want the link and emoji masked", sent)
+	}
+	if want := "Schau https://example.com 🎉"; got != want {
+		t.Errorf("Translate() = %q, want %q", got, want)
+	}
+
+	failing := fakeTranslator{translate: func(string, string, string) (string, error) {
+		return "", errors.New("boom")
+	}}
+	if _, err := (placeholderTranslator{Translator: failing}).Translate("see www.example.com", "", "DE"); err == nil {
+		t.Error("Translate() succeeded, want the provider error")
+	}
+}
+
+func TestPlaceholderTranslatorKeepsLinksAndHashtags(t *testing.T) {
+	const description = "New video! Code: https://github.com/user/repo, docs at https://pkg.go.dev/net/http?tab=doc.\n" +
+		"#golang, #deepl and (#youtube)!"
+	keep := []string{"https://github.com/user/repo", "https://pkg.go.dev/net/http?tab=doc", "#golang", "#deepl", "#youtube"}
+
+	// Upper casing would change every link and hashtag that reaches it
+	upper := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		return strings.ToUpper(text), nil
+	}}
+	got, err := placeholderTranslator{Translator: upper}.Translate(description, "", "DE")
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	for _, original := range keep {
+		if !strings.Contains(got, original) {
+			t.Errorf("translation %q lost %q", got, original)
+		}
+	}
+	if !strings.HasPrefix(got, "NEW VIDEO! CODE: ") || !strings.HasSuffix(got, " AND (#youtube)!") {
+		t.Errorf("translation %q, want the rest of the text translated", got)
+	}
+}
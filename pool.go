@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const defaultMaxConcurrency = 4
+
+// runPool calls fn for every target language on at most concurrency
+// goroutines. Once ctx is done no further languages are dispatched and the
+// context error is reported alongside the errors returned by fn. The same
+// happens once fn reports ErrQuotaExceeded, since every further request
+// would fail the same way.
+func runPool(ctx context.Context, concurrency int, targetLangs []string, fn func(targetLang string) error) error {
+	if concurrency <= 0 {
+		concurrency = defaultMaxConcurrency
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		errs     []error
+		stopOnce sync.Once
+	)
+	work := make(chan string)
+	stop := make(chan struct{})
+
+	for i := 0; i < concurrency && i < len(targetLangs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for targetLang := range work {
+				if err := fn(targetLang); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+					if errors.Is(err, ErrQuotaExceeded) {
+						stopOnce.Do(func() { close(stop) })
+					}
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, targetLang := range targetLangs {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case <-stop:
+			break dispatch
+		case work <- targetLang:
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
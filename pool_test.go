@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// enter counts a running call and records the most ever running at once in
+// peak. The returned func counts it as finished.
+func enter(running *atomic.Int64, peak *atomic.Int64) func() {
+	n := running.Add(1)
+	for {
+		old := peak.Load()
+		if n <= old || peak.CompareAndSwap(old, n) {
+			break
+		}
+	}
+	return func() { running.Add(-1) }
+}
+
+func TestRunPoolBound(t *testing.T) {
+	var targets []string
+	for i := 0; i < 20; i++ {
+		targets = append(targets, fmt.Sprintf("L%d", i))
+	}
+
+	tests := []struct {
+		concurrency int
+		wantMax     int64
+	}{
+		{1, 1},
+		{3, 3},
+		{0, defaultMaxConcurrency},
+	}
+	for _, tt := range tests {
+		var running, peak atomic.Int64
+		var mu sync.Mutex
+		done := map[string]bool{}
+		err := runPool(context.Background(), tt.concurrency, targets, func(targetLang string) error {
+			leave := enter(&running, &peak)
+			time.Sleep(time.Millisecond)
+			leave()
+			mu.Lock()
+			done[targetLang] = true
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("runPool(%d) error = %v", tt.concurrency, err)
+		}
+		if peak.Load() > tt.wantMax {
+			t.Errorf("runPool(%d) ran %d at once, want at most %d", tt.concurrency, peak.Load(), tt.wantMax)
+		}
+		if len(done) != len(targets) {
+			t.Errorf("runPool(%d) handled %d of %d languages", tt.concurrency, len(done), len(targets))
+		}
+	}
+}
+
+func TestRunPoolCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int64
+	err := runPool(ctx, 1, []string{"DE", "FR", "ES", "IT"}, func(targetLang string) error {
+		if calls.Add(1) == 1 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("runPool() error = %v, want context.Canceled", err)
+	}
+	if calls.Load() > 2 {
+		t.Errorf("dispatched %d languages after the cancel", calls.Load()-1)
+	}
+}
+
+func TestTranslateTextMultiConcurrent(t *testing.T) {
+	var running, peak atomic.Int64
+	translator := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		defer enter(&running, &peak)()
+		time.Sleep(time.Millisecond)
+		if targetLang == "XX" {
+			return "", errors.New("unsupported")
+		}
+		return targetLang + ":" + text, nil
+	}}
+
+	got, err := translateTextMultiConcurrent(context.Background(), translator, "Hi", "", []string{"DE", "FR", "XX", "ES", "IT"}, 2)
+	if err == nil {
+		t.Error("translateTextMultiConcurrent() succeeded, want the XX error")
+	}
+	if len(got) != 4 || got["IT"] != "IT:Hi" {
+		t.Errorf("translations = %v, want the four working languages", got)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("%d translations ran at once, want at most 2", peak.Load())
+	}
+}
+
+func TestRunPoolStopsOnQuotaExceeded(t *testing.T) {
+	var calls atomic.Int64
+	err := runPool(context.Background(), 1, []string{"DE", "FR", "ES", "IT"}, func(targetLang string) error {
+		calls.Add(1)
+		return &DeeplError{StatusCode: deeplStatusQuotaExceeded}
+	})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("runPool() error = %v, want ErrQuotaExceeded", err)
+	}
+	if calls.Load() > 2 {
+		t.Errorf("dispatched %d languages after the quota ran out", calls.Load()-1)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// progressPrinter prints a line for every finished translation of a video
+// into one language. It is safe for concurrent use, the lines come out in
+// the order of their counter.
+type progressPrinter struct {
+	w     io.Writer
+	total int
+
+	mu   sync.Mutex
+	done int
+}
+
+func newProgressPrinter(w io.Writer, total int) *progressPrinter {
+	return &progressPrinter{w: w, total: total}
+}
+
+// report has the signature of translateVideoOptions.Progress.
+func (p *progressPrinter) report(videoID string, targetLang string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	fmt.Fprintf(p.w, "[%d/%d] video %s → %s\n", p.done, p.total, videoID, targetLang)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProgressPrinterConcurrent(t *testing.T) {
+	const total = 100
+	var buf bytes.Buffer
+	p := newProgressPrinter(&buf, total)
+
+	var wg sync.WaitGroup
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p.report(fmt.Sprintf("video%d", i), "DE")
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != total {
+		t.Fatalf("got %d lines, want %d", len(lines), total)
+	}
+	for i, line := range lines {
+		if prefix := fmt.Sprintf("[%d/%d] video ", i+1, total); !strings.HasPrefix(line, prefix) {
+			t.Errorf("line %d = %q, want prefix %q", i, line, prefix)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	tests := []struct {
+		perSecond float64
+		requests  int
+		// The first request goes out at once, every further one waits an
+		// interval
+		wantMin time.Duration
+	}{
+		{0, 10, 0},
+		{50, 1, 0},
+		{50, 6, 100 * time.Millisecond},
+		{20, 5, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		limiter := newRateLimiter(tt.perSecond)
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < tt.requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := limiter.Wait(context.Background()); err != nil {
+					t.Errorf("Wait() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if elapsed := time.Since(start); elapsed < tt.wantMin {
+			t.Errorf("%d requests at %v/s took %v, want at least %v", tt.requests, tt.perSecond, elapsed, tt.wantMin)
+		}
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	limiter := newRateLimiter(0.1)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestDeeplRequestsShareLimiter(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+	previous := deeplLimiter
+	deeplLimiter = newRateLimiter(20)
+	defer func() { deeplLimiter = previous }()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := translateText("Hello", "deepl-key", "", "DE"); err != nil {
+			t.Fatalf("translateText() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("4 requests at 20/s took %v, want at least 150ms", elapsed)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries  = 3
+	defaultRetryJitter = 0.5
+	retryBaseDelay     = 500 * time.Millisecond
+	retryMaxDelay      = 30 * time.Second
+)
+
+// maxRetries is the number of extra attempts made for a DeepL request that
+// failed with a retryable status. main sets it from the loaded config.
+var maxRetries = defaultMaxRetries
+
+// BackoffStrategy decides how long to wait before retry number attempt,
+// counting from zero.
+type BackoffStrategy interface {
+	NextDelay(attempt int) time.Duration
+}
+
+// exponentialBackoff doubles Base with every attempt up to Max and adds up to
+// Jitter times the delay on top, so concurrent clients don't retry in
+// lockstep.
+type exponentialBackoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+func (b exponentialBackoff) NextDelay(attempt int) time.Duration {
+	delay := b.Base << attempt
+	if delay <= 0 || delay > b.Max {
+		delay = b.Max
+	}
+	if b.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(float64(delay)*b.Jitter) + 1))
+	}
+	return delay
+}
+
+// constantBackoff waits the same time before every attempt.
+type constantBackoff time.Duration
+
+func (b constantBackoff) NextDelay(attempt int) time.Duration {
+	return time.Duration(b)
+}
+
+// backoff is used by doWithRetry. main sets it from the loaded config.
+var backoff BackoffStrategy = exponentialBackoff{Base: retryBaseDelay, Max: retryMaxDelay, Jitter: defaultRetryJitter}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// retryPolicy is how often and how late a request is retried. A nil policy
+// means maxRetries and backoff.
+type retryPolicy struct {
+	MaxRetries int
+	Backoff    BackoffStrategy
+}
+
+func (p *retryPolicy) maxRetries() int {
+	if p == nil {
+		return maxRetries
+	}
+	return p.MaxRetries
+}
+
+func (p *retryPolicy) backoff() BackoffStrategy {
+	if p == nil {
+		return backoff
+	}
+	return p.Backoff
+}
+
+// retryDelay returns how long to wait before the next attempt, honouring a
+// Retry-After header when the server sent one. Retry-After is capped at
+// retryMaxDelay, a server asking for an hour would stall the whole run.
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	var p *retryPolicy
+	return p.retryDelay(resp, attempt)
+}
+
+func (p *retryPolicy) retryDelay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if delay > retryMaxDelay {
+				delay = retryMaxDelay
+			}
+			return delay
+		}
+	}
+	return p.backoff().NextDelay(attempt)
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// doWithRetry sends req, retrying on 429 and 5xx responses with exponential
+// backoff. Other responses, including non-retryable 4xx, are returned as is.
+// Every attempt waits for limiter first, limiter may be nil.
+func doWithRetry(client HTTPDoer, limiter *rateLimiter, req *http.Request) (*http.Response, error) {
+	var p *retryPolicy
+	return p.do(client, limiter, req)
+}
+
+// do is doWithRetry retrying as p says.
+func (p *retryPolicy) do(client HTTPDoer, limiter *rateLimiter, req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if !isRetryableStatus(resp.StatusCode) || attempt >= p.maxRetries() {
+			return resp, nil
+		}
+
+		delay := p.retryDelay(resp, attempt)
+		resp.Body.Close()
+		// Only host and path are logged, the query string may carry an API key
+		slog.Debug("retrying request", "url", req.URL.Host+req.URL.Path,
+			"status", resp.StatusCode, "attempt", attempt+1, "delay", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
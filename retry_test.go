@@ -0,0 +1,238 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useNoBackoff makes retries of the test immediate.
+func useNoBackoff(t *testing.T) {
+	previous := backoff
+	backoff = constantBackoff(0)
+	t.Cleanup(func() { backoff = previous })
+}
+
+func TestDoWithRetry(t *testing.T) {
+	useNoBackoff(t)
+
+	tests := []struct {
+		name      string
+		statuses  []int
+		want      int
+		wantCalls int64
+	}{
+		{"fails twice then succeeds", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, http.StatusOK, 3},
+		{"client errors are not retried", []int{http.StatusForbidden, http.StatusOK}, http.StatusForbidden, 1},
+		{"gives up after max retries", []int{500, 502, 503, 504, 500}, 504, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				if r.Header.Get("X-Test") != "kept" {
+					t.Errorf("attempt %d lost the request headers", n)
+				}
+				status := tt.statuses[n-1]
+				if status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "0")
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			req, err := http.NewRequest("GET", server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Test", "kept")
+			resp, err := doWithRetry(server.Client(), nil, req)
+			if err != nil {
+				t.Fatalf("doWithRetry() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want || calls.Load() != tt.wantCalls {
+				t.Errorf("status %d after %d calls, want %d after %d", resp.StatusCode, calls.Load(), tt.want, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := exponentialBackoff{Base: time.Second, Max: 5 * time.Second}
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := b.NextDelay(attempt); got != want {
+			t.Errorf("NextDelay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+// recordingBackoff records the attempts the retry loop asks a delay for and
+// never waits.
+type recordingBackoff struct {
+	attempts []int
+}
+
+func (b *recordingBackoff) NextDelay(attempt int) time.Duration {
+	b.attempts = append(b.attempts, attempt)
+	return 0
+}
+
+func TestDoWithRetryUsesBackoff(t *testing.T) {
+	strategy := &recordingBackoff{}
+	previous, previousRetries := backoff, maxRetries
+	backoff, maxRetries = strategy, 3
+	defer func() { backoff, maxRetries = previous, previousRetries }()
+
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The second attempt says when to retry, the backoff isn't asked then
+		if calls.Add(1) == 2 {
+			w.Header().Set("Retry-After", "0")
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := doWithRetry(server.Client(), nil, req)
+	if err != nil {
+		t.Fatalf("doWithRetry() error = %v", err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 4 {
+		t.Errorf("sent %d attempts, want 4", calls.Load())
+	}
+	if want := []int{0, 2}; !reflect.DeepEqual(strategy.attempts, want) {
+		t.Errorf("asked the backoff for attempts %v, want %v", strategy.attempts, want)
+	}
+}
+
+func TestNewBackoff(t *testing.T) {
+	zero := 0.0
+	deterministic := newBackoff(Config{RetryJitter: &zero})
+	want := []time.Duration{retryBaseDelay, 2 * retryBaseDelay, 4 * retryBaseDelay}
+	for attempt, delay := range want {
+		if got := deterministic.NextDelay(attempt); got != delay {
+			t.Errorf("NextDelay(%d) = %v, want %v", attempt, got, delay)
+		}
+	}
+	if got := deterministic.NextDelay(62); got != retryMaxDelay {
+		t.Errorf("NextDelay(62) = %v, want the maximum %v", got, retryMaxDelay)
+	}
+
+	jittered := newBackoff(Config{})
+	for i := 0; i < 100; i++ {
+		got := jittered.NextDelay(1)
+		if low, high := 2*retryBaseDelay, time.Duration(float64(2*retryBaseDelay)*(1+defaultRetryJitter)); got < low || got > high {
+			t.Fatalf("NextDelay(1) = %v, want between %v and %v", got, low, high)
+		}
+	}
+
+	if got := constantBackoff(time.Second).NextDelay(7); got != time.Second {
+		t.Errorf("constantBackoff.NextDelay(7) = %v, want 1s", got)
+	}
+}
+
+func TestRetryDelayCapsRetryAfter(t *testing.T) {
+	useNoBackoff(t)
+
+	tests := []struct {
+		retryAfter string
+		want       time.Duration
+	}{
+		{"2", 2 * time.Second},
+		{"3600", retryMaxDelay},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+		if got := retryDelay(resp, 0); got != tt.want {
+			t.Errorf("retryDelay(Retry-After %q) = %v, want %v", tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
+func TestRetryLimit(t *testing.T) {
+	tests := []struct {
+		file      string
+		want      int
+		wantValid bool
+	}{
+		{`{}`, defaultMaxRetries, true},
+		{`{"max_retries":0}`, 0, true},
+		{`{"max_retries":5}`, 5, true},
+		{`{"max_retries":-1}`, -1, false},
+	}
+	for _, tt := range tests {
+		config, err := loadConfig(writeConfig(t, tt.file))
+		if err != nil {
+			t.Fatalf("loadConfig(%s) error = %v", tt.file, err)
+		}
+		if got := retryLimit(config); got != tt.want {
+			t.Errorf("retryLimit(%s) = %d, want %d", tt.file, got, tt.want)
+		}
+		err = config.Validate()
+		if valid := err == nil || !strings.Contains(err.Error(), "max_retries"); valid != tt.wantValid {
+			t.Errorf("Validate(%s) error = %v, want valid %v", tt.file, err, tt.wantValid)
+		}
+	}
+}
+
+func TestDoWithRetryDisabled(t *testing.T) {
+	useNoBackoff(t)
+	previous := maxRetries
+	maxRetries = 0
+	defer func() { maxRetries = previous }()
+
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := doWithRetry(server.Client(), nil, req)
+	if err != nil {
+		t.Fatalf("doWithRetry() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("status %d after %d calls, want 503 after a single call", resp.StatusCode, calls.Load())
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// runState records every (video, language) pair a translate run finished,
+// with its translation, so the next run, e.g. after an interruption, doesn't
+// translate those pairs again. It is safe for concurrent use.
+type runState struct {
+	path string
+	// scope is the stateScope of the run, a different one invalidates the
+	// recorded translations like changed source texts do
+	scope string
+	// force translates every pair again, the new translations are still
+	// recorded
+	force bool
+
+	mu     sync.Mutex
+	Videos map[string]map[string]VideoTranslation `json:"videos"`
+	// Sources holds the sourceHash of every video when it was translated
+	Sources map[string]string `json:"sources"`
+}
+
+// newRunState starts an empty state for a run with scope that is saved to
+// path.
+func newRunState(path string, scope string) *runState {
+	return &runState{
+		path:    path,
+		scope:   scope,
+		Videos:  make(map[string]map[string]VideoTranslation),
+		Sources: make(map[string]string),
+	}
+}
+
+// stateScope describes everything besides the source texts that shapes the
+// translations a translate run records: what cacheScope covers, the source
+// languages, the fields picked and the options changing the output.
+func stateScope(config Config, opts translateOptions, fields videoFields) string {
+	scope := struct {
+		Cache                 string
+		SourceLang            string `json:",omitempty"`
+		TitleSourceLang       string `json:",omitempty"`
+		DescriptionSourceLang string `json:",omitempty"`
+		Fields                videoFields
+		Verify                bool
+		Truncate              bool
+		TitleMax              int
+	}{
+		Cache:                 cacheScope(config),
+		SourceLang:            config.SourceLang,
+		TitleSourceLang:       opts.titleSource,
+		DescriptionSourceLang: opts.descSource,
+		Fields:                fields.orAll(),
+		Verify:                opts.verify,
+		Truncate:              opts.truncate,
+		TitleMax:              opts.titleMax,
+	}
+	data, err := json.Marshal(scope)
+	if err != nil {
+		// Can't happen for these types
+		return scope.Cache
+	}
+	return string(data)
+}
+
+// sourceHash identifies the title and description of video, the texts its
+// translations were made from, together with the scope of the run.
+func sourceHash(video YouTubeVideo, scope string) string {
+	hash := sha256.New()
+	for _, part := range []string{video.Title, video.Description, scope} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// loadRunState reads the state saved at path for a run with scope. A missing
+// file is a fresh start, not an error.
+func loadRunState(path string, scope string) (*runState, error) {
+	state := newRunState(path, scope)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %v", path, err)
+	}
+	if state.Videos == nil {
+		state.Videos = make(map[string]map[string]VideoTranslation)
+	}
+	if state.Sources == nil {
+		state.Sources = make(map[string]string)
+	}
+	return state, nil
+}
+
+// checkSource compares the title and description of video, and the scope of
+// the run, with the ones the recorded translations were made from, and
+// reports whether they changed. The translations of a changed video are
+// dropped, so the video is translated again. Videos recorded before the
+// hashes were kept count as unchanged.
+func (s *runState) checkSource(video YouTubeVideo) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := sourceHash(video, s.scope)
+	recorded, ok := s.Sources[video.ID]
+	changed := ok && recorded != hash
+	if changed {
+		delete(s.Videos, video.ID)
+	}
+	s.Sources[video.ID] = hash
+	return changed
+}
+
+// done returns the translation of videoID into targetLang when an earlier run
+// finished it and the state wasn't forced.
+func (s *runState) done(videoID string, targetLang string) (VideoTranslation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.force {
+		return VideoTranslation{}, false
+	}
+	translation, ok := s.Videos[videoID][targetLang]
+	return translation, ok
+}
+
+// record marks a pair as finished and saves the state right away, so a run
+// dying later loses nothing. Failing to save is only logged, it costs a
+// re-translation in the next run at worst.
+func (s *runState) record(videoID string, targetLang string, translation VideoTranslation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Videos[videoID] == nil {
+		s.Videos[videoID] = make(map[string]VideoTranslation)
+	}
+	s.Videos[videoID][targetLang] = translation
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = writeCacheFile(s.path, string(data)+"\n")
+	}
+	if err != nil {
+		slog.Warn("failed to save state file", "path", s.path, "error", err)
+	}
+}
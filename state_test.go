@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingTranslator translates by prefixing the target language and counts
+// the texts it was sent.
+func countingTranslator(calls *atomic.Int64) fakeTranslator {
+	return fakeTranslator{
+		translate: func(text string, sourceLang string, targetLang string) (string, error) {
+			calls.Add(1)
+			return targetLang + ":" + text, nil
+		},
+		languages: []DeeplLanguage{{Code: "DE", Name: "German"}, {Code: "FR", Name: "French"}},
+	}
+}
+
+func TestRunStateSkipsUnchangedVideos(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	var calls atomic.Int64
+	translator := countingTranslator(&calls)
+	video := YouTubeVideo{ID: "dQw4w9WgXcQ", Title: "Title", Description: "Description"}
+
+	run := func(video YouTubeVideo, scope string, force bool) TranslatedVideo {
+		t.Helper()
+		state, err := loadRunState(path, scope)
+		if err != nil {
+			t.Fatalf("loadRunState() error = %v", err)
+		}
+		state.force = force
+		translated, err := translateVideo(context.Background(), video, translator, []string{"DE", "FR"}, translateVideoOptions{State: state})
+		if err != nil {
+			t.Fatalf("translateVideo() error = %v", err)
+		}
+		return translated
+	}
+
+	tests := []struct {
+		name      string
+		title     string
+		scope     string
+		force     bool
+		wantCalls int64
+		wantTitle string
+	}{
+		{"first run translates everything", "Title", "deepl", false, 4, "DE:Title"},
+		{"unchanged video is skipped", "Title", "deepl", false, 0, "DE:Title"},
+		{"changed video is translated again", "New title", "deepl", false, 4, "DE:New title"},
+		{"changed video is recorded again", "New title", "deepl", false, 0, "DE:New title"},
+		{"changed scope translates again", "New title", "google", false, 4, "DE:New title"},
+		{"force translates again", "New title", "google", true, 4, "DE:New title"},
+		{"forced translations are recorded", "New title", "google", false, 0, "DE:New title"},
+	}
+	for _, tt := range tests {
+		calls.Store(0)
+		video.Title = tt.title
+		translated := run(video, tt.scope, tt.force)
+		if calls.Load() != tt.wantCalls {
+			t.Errorf("%s: %d translations, want %d", tt.name, calls.Load(), tt.wantCalls)
+		}
+		if got := translated.Translations["DE"].Title; got != tt.wantTitle {
+			t.Errorf("%s: title = %q, want %q", tt.name, got, tt.wantTitle)
+		}
+	}
+}
+
+func TestLoadRunState(t *testing.T) {
+	dir := t.TempDir()
+
+	state, err := loadRunState(filepath.Join(dir, "missing.json"), "")
+	if err != nil || len(state.Videos) != 0 {
+		t.Fatalf("loadRunState(missing) = %v, %v, want an empty state", state, err)
+	}
+
+	broken := filepath.Join(dir, "broken.json")
+	os.WriteFile(broken, []byte("{"), 0644)
+	if _, err := loadRunState(broken, ""); err == nil {
+		t.Error("loadRunState(broken) succeeded, want an error")
+	}
+
+	// States from before the source hashes trust their translations
+	legacy := filepath.Join(dir, "legacy.json")
+	os.WriteFile(legacy, []byte(`{"videos":{"v":{"DE":{"title":"Titel"}}}}`), 0644)
+	state, err = loadRunState(legacy, "")
+	if err != nil {
+		t.Fatalf("loadRunState(legacy) error = %v", err)
+	}
+	if state.checkSource(YouTubeVideo{ID: "v", Title: "Title"}) {
+		t.Error("checkSource() reported a change for a video without a recorded hash")
+	}
+	if translation, ok := state.done("v", "DE"); !ok || translation.Title != "Titel" {
+		t.Errorf("done() = %+v, %v, want the recorded translation", translation, ok)
+	}
+}
+
+func TestRunStateRecordSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	state := newRunState(path, "deepl")
+	state.checkSource(YouTubeVideo{ID: "v", Title: "Title"})
+	state.record("v", "DE", VideoTranslation{Title: "Titel"})
+
+	loaded, err := loadRunState(path, "deepl")
+	if err != nil {
+		t.Fatalf("loadRunState() error = %v", err)
+	}
+	if translation, ok := loaded.done("v", "DE"); !ok || translation.Title != "Titel" {
+		t.Errorf("done() = %+v, %v after reload", translation, ok)
+	}
+	if loaded.Sources["v"] != sourceHash(YouTubeVideo{Title: "Title"}, "deepl") {
+		t.Errorf("source hash not saved: %v", loaded.Sources)
+	}
+}
+
+// youtubeVideoHandler answers videos.list with a single video.
+func youtubeVideoHandler(id string, title string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{
+				"id":      id,
+				"snippet": map[string]string{"title": title, "description": "Description"},
+			}},
+		})
+	})
+}
+
+func TestTranslateReusesState(t *testing.T) {
+	useStubServer(t, youtubeVideoHandler("dQw4w9WgXcQ", "Title"))
+	dir := t.TempDir()
+	var calls atomic.Int64
+
+	config := Config{
+		DeeplApiKey:    "deepl-key",
+		YoutubeApiKey:  "youtube-key",
+		YoutubeVideoId: "dQw4w9WgXcQ",
+		TargetLangs:    []string{"DE"},
+		OutputPath:     filepath.Join(dir, "out.json"),
+		StatePath:      filepath.Join(dir, "state.json"),
+		LogLevel:       "error",
+	}
+	newApp := func() *app {
+		return &app{config: config, stdout: &bytes.Buffer{}, stderr: &bytes.Buffer{}, newTranslator: func(Config) (Translator, error) {
+			return countingTranslator(&calls), nil
+		}}
+	}
+
+	tests := []struct {
+		args      []string
+		wantCalls int64
+	}{
+		{nil, 2},
+		{nil, 0},
+		{[]string{"-resume"}, 0},
+		// Other fields or options make other translations
+		{[]string{"-fields", "title"}, 1},
+		{nil, 2},
+		{[]string{"-title-source", "EN"}, 2},
+		{[]string{"-title-source", "EN"}, 0},
+		{[]string{"-title-source", "EN", "-force"}, 2},
+	}
+	for _, tt := range tests {
+		calls.Store(0)
+		if err := newApp().runTranslate(tt.args); err != nil {
+			t.Fatalf("translate %v error = %v", tt.args, err)
+		}
+		if calls.Load() != tt.wantCalls {
+			t.Errorf("translate %v: %d translations, want %d", tt.args, calls.Load(), tt.wantCalls)
+		}
+		output, err := os.ReadFile(config.OutputPath)
+		if err != nil || !strings.Contains(string(output), "DE:Title") {
+			t.Errorf("translate %v: output = %s, %v", tt.args, output, err)
+		}
+	}
+}
+
+func TestTranslateSkipsDonePairs(t *testing.T) {
+	useStubServer(t, youtubeVideoHandler("dQw4w9WgXcQ", "Title"))
+	source := YouTubeVideo{Title: "Title", Description: "Description"}
+	scope := stateScope(Config{}, translateOptions{}, videoFields{})
+	done := `{"videos":{"dQw4w9WgXcQ":{"DE":{"title":"Titel","description":"Beschreibung"}}},` +
+		`"sources":{"dQw4w9WgXcQ":"` + sourceHash(source, scope) + `"}}`
+
+	tests := []struct {
+		name      string
+		state     string
+		args      []string
+		wantCalls int64
+		wantDE    string
+	}{
+		{name: "one pair done", state: done, wantCalls: 2, wantDE: "Titel"},
+		{name: "missing state file", wantCalls: 4, wantDE: "DE:Title"},
+		{name: "forced", state: done, args: []string{"-force"}, wantCalls: 4, wantDE: "DE:Title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var calls atomic.Int64
+			config := Config{
+				DeeplApiKey:    "deepl-key",
+				YoutubeApiKey:  "youtube-key",
+				YoutubeVideoId: "dQw4w9WgXcQ",
+				TargetLangs:    []string{"DE", "FR"},
+				OutputPath:     filepath.Join(dir, "out.json"),
+				StatePath:      filepath.Join(dir, "state.json"),
+				LogLevel:       "error",
+			}
+			if tt.state != "" {
+				os.WriteFile(config.StatePath, []byte(tt.state), 0644)
+			}
+			a := &app{config: config, stdout: &bytes.Buffer{}, stderr: &bytes.Buffer{}, newTranslator: func(Config) (Translator, error) {
+				return countingTranslator(&calls), nil
+			}}
+
+			if err := a.runTranslate(tt.args); err != nil {
+				t.Fatalf("translate %v error = %v", tt.args, err)
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("%d translations, want %d", calls.Load(), tt.wantCalls)
+			}
+
+			data, err := os.ReadFile(config.OutputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var output TranslatedVideo
+			if err := json.Unmarshal(data, &output); err != nil {
+				t.Fatal(err)
+			}
+			if output.Translations["DE"].Title != tt.wantDE || output.Translations["FR"].Title != "FR:Title" {
+				t.Errorf("translations = %+v, want DE %q and FR:Title", output.Translations, tt.wantDE)
+			}
+
+			state, err := loadRunState(config.StatePath, scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := state.done("dQw4w9WgXcQ", "FR"); !ok {
+				t.Error("state file doesn't record FR as done")
+			}
+		})
+	}
+}
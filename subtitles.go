@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type SubtitleCue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// formatTimestamp renders d as HH:MM:SS followed by sep and milliseconds.
+func formatTimestamp(d time.Duration, sep string) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// formatSRT renders cues as SRT. Cues are separated by a single blank line and
+// the output ends with exactly one newline after the last cue.
+func formatSRT(cues []SubtitleCue) string {
+	blocks := make([]string, 0, len(cues))
+	for i, cue := range cues {
+		blocks = append(blocks, fmt.Sprintf("%d\n%s --> %s\n%s",
+			i+1, formatTimestamp(cue.Start, ","), formatTimestamp(cue.End, ","), strings.TrimRight(cue.Text, "\n")))
+	}
+	if len(blocks) == 0 {
+		return ""
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+func writeSRT(w io.Writer, path string, cues []SubtitleCue) error {
+	return writeTextFile(w, path, formatSRT(cues))
+}
+
+// formatVTT renders cues as WebVTT. Cue identifiers are optional in VTT and
+// left out, an empty cue list yields just the header.
+func formatVTT(cues []SubtitleCue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&b, "\n%s --> %s\n%s\n",
+			formatTimestamp(cue.Start, "."), formatTimestamp(cue.End, "."), strings.TrimRight(cue.Text, "\n"))
+	}
+	return b.String()
+}
+
+func writeVTT(w io.Writer, path string, cues []SubtitleCue) error {
+	return writeTextFile(w, path, formatVTT(cues))
+}
+
+// writeTextFile writes content to path, or to w when path is empty.
+func writeTextFile(w io.Writer, path string, content string) error {
+	if path == "" {
+		_, err := io.WriteString(w, content)
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+var srtTimingPattern = regexp.MustCompile(`^(\d+):(\d{2}):(\d{2})[,.](\d{3})\s*-->\s*(\d+):(\d{2}):(\d{2})[,.](\d{3})`)
+
+func parseTimestamp(hours, minutes, seconds, millis string) time.Duration {
+	h, _ := strconv.Atoi(hours)
+	m, _ := strconv.Atoi(minutes)
+	s, _ := strconv.Atoi(seconds)
+	ms, _ := strconv.Atoi(millis)
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+		time.Duration(s)*time.Second + time.Duration(ms)*time.Millisecond
+}
+
+// parseSRT parses SRT content into cues. Errors name the offending line.
+func parseSRT(content string) ([]SubtitleCue, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	var cues []SubtitleCue
+	for i := 0; i < len(lines); {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+
+		// The numeric cue identifier is optional in practice
+		if _, err := strconv.Atoi(strings.TrimSpace(lines[i])); err == nil {
+			i++
+		}
+		if i >= len(lines) {
+			return nil, fmt.Errorf("line %d: expected cue timing, got end of file", i)
+		}
+
+		timing := srtTimingPattern.FindStringSubmatch(strings.TrimSpace(lines[i]))
+		if timing == nil {
+			return nil, fmt.Errorf("line %d: invalid cue timing %q", i+1, lines[i])
+		}
+		cue := SubtitleCue{
+			Start: parseTimestamp(timing[1], timing[2], timing[3], timing[4]),
+			End:   parseTimestamp(timing[5], timing[6], timing[7], timing[8]),
+		}
+		if cue.End < cue.Start {
+			return nil, fmt.Errorf("line %d: cue ends before it starts", i+1)
+		}
+		i++
+
+		var text []string
+		for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+			text = append(text, lines[i])
+		}
+		cue.Text = strings.Join(text, "\n")
+		cues = append(cues, cue)
+	}
+
+	return cues, nil
+}
+
+// translateCues translates the text of every cue, keeping the timing as is.
+func translateCues(cues []SubtitleCue, t Translator, sourceLang string, targetLang string) ([]SubtitleCue, error) {
+	translated := make([]SubtitleCue, len(cues))
+	for i, cue := range cues {
+		text, err := t.Translate(cue.Text, sourceLang, targetLang)
+		if err != nil {
+			return nil, fmt.Errorf("failed to translate cue %d: %w", i+1, err)
+		}
+		translated[i] = SubtitleCue{Start: cue.Start, End: cue.End, Text: text}
+	}
+	return translated, nil
+}
+
+// sentenceGap is the pause between two cues that restorePunctuation takes as
+// the end of a sentence.
+const sentenceGap = time.Second
+
+// restorePunctuation adds the sentence breaks auto-generated captions lack:
+// a cue followed by a pause of at least gap, or the last cue, ends with a
+// period unless it already ends in punctuation, and the cue after a break
+// starts with a capital letter. Without them DeepL translates one endless
+// sentence.
+func restorePunctuation(cues []SubtitleCue, gap time.Duration) []SubtitleCue {
+	restored := make([]SubtitleCue, len(cues))
+	sentenceStart := true
+	for i, cue := range cues {
+		text := strings.TrimSpace(cue.Text)
+		if sentenceStart {
+			text = capitalizeFirst(text)
+		}
+
+		sentenceEnd := i == len(cues)-1 || cues[i+1].Start-cue.End >= gap
+		if sentenceEnd && text != "" && !strings.ContainsAny(text[len(text)-1:], ".!?,;:") && !strings.HasSuffix(text, "…") {
+			text += "."
+		}
+		if text != "" {
+			sentenceStart = strings.ContainsAny(text[len(text)-1:], ".!?") || strings.HasSuffix(text, "…")
+		}
+
+		restored[i] = SubtitleCue{Start: cue.Start, End: cue.End, Text: text}
+	}
+	return restored
+}
+
+func capitalizeFirst(text string) string {
+	for i, r := range text {
+		return text[:i] + strings.ToUpper(string(r)) + text[i+utf8.RuneLen(r):]
+	}
+	return text
+}
+
+type srtFileOptions struct {
+	SourceLang string
+	// RestorePunctuation runs restorePunctuation before translating, for
+	// auto-generated captions
+	RestorePunctuation bool
+}
+
+// translateSRTFile translates every cue of the SRT file at inputPath into
+// targetLang and writes the result to outputPath, or to w when it is empty,
+// keeping the timings as they are. t is used as is, so pass one from
+// newTranslator to get caching and rate limiting.
+func translateSRTFile(w io.Writer, inputPath string, outputPath string, targetLang string, t Translator, opts srtFileOptions) error {
+	content, err := os.ReadFile(inputPath)
+	if err != nil {
+		return err
+	}
+
+	cues, err := parseSRT(string(content))
+	if err != nil {
+		return fmt.Errorf("%s: %w", inputPath, err)
+	}
+
+	if opts.RestorePunctuation {
+		cues = restorePunctuation(cues, sentenceGap)
+	}
+
+	translated, err := translateCues(cues, t, opts.SourceLang, targetLang)
+	if err != nil {
+		return err
+	}
+
+	return writeSRT(w, outputPath, translated)
+}
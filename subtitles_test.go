@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "00:00:00,000"},
+		{1500 * time.Millisecond, "00:00:01,500"},
+		{time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond, "01:02:03,004"},
+		{-time.Second, "00:00:00,000"},
+	}
+	for _, tt := range tests {
+		if got := formatTimestamp(tt.d, ","); got != tt.want {
+			t.Errorf("formatTimestamp(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestWriteSRT(t *testing.T) {
+	tests := []struct {
+		name string
+		cues []SubtitleCue
+		want string
+	}{
+		{
+			name: "cues",
+			cues: []SubtitleCue{
+				{Start: time.Second, End: 2500 * time.Millisecond, Text: "Hello"},
+				{Start: 3 * time.Second, End: 3 * time.Second, Text: "Zero duration"},
+				{Start: time.Hour, End: time.Hour + time.Second, Text: "Two\nlines\n"},
+			},
+			want: "1\n00:00:01,000 --> 00:00:02,500\nHello\n\n" +
+				"2\n00:00:03,000 --> 00:00:03,000\nZero duration\n\n" +
+				"3\n01:00:00,000 --> 01:00:01,000\nTwo\nlines\n",
+		},
+		{name: "no cues", want: ""},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeSRT(&buf, "", tt.cues); err != nil {
+			t.Fatalf("%s: writeSRT() error = %v", tt.name, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: writeSRT() = %q, want %q", tt.name, buf.String(), tt.want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "out.srt")
+	if err := writeSRT(nil, path, tests[0].cues); err != nil {
+		t.Fatalf("writeSRT(%s) error = %v", path, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != tests[0].want {
+		t.Errorf("%s holds %q, want %q", path, data, tests[0].want)
+	}
+}
+
+func TestWriteVTT(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "cues.vtt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cues []SubtitleCue
+		want string
+	}{
+		{
+			name: "golden",
+			cues: []SubtitleCue{
+				{Start: time.Second, End: 2500 * time.Millisecond, Text: "Hello"},
+				{Start: time.Minute + 3040*time.Millisecond, End: time.Minute + 5*time.Second, Text: "Two\nlines\n"},
+				{Start: time.Hour, End: time.Hour + time.Second, Text: "The end"},
+			},
+			want: string(golden),
+		},
+		{name: "no cues", want: "WEBVTT\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeVTT(&buf, "", tt.cues); err != nil {
+			t.Fatalf("%s: writeVTT() error = %v", tt.name, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: writeVTT() = %q, want %q", tt.name, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestTranslateSRTFile(t *testing.T) {
+	upper := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		return strings.ToUpper(text), nil
+	}}
+	input := filepath.Join("testdata", "cues.srt")
+	output := filepath.Join(t.TempDir(), "cues.de.srt")
+
+	if err := translateSRTFile(nil, input, output, "DE", upper, srtFileOptions{}); err != nil {
+		t.Fatalf("translateSRTFile() error = %v", err)
+	}
+	original, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	translated, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Timings and numbering stay byte for byte, only the text changes
+	if string(translated) != strings.ToUpper(string(original)) {
+		t.Errorf("translated SRT = %q, want %q", translated, strings.ToUpper(string(original)))
+	}
+}
+
+func TestTranslateSRTFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "broken.srt")
+	if err := os.WriteFile(malformed, []byte("1\n00:00:01,000 --> 00:00:02,000\nHi\n\n2\n00:00:03 --> 00:00:04\nThere\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	failing := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		return "", errors.New("quota")
+	}}
+	echo := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		return text, nil
+	}}
+
+	tests := []struct {
+		name       string
+		input      string
+		translator Translator
+		want       []string
+	}{
+		{"malformed cue", malformed, echo, []string{malformed, "line 6"}},
+		{"missing file", filepath.Join(dir, "missing.srt"), echo, []string{"missing.srt"}},
+		{"failed cue", filepath.Join("testdata", "cues.srt"), failing, []string{"cue 1", "quota"}},
+	}
+	for _, tt := range tests {
+		output := filepath.Join(dir, "out.srt")
+		err := translateSRTFile(nil, tt.input, output, "DE", tt.translator, srtFileOptions{})
+		for _, want := range tt.want {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: translateSRTFile() error = %v, want it to contain %q", tt.name, err, want)
+			}
+		}
+		if _, err := os.Stat(output); err == nil {
+			t.Errorf("%s: wrote %s despite the error", tt.name, output)
+		}
+	}
+}
+
+func TestRestorePunctuation(t *testing.T) {
+	cue := func(start, end time.Duration, text string) SubtitleCue {
+		return SubtitleCue{Start: start, End: end, Text: text}
+	}
+	tests := []struct {
+		name string
+		cues []SubtitleCue
+		want []string
+	}{
+		{
+			name: "long gaps end sentences",
+			cues: []SubtitleCue{
+				cue(0, time.Second, "so today we"),
+				cue(1200*time.Millisecond, 2*time.Second, "make pasta"),
+				cue(4*time.Second, 5*time.Second, "first the water"),
+			},
+			want: []string{"So today we", "make pasta.", "First the water."},
+		},
+		{
+			name: "existing punctuation is kept",
+			cues: []SubtitleCue{
+				cue(0, time.Second, "really?"),
+				cue(3*time.Second, 4*time.Second, "wait,"),
+				cue(6*time.Second, 7*time.Second, "okay…"),
+				cue(9*time.Second, 10*time.Second, "  überall  "),
+			},
+			want: []string{"Really?", "Wait,", "okay…", "Überall."},
+		},
+		{
+			name: "empty cues",
+			cues: []SubtitleCue{cue(0, time.Second, ""), cue(3*time.Second, 4*time.Second, "hi")},
+			want: []string{"", "Hi."},
+		},
+	}
+	for _, tt := range tests {
+		restored := restorePunctuation(tt.cues, sentenceGap)
+		var got []string
+		for i, cue := range restored {
+			got = append(got, cue.Text)
+			if cue.Start != tt.cues[i].Start || cue.End != tt.cues[i].End {
+				t.Errorf("%s: cue %d timing changed", tt.name, i)
+			}
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: restorePunctuation() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateSRTFileRestoresPunctuation(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "asr.srt")
+	asr := "1\n00:00:01,000 --> 00:00:02,000\nwelcome back\n\n2\n00:00:02,100 --> 00:00:03,000\ntoday we cook\n\n" +
+		"3\n00:00:05,000 --> 00:00:06,000\nlet's go\n"
+	if err := os.WriteFile(input, []byte(asr), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		restore  bool
+		wantSent []string
+	}{
+		{false, []string{"welcome back", "today we cook", "let's go"}},
+		{true, []string{"Welcome back", "today we cook.", "Let's go."}},
+	}
+	for _, tt := range tests {
+		var sent []string
+		recording := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+			sent = append(sent, text)
+			return text, nil
+		}}
+		if err := translateSRTFile(&bytes.Buffer{}, input, "", "DE", recording, srtFileOptions{RestorePunctuation: tt.restore}); err != nil {
+			t.Fatalf("translateSRTFile() error = %v", err)
+		}
+		if strings.Join(sent, "|") != strings.Join(tt.wantSent, "|") {
+			t.Errorf("restore %v: sent %q, want %q", tt.restore, sent, tt.wantSent)
+		}
+	}
+}
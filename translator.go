@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	providerDeepl  = "deepl"
+	providerGoogle = "google"
+	providerAzure  = "azure"
+)
+
+// Translator is implemented by every translation backend.
+type Translator interface {
+	Translate(text string, sourceLang string, targetLang string) (string, error)
+	Languages() ([]DeeplLanguage, error)
+}
+
+// DetailedTranslator is implemented by translators that also report the
+// source language they detected.
+type DetailedTranslator interface {
+	TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error)
+}
+
+// ContextTranslator is implemented by translators whose requests stop once
+// ctx is done. Every backend and decorator newTranslator builds has it.
+type ContextTranslator interface {
+	TranslateDetailedCtx(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error)
+}
+
+// providerLanguages converts the languages a provider lists under its own
+// codes into DeepL codes. Next to the code from maps a language to, every
+// DeepL code that to sends as that language is listed, e.g. EN-GB and EN-US
+// for "en", so validating targets accepts exactly what the provider handles.
+func providerLanguages(listed []DeeplLanguage, variants map[string]string, to func(string) string, from func(string) string) []DeeplLanguage {
+	deeplCodes := make([]string, 0, len(variants))
+	for code := range variants {
+		deeplCodes = append(deeplCodes, code)
+	}
+	sort.Strings(deeplCodes)
+
+	languages := make([]DeeplLanguage, 0, len(listed))
+	seen := make(map[string]bool, len(listed))
+	for _, lang := range listed {
+		candidates := append([]string{from(lang.Code), strings.ToUpper(lang.Code)}, deeplCodes...)
+		for i, code := range candidates {
+			// The code from picks is listed even when to maps it elsewhere
+			if seen[code] || (i > 0 && to(code) != lang.Code) {
+				continue
+			}
+			seen[code] = true
+			languages = append(languages, DeeplLanguage{Code: code, Name: lang.Name})
+		}
+	}
+	return languages
+}
+
+// translateWithDetection uses the TranslateDetailed method of t when it has
+// one, otherwise the detected language stays empty.
+func translateWithDetection(t Translator, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	if detailed, ok := t.(DetailedTranslator); ok {
+		return detailed.TranslateDetailed(text, sourceLang, targetLang)
+	}
+	translated, err := t.Translate(text, sourceLang, targetLang)
+	return TranslationResult{Text: translated}, err
+}
+
+// translateWithDetectionCtx is translateWithDetection that passes ctx on to
+// a ContextTranslator. Other translators can't be interrupted, for them ctx
+// is only checked before the call.
+func translateWithDetectionCtx(ctx context.Context, t Translator, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	if ctxTranslator, ok := t.(ContextTranslator); ok {
+		return ctxTranslator.TranslateDetailedCtx(ctx, text, sourceLang, targetLang)
+	}
+	if err := ctx.Err(); err != nil {
+		return TranslationResult{}, err
+	}
+	return translateWithDetection(t, text, sourceLang, targetLang)
+}
+
+// newTranslator builds the backend selected by config.Provider, with
+// translations cached as config.CacheBackend says.
+func newTranslator(config Config) (Translator, error) {
+	// Each provider gets its own cache so their translations never mix
+	return newTranslatorWith(config, globalAPISettings(), newCache(config, config.Provider))
+}
+
+// newTranslatorWith is newTranslator sending its requests as settings say,
+// with translations kept in cache.
+func newTranslatorWith(config Config, settings apiSettings, cache TranslationCache) (Translator, error) {
+	var translator Translator
+	switch config.Provider {
+	case providerDeepl, "":
+		deepl := newDeeplTranslator(config.DeeplApiKey)
+		deepl.BaseURL, deepl.Client, deepl.Limiter, deepl.Retry = settings.DeeplBaseURL, settings.Client, settings.Limiter, settings.Retry
+		deepl.Options = deeplOptions(config)
+		translator = deepl
+	case providerGoogle:
+		google := newGoogleTranslator(config.GoogleApiKey)
+		google.Client, google.Retry = settings.Client, settings.Retry
+		translator = google
+	case providerAzure:
+		azure := newAzureTranslator(config.AzureApiKey, config.AzureRegion)
+		azure.Client, azure.Retry = settings.Client, settings.Retry
+		translator = azure
+	default:
+		return nil, fmt.Errorf("unknown translation provider %q", config.Provider)
+	}
+
+	translator = metricsTranslator{Translator: translator, provider: config.Provider, metrics: runMetrics}
+	translator = chunkedTranslator{Translator: translator, maxChars: config.MaxChunkChars}
+	translator = placeholderTranslator{Translator: translator, emoji: config.PreserveEmoji}
+	translator = chapterTranslator{Translator: translator}
+
+	if cache != nil {
+		translator = cachedTranslator{Translator: translator, cache: cache, scope: cacheScope(config)}
+	}
+
+	return translator, nil
+}
+
+// deeplOptions are the DeepL request options config asks for.
+func deeplOptions(config Config) TranslateOptions {
+	return TranslateOptions{
+		Formality:     config.Formality,
+		GlossaryID:    config.GlossaryID,
+		TagHandling:   config.TagHandling,
+		IgnoreTags:    config.IgnoreTags,
+		SplittingTags: config.SplittingTags,
+
+		OutlineDetection:   config.OutlineDetection,
+		PreserveFormatting: config.PreserveFormatting,
+		SplitSentences:     config.SplitSentences,
+	}
+}
+
+// newTitleHintTranslator translates titles again for -title-max, with a
+// DeepL context asking to keep them under maxChars characters. The other
+// providers take no context, for them it returns nil. The hinted
+// translations aren't cached since the cache key doesn't know the hint.
+func newTitleHintTranslator(config Config, maxChars int) Translator {
+	if config.Provider != providerDeepl && config.Provider != "" {
+		return nil
+	}
+	deepl := newDeeplTranslator(config.DeeplApiKey)
+	deepl.Options = deeplOptions(config)
+	deepl.Options.Context = fmt.Sprintf("This is the title of a YouTube video. Keep its translation shorter than %d characters.", maxChars)
+
+	var translator Translator = metricsTranslator{Translator: deepl, provider: providerDeepl, metrics: runMetrics}
+	return placeholderTranslator{Translator: translator, emoji: config.PreserveEmoji}
+}
+
+// translateTags translates each video tag separately so they stay usable as
+// individual tags. A video without tags yields a nil slice.
+func translateTags(tags []string, apiKey string, targetLang string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	translatedTags := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		translatedTag, err := translateText(tag, apiKey, "", targetLang)
+		if err != nil {
+			return nil, fmt.Errorf("failed to translate tag %q: %w", tag, err)
+		}
+		translatedTags = append(translatedTags, translatedTag)
+	}
+
+	return translatedTags, nil
+}
+
+// translateTextMulti translates text into every target language. Languages
+// that fail are left out of the returned map and reported in the combined error.
+func translateTextMulti(text string, apiKey string, targetLangs []string) (map[string]string, error) {
+	return translateTextMultiConcurrent(context.Background(), newDeeplTranslator(apiKey), text, "", targetLangs, defaultMaxConcurrency)
+}
+
+// translateTextMultiConcurrent is translateTextMulti for any Translator,
+// translating up to concurrency languages at the same time.
+func translateTextMultiConcurrent(ctx context.Context, t Translator, text string, sourceLang string, targetLangs []string, concurrency int) (map[string]string, error) {
+	translations := make(map[string]string, len(targetLangs))
+	var mu sync.Mutex
+
+	err := runPool(ctx, concurrency, targetLangs, func(targetLang string) error {
+		translated, err := translateWithDetectionCtx(ctx, t, text, sourceLang, targetLang)
+		if err != nil {
+			return fmt.Errorf("%s: %w", targetLang, err)
+		}
+		mu.Lock()
+		translations[targetLang] = translated.Text
+		mu.Unlock()
+		return nil
+	})
+
+	return translations, err
+}
+
+// videoFields picks the parts of a video that get translated.
+type videoFields struct {
+	Title       bool
+	Description bool
+}
+
+var allVideoFields = videoFields{Title: true, Description: true}
+
+// parseVideoFields parses a comma separated list such as "title" or
+// "title,description".
+func parseVideoFields(list string) (videoFields, error) {
+	var fields videoFields
+	for _, name := range strings.Split(list, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "title":
+			fields.Title = true
+		case "description":
+			fields.Description = true
+		default:
+			return videoFields{}, fmt.Errorf("unknown field %q, expected \"title\" or \"description\"", strings.TrimSpace(name))
+		}
+	}
+	return fields, nil
+}
+
+// orAll makes the zero value mean every field.
+func (f videoFields) orAll() videoFields {
+	if f == (videoFields{}) {
+		return allVideoFields
+	}
+	return f
+}
+
+type translateVideoOptions struct {
+	SourceLang string
+	// TitleSourceLang and DescriptionSourceLang override SourceLang for one
+	// field, for videos whose title and description are in different
+	// languages
+	TitleSourceLang       string
+	DescriptionSourceLang string
+	Concurrency           int
+	// Fields that aren't picked keep their original text. The zero value
+	// translates every field.
+	Fields videoFields
+	// Progress, when set, is called once a video is done for a language,
+	// whether or not that succeeded. Calls may come from several goroutines.
+	Progress func(videoID string, targetLang string)
+	// State, when set, supplies the pairs an earlier run finished and records
+	// the ones this run finishes. Videos whose title or description changed
+	// since are translated again.
+	State *runState
+	// Verify translates every translation back and records its similarity
+	// to the original.
+	Verify bool
+	// Truncate cuts translated descriptions down to what YouTube accepts,
+	// otherwise they are only reported.
+	Truncate bool
+	// TitleMax, when set, is the most characters a translated title should
+	// have. Longer titles are translated again with ShortTitles when it is
+	// set, and truncated when they still don't fit.
+	TitleMax    int
+	ShortTitles Translator
+}
+
+// translateVideo translates the title and description into every target
+// language. Languages that fail are missing from the result and reported in
+// the combined error. When only one field fails the language stays in the
+// result with that field's error, and is reported as well.
+func translateVideo(ctx context.Context, video YouTubeVideo, t Translator, targetLangs []string, opts translateVideoOptions) (TranslatedVideo, error) {
+	result := TranslatedVideo{
+		VideoID:      video.ID,
+		Title:        video.Title,
+		Description:  video.Description,
+		Translations: make(map[string]VideoTranslation, len(targetLangs)),
+	}
+	fields := opts.Fields.orAll()
+	titleSource := orDefault(opts.TitleSourceLang, opts.SourceLang)
+	descriptionSource := orDefault(opts.DescriptionSourceLang, opts.SourceLang)
+	var (
+		mu              sync.Mutex
+		titleLang       string
+		descriptionLang string
+	)
+
+	if opts.State != nil && opts.State.checkSource(video) {
+		slog.Info("video changed since its translations were recorded, translating it again", "video", video.ID)
+	}
+
+	err := runPool(ctx, opts.Concurrency, targetLangs, func(targetLang string) error {
+		if opts.Progress != nil {
+			defer opts.Progress(video.ID, targetLang)
+		}
+		if opts.State != nil {
+			if translation, ok := opts.State.done(video.ID, targetLang); ok {
+				mu.Lock()
+				result.Translations[targetLang] = translation
+				mu.Unlock()
+				return nil
+			}
+		}
+
+		// A failing field doesn't cost the other one, the language is kept
+		// with the error attached to the failed field
+		var titleErr, descriptionErr error
+		title := TranslationResult{Text: video.Title}
+		if fields.Title {
+			title, titleErr = translateFieldCtx(ctx, t, video.Title, titleSource, targetLang)
+			if titleErr != nil {
+				titleErr = fmt.Errorf("failed to translate title to %s: %w", targetLang, titleErr)
+			} else {
+				title = fitTitle(ctx, video, title, titleSource, targetLang, opts)
+			}
+		}
+		description := TranslationResult{Text: video.Description}
+		if fields.Description {
+			description, descriptionErr = translateFieldCtx(ctx, t, video.Description, descriptionSource, targetLang)
+			if descriptionErr != nil {
+				descriptionErr = fmt.Errorf("failed to translate description to %s: %w", targetLang, descriptionErr)
+			} else {
+				description.Text = fitDescription(video.ID, targetLang, description.Text, opts.Truncate)
+			}
+		}
+		fieldErr := errors.Join(titleErr, descriptionErr)
+		if (!fields.Title || titleErr != nil) && (!fields.Description || descriptionErr != nil) {
+			return fieldErr
+		}
+
+		translation := VideoTranslation{Title: title.Text, Description: description.Text}
+		if titleErr != nil {
+			translation.TitleError = titleErr.Error()
+		}
+		if descriptionErr != nil {
+			translation.DescriptionError = descriptionErr.Error()
+		}
+		// The round trips are billed like any other translation
+		var verifyBilled, billed int
+		if opts.Verify {
+			if fields.Title && titleErr == nil {
+				sourceLang := orDefault(titleSource, title.DetectedSourceLanguage)
+				translation.TitleSimilarity, billed = verifyField(t, video.Title, title.Text, sourceLang, targetLang)
+				verifyBilled += billed
+			}
+			if fields.Description && descriptionErr == nil {
+				sourceLang := orDefault(descriptionSource, description.DetectedSourceLanguage)
+				translation.DescriptionSimilarity, billed = verifyField(t, video.Description, description.Text, sourceLang, targetLang)
+				verifyBilled += billed
+			}
+		}
+		// Only complete languages count as done, so the next run retries the rest
+		if opts.State != nil && fieldErr == nil {
+			opts.State.record(video.ID, targetLang, translation)
+		}
+		mu.Lock()
+		result.Translations[targetLang] = translation
+		result.BilledCharacters += title.BilledCharacters + description.BilledCharacters + verifyBilled
+		if titleLang == "" {
+			titleLang = title.DetectedSourceLanguage
+		}
+		if descriptionLang == "" {
+			descriptionLang = description.DetectedSourceLanguage
+		}
+		mu.Unlock()
+		return fieldErr
+	})
+
+	if titleSource == "" && descriptionSource == "" {
+		warnOnLanguageMismatch(video.ID, titleLang, descriptionLang)
+	}
+
+	return result, err
+}
+
+// orDefault returns value, or fallback when value is empty.
+func orDefault(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// translatePlaylist translates the title and description of the playlist
+// itself the same way translateVideo does for a video.
+func translatePlaylist(ctx context.Context, playlist YouTubePlaylist, t Translator, targetLangs []string, opts translateVideoOptions) (TranslatedVideo, error) {
+	video := YouTubeVideo{ID: playlist.ID, Title: playlist.Title, Description: playlist.Description}
+	return translateVideo(ctx, video, t, targetLangs, opts)
+}
+
+// fitTitle shortens a translated title longer than opts.TitleMax. It asks
+// opts.ShortTitles for a shorter translation first and truncates at a word
+// boundary when that fails or is still too long. This is best effort, the
+// title is never dropped.
+func fitTitle(ctx context.Context, video YouTubeVideo, title TranslationResult, sourceLang string, targetLang string, opts translateVideoOptions) TranslationResult {
+	length := utf8.RuneCountInString(title.Text)
+	if opts.TitleMax <= 0 || length <= opts.TitleMax {
+		return title
+	}
+	slog.Warn("translated title is longer than -title-max",
+		"video", video.ID, "target_lang", targetLang, "characters", length, "limit", opts.TitleMax)
+
+	if opts.ShortTitles != nil {
+		shorter, err := translateFieldCtx(ctx, opts.ShortTitles, video.Title, sourceLang, targetLang)
+		title.BilledCharacters += shorter.BilledCharacters
+		if err != nil {
+			slog.Warn("failed to ask for a shorter title", "video", video.ID, "target_lang", targetLang, "error", err)
+		} else {
+			// Still too long it is at least closer to what gets truncated
+			title.Text = shorter.Text
+		}
+	}
+	title.Text = truncateAtWord(title.Text, opts.TitleMax)
+	return title
+}
+
+// verifyField scores a translated field by translating it back, and returns
+// the score with the characters the back translation was billed. A failed
+// round trip only costs the score, the translation itself is fine.
+func verifyField(t Translator, original string, translated string, sourceLang string, targetLang string) (*float64, int) {
+	if strings.TrimSpace(original) == "" {
+		return nil, 0
+	}
+	back, score, err := roundTrip(original, translated, sourceLang, targetLang, t)
+	if err != nil {
+		slog.Warn("failed to translate back for -verify", "target_lang", targetLang, "error", err)
+		return nil, 0
+	}
+	return &score, back.BilledCharacters
+}
+
+// warnOnLanguageMismatch logs a warning when the title and the description
+// were detected as different languages, and reports whether it did. Unknown
+// languages never count as a mismatch.
+func warnOnLanguageMismatch(videoID string, titleLang string, descriptionLang string) bool {
+	if titleLang == "" || descriptionLang == "" || strings.EqualFold(baseLanguage(titleLang), baseLanguage(descriptionLang)) {
+		return false
+	}
+	slog.Warn("title and description are in different languages, set source_lang if the translations look off",
+		"video", videoID, "title_lang", titleLang, "description_lang", descriptionLang)
+	return true
+}
+
+// translateFieldCtx translates a single metadata field and gives up once ctx
+// is done, the request in flight is cancelled with it. Empty fields, e.g. a
+// video without a description, stay empty without calling the API.
+func translateFieldCtx(ctx context.Context, t Translator, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	if err := ctx.Err(); err != nil {
+		return TranslationResult{}, err
+	}
+	if strings.TrimSpace(text) == "" {
+		return TranslationResult{}, nil
+	}
+	return translateWithDetectionCtx(ctx, t, text, sourceLang, targetLang)
+}
+
+// translateVideos translates every video in turn. A failing video doesn't
+// stop the others, its error is part of the combined error. Running out of
+// DeepL quota stops the remaining videos.
+func translateVideos(ctx context.Context, videos []YouTubeVideo, t Translator, targetLangs []string, opts translateVideoOptions) ([]TranslatedVideo, error) {
+	results := make([]TranslatedVideo, 0, len(videos))
+	stream := make(chan TranslatedVideo)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- streamVideos(ctx, videos, t, targetLangs, opts, stream)
+	}()
+
+	for result := range stream {
+		results = append(results, result)
+	}
+
+	return results, <-errc
+}
+
+// streamVideos works like translateVideos but sends every result on results
+// as soon as it is done, and closes results when it returns.
+func streamVideos(ctx context.Context, videos []YouTubeVideo, t Translator, targetLangs []string, opts translateVideoOptions, results chan<- TranslatedVideo) error {
+	defer close(results)
+	var errs []error
+
+	for _, video := range videos {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		result, err := translateVideo(ctx, video, t, targetLangs, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("video %s: %w", video.ID, err))
+		}
+		results <- result
+		if errors.Is(err, ErrQuotaExceeded) {
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// estimateCost returns the number of characters translating every text into
+// every target language bills, since DeepL charges per character per target.
+func estimateCost(texts []string, targetLangs []string) int {
+	return countCharacters(texts) * len(targetLangs)
+}
+
+func countCharacters(texts []string) int {
+	characters := 0
+	for _, text := range texts {
+		characters += utf8.RuneCountInString(text)
+	}
+	return characters
+}
+
+// videoTexts lists the texts translateVideo sends for the given videos.
+func videoTexts(videos []YouTubeVideo, fields videoFields) []string {
+	fields = fields.orAll()
+	texts := make([]string, 0, 2*len(videos))
+	for _, video := range videos {
+		if fields.Title {
+			texts = append(texts, video.Title)
+		}
+		if fields.Description {
+			texts = append(texts, video.Description)
+		}
+	}
+	return texts
+}
@@ -0,0 +1,647 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTranslateTextMulti(t *testing.T) {
+	useDeeplStub(t, &deeplRecorder{fail: map[string]int{"XX": http.StatusBadRequest}})
+
+	tests := []struct {
+		name    string
+		targets []string
+		want    map[string]string
+		wantErr string
+	}{
+		{"all languages", []string{"DE", "FR"}, map[string]string{"DE": "DE:Hello", "FR": "FR:Hello"}, ""},
+		{"keeps the others on failure", []string{"DE", "XX"}, map[string]string{"DE": "DE:Hello"}, "XX: "},
+		{"no languages", nil, map[string]string{}, ""},
+	}
+	for _, tt := range tests {
+		got, err := translateTextMulti("Hello", "deepl-key", tt.targets)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: error = %v, want one mentioning %q", tt.name, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: translations = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateTags(t *testing.T) {
+	useDeeplStub(t, &deeplRecorder{})
+
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{[]string{"go", "deepl", "youtube"}, []string{"DE:go", "DE:deepl", "DE:youtube"}},
+		{[]string{}, nil},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := translateTags(tt.tags, "deepl-key", "DE")
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("translateTags(%q) = %q, %v, want %q", tt.tags, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewTranslatorProvider(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     string
+		wantErr  bool
+	}{
+		{"", "*main.DeeplTranslator", false},
+		{providerDeepl, "*main.DeeplTranslator", false},
+		{providerGoogle, "*main.GoogleTranslator", false},
+		{providerAzure, "*main.AzureTranslator", false},
+		{"bing", "", true},
+	}
+	for _, tt := range tests {
+		translator, err := newTranslator(Config{Provider: tt.provider})
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("newTranslator(%q) error = %v, wantErr %v", tt.provider, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		// The backend sits innermost in the chain of wrappers
+		inner := translator.(cachedTranslator).Translator.(chapterTranslator).Translator.(placeholderTranslator).Translator.(chunkedTranslator).Translator.(metricsTranslator).Translator
+		if got := fmt.Sprintf("%T", inner); got != tt.want {
+			t.Errorf("newTranslator(%q) wraps %s, want %s", tt.provider, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateVideoWithFake(t *testing.T) {
+	translator := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		return targetLang + ":" + text, nil
+	}}
+	video := YouTubeVideo{ID: "dQw4w9WgXcQ", Title: "Title", Description: "Description"}
+
+	translated, err := translateVideo(context.Background(), video, translator, []string{"DE", "FR"}, translateVideoOptions{})
+	if err != nil {
+		t.Fatalf("translateVideo() error = %v", err)
+	}
+	want := map[string]VideoTranslation{
+		"DE": {Title: "DE:Title", Description: "DE:Description"},
+		"FR": {Title: "FR:Title", Description: "FR:Description"},
+	}
+	if translated.VideoID != video.ID || translated.Title != video.Title || !reflect.DeepEqual(translated.Translations, want) {
+		t.Errorf("translateVideo() = %+v, want %v", translated, want)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		name    string
+		texts   []string
+		targets []string
+		want    int
+	}{
+		{"one target", []string{"Hello", "World!"}, []string{"DE"}, 11},
+		{"three targets", []string{"Hello", "World!"}, []string{"DE", "FR", "JA"}, 33},
+		{"multibyte text", []string{"Grüße", "日本語", "🎉"}, []string{"DE", "FR"}, 18},
+		{"no targets", []string{"Hello"}, nil, 0},
+	}
+	for _, tt := range tests {
+		if got := estimateCost(tt.texts, tt.targets); got != tt.want {
+			t.Errorf("%s: estimateCost() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateVideosStopsOnQuotaExceeded(t *testing.T) {
+	var calls atomic.Int64
+	translator := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		calls.Add(1)
+		return "", &DeeplError{StatusCode: deeplStatusQuotaExceeded}
+	}}
+	videos := []YouTubeVideo{{ID: "a", Title: "A"}, {ID: "b", Title: "B"}, {ID: "c", Title: "C"}}
+
+	results, err := translateVideos(context.Background(), videos, translator, []string{"DE"}, translateVideoOptions{Concurrency: 1})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("translateVideos() error = %v, want ErrQuotaExceeded", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("translateVideos() went on to %d videos, want to stop after the first", len(results))
+	}
+}
+
+func TestTranslateVideoSkipsEmptyFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		video     YouTubeVideo
+		wantCalls int64
+		wantDE    VideoTranslation
+	}{
+		{"empty description", YouTubeVideo{ID: "a", Title: "Title"}, 1, VideoTranslation{Title: "DE:Title"}},
+		{"blank description", YouTubeVideo{ID: "a", Title: "Title", Description: " \n "}, 1, VideoTranslation{Title: "DE:Title"}},
+		{"both set", YouTubeVideo{ID: "a", Title: "Title", Description: "Text"}, 2, VideoTranslation{Title: "DE:Title", Description: "DE:Text"}},
+	}
+	for _, tt := range tests {
+		var calls atomic.Int64
+		translated, err := translateVideo(context.Background(), tt.video, countingTranslator(&calls), []string{"DE"}, translateVideoOptions{})
+		if err != nil {
+			t.Fatalf("%s: translateVideo() error = %v", tt.name, err)
+		}
+		if calls.Load() != tt.wantCalls {
+			t.Errorf("%s: translator called %d times, want %d", tt.name, calls.Load(), tt.wantCalls)
+		}
+		if got := translated.Translations["DE"]; got != tt.wantDE {
+			t.Errorf("%s: DE = %+v, want %+v", tt.name, got, tt.wantDE)
+		}
+	}
+}
+
+// detectingTranslator reports the language detected holds for each text, or
+// the source language when one is given.
+type detectingTranslator struct {
+	fakeTranslator
+	detected map[string]string
+}
+
+func (d detectingTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	detected := d.detected[text]
+	if sourceLang != "" {
+		detected = sourceLang
+	}
+	return TranslationResult{Text: targetLang + ":" + text, DetectedSourceLanguage: detected}, nil
+}
+
+func TestTranslateVideoWarnsOnLanguageMismatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		detected   map[string]string
+		sourceLang string
+		wantWarn   bool
+	}{
+		{"same language", map[string]string{"Title": "EN", "Description": "EN"}, "", false},
+		{"regional variants", map[string]string{"Title": "EN-GB", "Description": "EN"}, "", false},
+		{"different languages", map[string]string{"Title": "EN", "Description": "ES"}, "", true},
+		{"unknown language", map[string]string{"Title": "EN"}, "", false},
+		{"source language given", map[string]string{"Title": "EN", "Description": "ES"}, "EN", false},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		previous := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+		video := YouTubeVideo{ID: "dQw4w9WgXcQ", Title: "Title", Description: "Description"}
+		_, err := translateVideo(context.Background(), video, detectingTranslator{detected: tt.detected}, []string{"DE", "FR"}, translateVideoOptions{SourceLang: tt.sourceLang})
+		slog.SetDefault(previous)
+
+		if err != nil {
+			t.Fatalf("%s: translateVideo() error = %v, want the mismatch to be advisory", tt.name, err)
+		}
+		warned := strings.Contains(buf.String(), "different languages")
+		if warned != tt.wantWarn {
+			t.Errorf("%s: warned = %v, want %v: %s", tt.name, warned, tt.wantWarn, buf.String())
+		}
+		if tt.wantWarn && strings.Count(buf.String(), "different languages") != 1 {
+			t.Errorf("%s: warned more than once per video: %s", tt.name, buf.String())
+		}
+	}
+}
+
+func TestParseVideoFields(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    videoFields
+		wantErr bool
+	}{
+		{"title,description", allVideoFields, false},
+		{"title", videoFields{Title: true}, false},
+		{" Description ", videoFields{Description: true}, false},
+		{"title,tags", videoFields{}, true},
+		{"", videoFields{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseVideoFields(tt.list)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseVideoFields(%q) = %+v, %v, want %+v", tt.list, got, err, tt.want)
+		}
+	}
+}
+
+func TestTranslateFieldsFlag(t *testing.T) {
+	tests := []struct {
+		fields   string
+		want     VideoTranslation
+		wantSent int
+	}{
+		{"title", VideoTranslation{Title: "DE:Title", Description: "Description"}, 1},
+		{"description", VideoTranslation{Title: "Title", Description: "DE:Description"}, 1},
+		{"title,description", VideoTranslation{Title: "DE:Title", Description: "DE:Description"}, 2},
+	}
+	for _, tt := range tests {
+		recorder := useAPIStub(t)
+		config := testConfig()
+		config.TargetLangs = []string{"DE"}
+		config.OutputPath = filepath.Join(t.TempDir(), "out.json")
+
+		a, _, _ := newTestApp(config)
+		if err := a.runTranslate([]string{"-fields", tt.fields}); err != nil {
+			t.Fatalf("translate -fields %s error = %v", tt.fields, err)
+		}
+		if len(recorder.requests) != tt.wantSent {
+			t.Errorf("-fields %s sent %d translate requests, want %d", tt.fields, len(recorder.requests), tt.wantSent)
+		}
+		data, err := os.ReadFile(config.OutputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result TranslatedVideo
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatal(err)
+		}
+		if got := result.Translations["DE"]; got != tt.want {
+			t.Errorf("-fields %s translated to %+v, want %+v", tt.fields, got, tt.want)
+		}
+	}
+
+	a, _, _ := newTestApp(testConfig())
+	if err := a.runTranslate([]string{"-fields", "title,tags"}); exitCode(err) != exitConfig || !strings.Contains(err.Error(), "tags") {
+		t.Errorf("translate -fields title,tags error = %v, want a config error naming tags", err)
+	}
+}
+
+func TestTranslateVideosBilledCharacters(t *testing.T) {
+	recorder := &deeplRecorder{}
+	useDeeplStub(t, recorder)
+	translator, err := newTranslator(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	videos := []YouTubeVideo{
+		{ID: "a", Title: "Title", Description: "Description"},
+		{ID: "b", Title: "Grüße"},
+	}
+
+	results, err := translateVideos(context.Background(), videos, translator, []string{"DE", "FR"}, translateVideoOptions{})
+	if err != nil {
+		t.Fatalf("translateVideos() error = %v", err)
+	}
+	for i, want := range []int{2 * (5 + 11), 2 * 5} {
+		if results[i].BilledCharacters != want {
+			t.Errorf("video %s billed %d characters, want %d", results[i].VideoID, results[i].BilledCharacters, want)
+		}
+	}
+	if total := billedCharacters(results); total != 42 {
+		t.Errorf("billedCharacters() = %d, want 42", total)
+	}
+}
+
+// ctxTranslator is a fakeTranslator that also takes the context, like the
+// real backends do.
+type ctxTranslator struct {
+	translate func(ctx context.Context, text string, sourceLang string, targetLang string) (string, error)
+	languages []DeeplLanguage
+}
+
+func (f ctxTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	return f.translate(context.Background(), text, sourceLang, targetLang)
+}
+
+func (f ctxTranslator) TranslateDetailedCtx(ctx context.Context, text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	translated, err := f.translate(ctx, text, sourceLang, targetLang)
+	return TranslationResult{Text: translated}, err
+}
+
+func (f ctxTranslator) Languages() ([]DeeplLanguage, error) {
+	return f.languages, nil
+}
+
+// blockingTranslator translates into every language but block, where it
+// waits for the context to end and counts that in cancelled.
+func blockingTranslator(block string, cancelled *atomic.Int64) ctxTranslator {
+	return ctxTranslator{
+		translate: func(ctx context.Context, text string, sourceLang string, targetLang string) (string, error) {
+			if targetLang == block {
+				<-ctx.Done()
+				cancelled.Add(1)
+				return "", ctx.Err()
+			}
+			return targetLang + ":" + text, nil
+		},
+		languages: []DeeplLanguage{{Code: "DE"}, {Code: "FR"}},
+	}
+}
+
+func TestTranslateVideoDeadline(t *testing.T) {
+	var cancelled atomic.Int64
+	slow := blockingTranslator("FR", &cancelled)
+	video := YouTubeVideo{ID: "dQw4w9WgXcQ", Title: "Title", Description: "Description"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	translated, err := translateVideo(ctx, video, slow, []string{"DE", "FR"}, translateVideoOptions{Concurrency: 2})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("translateVideo() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("translateVideo() returned after %v, want it to give up at the deadline", elapsed)
+	}
+	if cancelled.Load() == 0 {
+		t.Error("the translator never saw the deadline")
+	}
+	if got := translated.Translations["DE"]; got != (VideoTranslation{Title: "DE:Title", Description: "DE:Description"}) {
+		t.Errorf("DE = %+v, want the finished translation kept", got)
+	}
+	if got := translated.Translations["FR"]; got.Title != "" || got.Description != "" {
+		t.Errorf("FR = %+v, want nothing from the unfinished language", got)
+	}
+}
+
+func TestTranslateFieldCtxCancelsRequest(t *testing.T) {
+	tests := []struct {
+		provider   string
+		translator func(server *httptest.Server) Translator
+	}{
+		{providerDeepl, func(server *httptest.Server) Translator {
+			return &DeeplTranslator{APIKey: "deepl-key", BaseURL: server.URL, Client: server.Client()}
+		}},
+		{providerGoogle, func(server *httptest.Server) Translator {
+			return &GoogleTranslator{APIKey: "google-key", BaseURL: server.URL, Client: server.Client()}
+		}},
+		{providerAzure, func(server *httptest.Server) Translator {
+			return &AzureTranslator{APIKey: "azure-key", Region: "westeurope", BaseURL: server.URL, Client: server.Client()}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			cancelled := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The server only notices the client leaving once the body is read
+				io.Copy(io.Discard, r.Body)
+				<-r.Context().Done()
+				close(cancelled)
+			}))
+			defer server.Close()
+
+			// The decorators newTranslator adds pass the context on
+			var translator Translator = metricsTranslator{Translator: tt.translator(server), provider: tt.provider, metrics: newMetrics()}
+			translator = chunkedTranslator{Translator: translator, maxChars: defaultMaxChunkChars}
+			translator = placeholderTranslator{Translator: translator}
+			translator = chapterTranslator{Translator: translator}
+			translator = cachedTranslator{Translator: translator, cache: noopCache{}}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if _, err := translateFieldCtx(ctx, translator, "Hello", "EN", "DE"); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("translateFieldCtx() error = %v, want context.DeadlineExceeded", err)
+			}
+			select {
+			case <-cancelled:
+			case <-time.After(time.Second):
+				t.Error("the request was not cancelled at the deadline")
+			}
+		})
+	}
+}
+
+func TestTranslateVideoTruncatesDescription(t *testing.T) {
+	// The translation doubles every word, taking 3000 characters over the limit
+	doubling := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+		return strings.ReplaceAll(text, "word", "wordword"), nil
+	}}
+	video := YouTubeVideo{ID: "dQw4w9WgXcQ", Title: "Title", Description: strings.TrimSpace(strings.Repeat("word ", 1600))}
+
+	tests := []struct {
+		truncate bool
+		wantWarn string
+	}{
+		{false, "use -truncate to shorten it"},
+		{true, "truncated translated description"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		previous := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+		translated, err := translateVideo(context.Background(), video, doubling, []string{"DE"}, translateVideoOptions{Truncate: tt.truncate})
+		slog.SetDefault(previous)
+		if err != nil {
+			t.Fatalf("translateVideo() error = %v", err)
+		}
+
+		description := translated.Translations["DE"].Description
+		if !strings.Contains(buf.String(), tt.wantWarn) {
+			t.Errorf("truncate %v: log = %q, want %q", tt.truncate, buf.String(), tt.wantWarn)
+		}
+		if !tt.truncate {
+			if len(description) <= youtubeMaxDescriptionChars {
+				t.Errorf("description shortened to %d characters without -truncate", len(description))
+			}
+			continue
+		}
+		if n := len([]rune(description)); n > youtubeMaxDescriptionChars {
+			t.Errorf("description is %d characters, want at most %d", n, youtubeMaxDescriptionChars)
+		}
+		if !strings.HasSuffix(description, "wordword…") {
+			t.Errorf("description ends in %q, want a whole word and an ellipsis", description[len(description)-20:])
+		}
+	}
+}
+
+func TestTranslateVideoPartialFailure(t *testing.T) {
+	video := YouTubeVideo{ID: "dQw4w9WgXcQ", Title: "Title", Description: "Description"}
+	failing := func(texts ...string) Translator {
+		return fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+			for _, failed := range texts {
+				if text == failed {
+					return "", errors.New("backend error")
+				}
+			}
+			return targetLang + ":" + text, nil
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		translator  Translator
+		wantErr     bool
+		wantKept    bool
+		want        VideoTranslation
+		wantRecords bool
+	}{
+		{
+			name:        "both fields work",
+			translator:  failing(),
+			wantKept:    true,
+			want:        VideoTranslation{Title: "DE:Title", Description: "DE:Description"},
+			wantRecords: true,
+		},
+		{
+			name:       "description fails",
+			translator: failing("Description"),
+			wantErr:    true,
+			wantKept:   true,
+			want:       VideoTranslation{Title: "DE:Title", DescriptionError: "failed to translate description to DE: backend error"},
+		},
+		{
+			name:       "title fails",
+			translator: failing("Title"),
+			wantErr:    true,
+			wantKept:   true,
+			want:       VideoTranslation{TitleError: "failed to translate title to DE: backend error", Description: "DE:Description"},
+		},
+		{
+			name:       "both fail",
+			translator: failing("Title", "Description"),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		state := newRunState(filepath.Join(t.TempDir(), "state.json"), "")
+		translated, err := translateVideo(context.Background(), video, tt.translator, []string{"DE"}, translateVideoOptions{State: state})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: translateVideo() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		got, kept := translated.Translations["DE"]
+		if kept != tt.wantKept || (kept && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("%s: DE = %+v (kept %v), want %+v (kept %v)", tt.name, got, kept, tt.want, tt.wantKept)
+		}
+		// Only complete languages are done, -resume retries the others
+		if _, done := state.done(video.ID, "DE"); done != tt.wantRecords {
+			t.Errorf("%s: recorded as done = %v, want %v", tt.name, done, tt.wantRecords)
+		}
+	}
+}
+
+func TestTranslateWritesPartialOutput(t *testing.T) {
+	useStubServer(t, youtubeVideoHandler("dQw4w9WgXcQ", "Title"))
+	config := testConfig()
+	config.TargetLangs = []string{"DE"}
+	config.OutputPath = filepath.Join(t.TempDir(), "out.json")
+	a, _, _ := newTestApp(config)
+	a.newTranslator = func(Config) (Translator, error) {
+		return fakeTranslator{
+			translate: func(text string, sourceLang string, targetLang string) (string, error) {
+				if text == "Description" {
+					return "", errors.New("backend error")
+				}
+				return targetLang + ":" + text, nil
+			},
+			languages: []DeeplLanguage{{Code: "DE"}},
+		}, nil
+	}
+
+	err := a.runTranslate(nil)
+	if err == nil || exitCode(err) != exitFailure {
+		t.Fatalf("translate error = %v, want a failure for the description", err)
+	}
+	data, err := os.ReadFile(config.OutputPath)
+	if err != nil {
+		t.Fatalf("no output written: %v", err)
+	}
+	var output TranslatedVideo
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatal(err)
+	}
+	if de := output.Translations["DE"]; de.Title != "DE:Title" || !strings.Contains(de.DescriptionError, "backend error") {
+		t.Errorf("DE = %+v, want the title and the description's error", de)
+	}
+}
+
+func TestFitTitle(t *testing.T) {
+	video := YouTubeVideo{ID: "dQw4w9WgXcQ", Title: "How to cook pasta"}
+	short := func(text string) Translator {
+		return billingTranslator{dictionary: map[string]string{"DE:How to cook pasta": text}}
+	}
+	failing := fakeTranslator{translate: func(string, string, string) (string, error) {
+		return "", errors.New("quota")
+	}}
+
+	tests := []struct {
+		name        string
+		title       string
+		titleMax    int
+		shortTitles Translator
+		want        string
+		wantBilled  int
+		wantWarn    bool
+	}{
+		{"no limit", "Wie man Nudeln richtig kocht", 0, nil, "Wie man Nudeln richtig kocht", 0, false},
+		{"within the limit", "Nudeln kochen", 20, nil, "Nudeln kochen", 0, false},
+		{"truncated at a word", "Wie man Nudeln richtig kocht", 20, nil, "Wie man Nudeln…", 0, true},
+		{"shorter translation", "Wie man Nudeln richtig kocht", 20, short("Nudeln kochen"), "Nudeln kochen", 17, true},
+		{"shorter still too long", "Wie man Nudeln richtig kocht", 20, short("So kocht man Nudeln richtig"), "So kocht man Nudeln…", 17, true},
+		{"shorter fails", "Wie man Nudeln richtig kocht", 20, failing, "Wie man Nudeln…", 0, true},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		previous := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+		got := fitTitle(context.Background(), video, TranslationResult{Text: tt.title}, "EN", "DE", translateVideoOptions{TitleMax: tt.titleMax, ShortTitles: tt.shortTitles})
+		slog.SetDefault(previous)
+
+		if got.Text != tt.want || got.BilledCharacters != tt.wantBilled {
+			t.Errorf("%s: fitTitle() = %q billed %d, want %q billed %d", tt.name, got.Text, got.BilledCharacters, tt.want, tt.wantBilled)
+		}
+		if tt.titleMax > 0 && len([]rune(got.Text)) > tt.titleMax {
+			t.Errorf("%s: fitTitle() = %q is longer than %d", tt.name, got.Text, tt.titleMax)
+		}
+		if warned := strings.Contains(buf.String(), "longer than -title-max"); warned != tt.wantWarn {
+			t.Errorf("%s: reported = %v, want %v: %s", tt.name, warned, tt.wantWarn, buf.String())
+		}
+	}
+}
+
+func TestTranslateTitleMax(t *testing.T) {
+	recorder := &deeplRecorder{}
+	mux := http.NewServeMux()
+	mux.Handle("/youtube/v3/videos", youtubeVideoHandler("dQw4w9WgXcQ", "How to cook pasta the right way"))
+	mux.Handle("/v2/translate", recorder)
+	mux.HandleFunc("/v2/languages", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"language":"DE","name":"German"}]`))
+	})
+	mux.HandleFunc("/v2/usage", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"character_count":0,"character_limit":500000}`))
+	})
+	useStubServer(t, mux)
+
+	config := testConfig()
+	config.TargetLangs = []string{"DE"}
+	a, stdout, _ := newTestApp(config)
+	if err := a.runTranslate([]string{"-title-max", "20"}); err != nil {
+		t.Fatalf("translate -title-max error = %v", err)
+	}
+
+	var hinted int
+	for _, request := range recorder.requests {
+		if hint, _ := request["context"].(string); strings.Contains(hint, "shorter than 20 characters") {
+			hinted++
+		}
+	}
+	if hinted != 1 {
+		t.Errorf("asked for a shorter title %d times, want once", hinted)
+	}
+	var output TranslatedVideo
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		t.Fatal(err)
+	}
+	if got := output.Translations["DE"].Title; got != "DE:How to cook…" {
+		t.Errorf("title = %q, want it truncated at a word", got)
+	}
+}
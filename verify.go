@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// errUnknownSourceLang is returned by roundTrip when neither source_lang nor
+// the provider says what to translate back into.
+var errUnknownSourceLang = errors.New("source language unknown, set source_lang")
+
+// backTranslate translates text into viaLang and back, and returns the round
+// tripped text with its similarity to text. A low similarity hints at a bad
+// translation.
+func backTranslate(text string, viaLang string, t Translator) (string, float64, error) {
+	translated, err := translateWithDetection(t, text, "", viaLang)
+	if err != nil {
+		return "", 0, err
+	}
+	back, score, err := roundTrip(text, translated.Text, translated.DetectedSourceLanguage, viaLang, t)
+	return back.Text, score, err
+}
+
+// roundTrip translates translated, a translation of original from
+// sourceLang into viaLang, back into sourceLang and scores it against
+// original. The result is the back translation with what it was billed.
+// DeepL rejects regional variants such as EN-GB as source_lang, so viaLang
+// is sent as its base language.
+func roundTrip(original string, translated string, sourceLang string, viaLang string, t Translator) (TranslationResult, float64, error) {
+	if sourceLang == "" {
+		return TranslationResult{}, 0, errUnknownSourceLang
+	}
+	back, err := translateWithDetection(t, translated, baseLanguage(viaLang), sourceLang)
+	if err != nil {
+		return TranslationResult{}, 0, err
+	}
+	return back, similarity(original, back.Text), nil
+}
+
+// similarity is the Levenshtein ratio of a and b ignoring case, 1 for equal
+// texts and 0 for texts without anything in common. It is rounded to three
+// decimals to keep the output readable.
+func similarity(a string, b string) float64 {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	longest := utf8.RuneCountInString(a)
+	if n := utf8.RuneCountInString(b); n > longest {
+		longest = n
+	}
+	if longest == 0 {
+		return 1
+	}
+	ratio := 1 - float64(levenshtein([]rune(a), []rune(b)))/float64(longest)
+	return math.Round(ratio*1000) / 1000
+}
+
+// levenshtein counts the single rune insertions, deletions and substitutions
+// turning a into b, keeping only two rows of the distance table.
+func levenshtein(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
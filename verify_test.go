@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"Hello", "hello", 1},
+		{"kitten", "sitting", 0.571},
+		{"abc", "", 0},
+		{"Grüße", "Grüsse", 0.667},
+	}
+	for _, tt := range tests {
+		if got := similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "abc", 3},
+		{"flaw", "lawn", 2},
+		{"same", "same", 0},
+		{"ça", "ca", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// billingTranslator looks translations up in a dictionary, detects English
+// and bills one character per rune.
+type billingTranslator struct {
+	dictionary map[string]string
+}
+
+func (b billingTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	result, err := b.TranslateDetailed(text, sourceLang, targetLang)
+	return result.Text, err
+}
+
+func (b billingTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	translated, ok := b.dictionary[targetLang+":"+text]
+	if !ok {
+		translated = text
+	}
+	return TranslationResult{Text: translated, DetectedSourceLanguage: "EN", BilledCharacters: len([]rune(text))}, nil
+}
+
+func (billingTranslator) Languages() ([]DeeplLanguage, error) { return nil, nil }
+
+func TestBackTranslate(t *testing.T) {
+	translator := billingTranslator{dictionary: map[string]string{
+		"DE:Good morning": "Guten Morgen",
+		"EN:Guten Morgen": "Good morning!",
+	}}
+
+	back, score, err := backTranslate("Good morning", "DE", translator)
+	if err != nil {
+		t.Fatalf("backTranslate() error = %v", err)
+	}
+	if back != "Good morning!" || score != 0.923 {
+		t.Errorf("backTranslate() = %q, %v, want %q, 0.923", back, score, "Good morning!")
+	}
+}
+
+func TestVerifyIsBilled(t *testing.T) {
+	translator := billingTranslator{dictionary: map[string]string{
+		"DE:Title": "Titel",
+		"EN:Titel": "Title",
+		"DE:Text":  "Texte",
+		"EN:Texte": "Texts",
+	}}
+	video := YouTubeVideo{ID: "v", Title: "Title", Description: "Text"}
+
+	tests := []struct {
+		name   string
+		verify bool
+		want   int
+	}{
+		{"without verify", false, len("Title") + len("Text")},
+		{"with verify", true, len("Title") + len("Text") + len("Titel") + len("Texte")},
+	}
+	for _, tt := range tests {
+		translated, err := translateVideo(context.Background(), video, translator, []string{"DE"}, translateVideoOptions{Verify: tt.verify})
+		if err != nil {
+			t.Fatalf("%s: translateVideo() error = %v", tt.name, err)
+		}
+		if translated.BilledCharacters != tt.want {
+			t.Errorf("%s: billed %d characters, want %d", tt.name, translated.BilledCharacters, tt.want)
+		}
+		translation := translated.Translations["DE"]
+		if tt.verify && (translation.TitleSimilarity == nil || *translation.TitleSimilarity != 1 || translation.DescriptionSimilarity == nil) {
+			t.Errorf("%s: similarities = %v, %v", tt.name, translation.TitleSimilarity, translation.DescriptionSimilarity)
+		}
+	}
+
+	if score, billed := verifyField(translator, "  ", "", "EN", "DE"); score != nil || billed != 0 {
+		t.Errorf("verifyField(empty) = %v, %d, want nothing", score, billed)
+	}
+}
+
+func TestRoundTripLanguages(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourceLang string
+		viaLang    string
+		wantSent   string
+		wantErr    error
+	}{
+		{"base language", "EN", "DE", "DE->EN", nil},
+		{"regional variant", "EN", "PT-BR", "PT->EN", nil},
+		{"unknown source", "", "EN-GB", "", errUnknownSourceLang},
+	}
+	for _, tt := range tests {
+		var sent string
+		translator := fakeTranslator{translate: func(text string, sourceLang string, targetLang string) (string, error) {
+			sent = sourceLang + "->" + targetLang
+			return "Hello", nil
+		}}
+		_, score, err := roundTrip("Hello", "Olá", tt.sourceLang, tt.viaLang, translator)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: roundTrip() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if sent != tt.wantSent {
+			t.Errorf("%s: translated back %q, want %q", tt.name, sent, tt.wantSent)
+		}
+		if tt.wantErr == nil && score != 1 {
+			t.Errorf("%s: score = %v, want 1", tt.name, score)
+		}
+	}
+}
+
+// frenchTranslator detects French and records the languages it was asked
+// to translate back into.
+type frenchTranslator struct {
+	backTo *[]string
+}
+
+func (f frenchTranslator) Translate(text string, sourceLang string, targetLang string) (string, error) {
+	result, err := f.TranslateDetailed(text, sourceLang, targetLang)
+	return result.Text, err
+}
+
+func (f frenchTranslator) TranslateDetailed(text string, sourceLang string, targetLang string) (TranslationResult, error) {
+	if sourceLang != "" {
+		*f.backTo = append(*f.backTo, targetLang)
+		return TranslationResult{Text: "Bonjour"}, nil
+	}
+	return TranslationResult{Text: "Hallo", DetectedSourceLanguage: "FR"}, nil
+}
+
+func (frenchTranslator) Languages() ([]DeeplLanguage, error) { return nil, nil }
+
+func TestVerifyCachedTranslation(t *testing.T) {
+	// The second run translates from cache, which still knows the title is
+	// French
+	var backTo []string
+	translator := cachedTranslator{Translator: frenchTranslator{backTo: &backTo}, cache: NewMemoryCache(10)}
+	video := YouTubeVideo{ID: "v", Title: "Bonjour"}
+
+	for run := 0; run < 2; run++ {
+		translated, err := translateVideo(context.Background(), video, translator, []string{"DE"}, translateVideoOptions{Verify: true})
+		if err != nil {
+			t.Fatalf("run %d: translateVideo() error = %v", run, err)
+		}
+		if score := translated.Translations["DE"].TitleSimilarity; score == nil || *score != 1 {
+			t.Errorf("run %d: title similarity = %v, want 1", run, score)
+		}
+	}
+	if !reflect.DeepEqual(backTo, []string{"FR"}) {
+		t.Errorf("translated back into %v, want FR once and then from cache", backTo)
+	}
+}
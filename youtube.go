@@ -0,0 +1,930 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	youtubeAPIBaseURL = "https://www.googleapis.com/youtube/v3"
+
+	// youtubeMaxIDsPerRequest is the most IDs videos.list accepts at once.
+	youtubeMaxIDsPerRequest = 50
+)
+
+var (
+	// ErrYouTubeQuotaExceeded matches, using errors.Is, errors for requests
+	// YouTube rejected because the daily API quota is used up.
+	ErrYouTubeQuotaExceeded = errors.New("YouTube Data API quota exceeded, retry after the daily quota resets")
+	// ErrYouTubeNotFound matches errors for videos, playlists or channels
+	// YouTube doesn't know.
+	ErrYouTubeNotFound = errors.New("not found on YouTube")
+)
+
+// YouTubeError is returned when the YouTube Data API answers with a non-200
+// status. Reason is the first of the "reason" fields in the error body.
+type YouTubeError struct {
+	StatusCode int
+	Reason     string
+	Message    string
+}
+
+func (e *YouTubeError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("YouTube request failed with status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("YouTube request failed with status code: %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *YouTubeError) Is(target error) bool {
+	switch target {
+	case ErrYouTubeQuotaExceeded:
+		return e.StatusCode == http.StatusForbidden && (e.Reason == "quotaExceeded" || e.Reason == "dailyLimitExceeded")
+	case ErrYouTubeNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+func newYouTubeError(resp *http.Response) *YouTubeError {
+	youtubeErr := &YouTubeError{StatusCode: resp.StatusCode}
+
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+			Errors  []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
+		youtubeErr.Message = body.Error.Message
+		if len(body.Error.Errors) > 0 {
+			youtubeErr.Reason = body.Error.Errors[0].Reason
+		}
+	}
+
+	return youtubeErr
+}
+
+type YouTubeVideo struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+}
+
+type youtubeVideoListResponse struct {
+	// ETag is the ETag header of the response, for If-None-Match
+	ETag          string `json:"-"`
+	NextPageToken string `json:"nextPageToken"`
+	Items         []struct {
+		ID      string `json:"id"`
+		Snippet struct {
+			Title       string   `json:"title"`
+			Description string   `json:"description"`
+			Tags        []string `json:"tags"`
+			// Localized is the snippet in the language asked for with hl
+			Localized struct {
+				Title       string `json:"title"`
+				Description string `json:"description"`
+			} `json:"localized"`
+		} `json:"snippet"`
+	} `json:"items"`
+}
+
+func (r youtubeVideoListResponse) videos() []YouTubeVideo {
+	videos := make([]YouTubeVideo, 0, len(r.Items))
+	for _, item := range r.Items {
+		videos = append(videos, YouTubeVideo{
+			ID:          item.ID,
+			Title:       item.Snippet.Title,
+			Description: item.Snippet.Description,
+			Tags:        item.Snippet.Tags,
+		})
+	}
+	return videos
+}
+
+func listYouTubeVideos(ctx context.Context, query url.Values) (youtubeVideoListResponse, error) {
+	response, _, err := listYouTubeVideosIfNoneMatch(ctx, httpClient, query, "")
+	return response, err
+}
+
+// listYouTubeVideosIfNoneMatch is listYouTubeVideos sending etag as
+// If-None-Match. It reports whether YouTube answered 304 Not Modified, the
+// response is empty then and the copy behind etag is still current.
+func listYouTubeVideosIfNoneMatch(ctx context.Context, client HTTPDoer, query url.Values, etag string) (youtubeVideoListResponse, bool, error) {
+	var response youtubeVideoListResponse
+
+	req, err := http.NewRequestWithContext(ctx, "GET", youtubeAPIBaseURL+"/videos?"+query.Encode(), nil)
+	if err != nil {
+		return response, false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return response, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		return response, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return response, false, fmt.Errorf("failed to fetch video information: %w", newYouTubeError(resp))
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return response, false, err
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return response, false, err
+	}
+	response.ETag = resp.Header.Get("ETag")
+
+	return response, false, nil
+}
+
+// youtubeCache keeps the videos fetchYouTubeVideoInfo fetched along with
+// their ETag, so a video that didn't change is answered with 304 Not Modified
+// instead of its metadata. run keeps it under cache_dir.
+var youtubeCache TranslationCache = noopCache{}
+
+// cachedVideo is a video as youtubeCache stores it.
+type cachedVideo struct {
+	ETag  string       `json:"etag"`
+	Video YouTubeVideo `json:"video"`
+}
+
+func youtubeCacheKey(videoID string, hl string) string {
+	hash := sha256.New()
+	for _, part := range []string{"videos", videoID, hl} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// cachedVideoFor returns what cache holds under key. An unreadable
+// entry counts as missing, the video is fetched again then.
+func cachedVideoFor(cache TranslationCache, key string) cachedVideo {
+	var cached cachedVideo
+	if data, ok := cache.Get(key); ok {
+		if err := json.Unmarshal([]byte(data), &cached); err != nil {
+			return cachedVideo{}
+		}
+	}
+	return cached
+}
+
+func fetchYouTubeVideoInfo(videoID string, apiKey string) (YouTubeVideo, error) {
+	return fetchYouTubeVideoInfoCtx(context.Background(), videoID, apiKey)
+}
+
+func fetchYouTubeVideoInfoCtx(ctx context.Context, videoID string, apiKey string) (YouTubeVideo, error) {
+	return fetchYouTubeVideoInfoHL(ctx, videoID, apiKey, "")
+}
+
+// fetchYouTubeVideoInfoHL fetches the title and description as YouTube shows
+// them to viewers of language hl, e.g. "de". Without a localization in that
+// language YouTube returns the default metadata. An empty hl fetches the
+// default metadata.
+func fetchYouTubeVideoInfoHL(ctx context.Context, videoID string, apiKey string, hl string) (YouTubeVideo, error) {
+	return fetchYouTubeVideoInfoWith(ctx, globalAPISettings(), videoID, apiKey, hl)
+}
+
+// fetchYouTubeVideoInfoWith is fetchYouTubeVideoInfoHL with the client and
+// the cache of settings.
+func fetchYouTubeVideoInfoWith(ctx context.Context, settings apiSettings, videoID string, apiKey string, hl string) (YouTubeVideo, error) {
+	query := url.Values{}
+	query.Set("id", videoID)
+	query.Set("key", apiKey)
+	query.Set("part", "snippet")
+	if hl != "" {
+		query.Set("hl", hl)
+	}
+
+	key := youtubeCacheKey(videoID, hl)
+	cached := cachedVideoFor(settings.YouTubeCache, key)
+	response, notModified, err := listYouTubeVideosIfNoneMatch(ctx, settings.Client, query, cached.ETag)
+	if err != nil {
+		return YouTubeVideo{}, err
+	}
+	if notModified {
+		slog.Debug("video is unchanged, using the cached metadata", "video", videoID)
+		return cached.Video, nil
+	}
+
+	if len(response.Items) == 0 {
+		return YouTubeVideo{}, fmt.Errorf("video with ID %s: %w", videoID, ErrYouTubeNotFound)
+	}
+
+	video := response.videos()[0]
+	if hl != "" {
+		localized := response.Items[0].Snippet.Localized
+		if localized.Title == video.Title && localized.Description == video.Description {
+			slog.Debug("video has no localization for hl, using the default metadata", "video", videoID, "hl", hl)
+		}
+		if localized.Title != "" {
+			video.Title = localized.Title
+			video.Description = localized.Description
+		}
+	}
+	video.ID = videoID
+
+	// Caching is best effort, without it the next run fetches the video again
+	if response.ETag != "" {
+		if data, err := json.Marshal(cachedVideo{ETag: response.ETag, Video: video}); err == nil {
+			settings.YouTubeCache.Set(key, string(data))
+		}
+	}
+	return video, nil
+}
+
+// fetchYouTubeVideos fetches videos in batches of up to 50 IDs. IDs that
+// YouTube doesn't know are missing from the result instead of failing it.
+func fetchYouTubeVideos(videoIDs []string, apiKey string) ([]YouTubeVideo, error) {
+	return fetchYouTubeVideosCtx(context.Background(), videoIDs, apiKey)
+}
+
+func fetchYouTubeVideosCtx(ctx context.Context, videoIDs []string, apiKey string) ([]YouTubeVideo, error) {
+	var videos []YouTubeVideo
+
+	for start := 0; start < len(videoIDs); start += youtubeMaxIDsPerRequest {
+		end := start + youtubeMaxIDsPerRequest
+		if end > len(videoIDs) {
+			end = len(videoIDs)
+		}
+
+		query := url.Values{}
+		query.Set("id", strings.Join(videoIDs[start:end], ","))
+		query.Set("key", apiKey)
+		query.Set("part", "snippet")
+		query.Set("maxResults", fmt.Sprint(youtubeMaxIDsPerRequest))
+
+		for {
+			response, err := listYouTubeVideos(ctx, query)
+			if err != nil {
+				return videos, err
+			}
+			videos = append(videos, response.videos()...)
+
+			if response.NextPageToken == "" {
+				break
+			}
+			query.Set("pageToken", response.NextPageToken)
+		}
+	}
+
+	return videos, nil
+}
+
+type YouTubeLocalization struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// YouTube localizations are keyed by BCP-47 codes, which differ from DeepL's
+// codes in case and for the regional and script variants.
+var deeplToYouTubeLangs = map[string]string{
+	"AR":      "ar",
+	"BG":      "bg",
+	"CS":      "cs",
+	"DA":      "da",
+	"DE":      "de",
+	"EL":      "el",
+	"EN":      "en",
+	"EN-GB":   "en-GB",
+	"EN-US":   "en",
+	"ES":      "es",
+	"ES-419":  "es-419",
+	"ET":      "et",
+	"FI":      "fi",
+	"FR":      "fr",
+	"HE":      "iw",
+	"HU":      "hu",
+	"ID":      "id",
+	"IT":      "it",
+	"JA":      "ja",
+	"KO":      "ko",
+	"LT":      "lt",
+	"LV":      "lv",
+	"NB":      "no",
+	"NL":      "nl",
+	"PL":      "pl",
+	"PT":      "pt",
+	"PT-BR":   "pt-BR",
+	"PT-PT":   "pt-PT",
+	"RO":      "ro",
+	"RU":      "ru",
+	"SK":      "sk",
+	"SL":      "sl",
+	"SV":      "sv",
+	"TH":      "th",
+	"TR":      "tr",
+	"UK":      "uk",
+	"VI":      "vi",
+	"ZH":      "zh-Hans",
+	"ZH-HANS": "zh-Hans",
+	"ZH-HANT": "zh-Hant",
+}
+
+// youtubeToDeeplLangs is the inverse of deeplToYouTubeLangs. Where several
+// DeepL codes share a YouTube code the generic one wins.
+var youtubeToDeeplLangs = func() map[string]string {
+	langs := make(map[string]string, len(deeplToYouTubeLangs))
+	for deepl, youtube := range deeplToYouTubeLangs {
+		if current, ok := langs[youtube]; !ok || len(deepl) < len(current) {
+			langs[youtube] = deepl
+		}
+	}
+	return langs
+}()
+
+// deeplToYouTubeLang maps a DeepL language code to the code YouTube uses for
+// localizations. Unknown codes are lowercased.
+func deeplToYouTubeLang(code string) string {
+	if youtube, ok := deeplToYouTubeLangs[strings.ToUpper(code)]; ok {
+		return youtube
+	}
+	slog.Warn("no YouTube language code known for DeepL language, using it lowercased", "code", code)
+	return strings.ToLower(code)
+}
+
+// youtubeToDeeplLang maps a YouTube localization code to DeepL's code.
+// Unknown codes are uppercased.
+func youtubeToDeeplLang(code string) string {
+	if deepl, ok := youtubeToDeeplLangs[code]; ok {
+		return deepl
+	}
+	for youtube, deepl := range youtubeToDeeplLangs {
+		if strings.EqualFold(youtube, code) {
+			return deepl
+		}
+	}
+	slog.Warn("no DeepL language code known for YouTube language, using it uppercased", "code", code)
+	return strings.ToUpper(code)
+}
+
+// Google API keys always start with this prefix, OAuth access tokens never do.
+const googleAPIKeyPrefix = "AIza"
+
+// updateVideoLocalizations replaces the localizations of a video. The map is
+// keyed by BCP-47 language code and token must be an OAuth access token.
+func updateVideoLocalizations(videoID string, token string, locs map[string]YouTubeLocalization) error {
+	return updateVideoLocalizationsCtx(context.Background(), videoID, token, locs)
+}
+
+func updateVideoLocalizationsCtx(ctx context.Context, videoID string, token string, locs map[string]YouTubeLocalization) error {
+	if err := checkOAuthToken(token); err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"id":            videoID,
+		"localizations": locs,
+	}
+	requestData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request data: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", youtubeAPIBaseURL+"/videos?part=localizations", bytes.NewBuffer(requestData))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update video localizations: %w", newYouTubeError(resp))
+	}
+
+	return nil
+}
+
+// fetchVideoLocalizations returns the localizations a video already has,
+// keyed by language code. token must be an OAuth access token.
+func fetchVideoLocalizations(videoID string, token string) (map[string]YouTubeLocalization, error) {
+	return fetchVideoLocalizationsCtx(context.Background(), videoID, token)
+}
+
+func fetchVideoLocalizationsCtx(ctx context.Context, videoID string, token string) (map[string]YouTubeLocalization, error) {
+	if err := checkOAuthToken(token); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("id", videoID)
+	query.Set("part", "localizations")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", youtubeAPIBaseURL+"/videos?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch video localizations: %w", newYouTubeError(resp))
+	}
+
+	var response struct {
+		Items []struct {
+			Localizations map[string]YouTubeLocalization `json:"localizations"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Items) == 0 {
+		return nil, fmt.Errorf("video with ID %s: %w", videoID, ErrYouTubeNotFound)
+	}
+
+	locs := response.Items[0].Localizations
+	if locs == nil {
+		locs = map[string]YouTubeLocalization{}
+	}
+	return locs, nil
+}
+
+// mergeLocalizations adds translated to existing and returns the merged map
+// with the sorted languages it added. Languages that already exist are kept
+// as they are, they may have been fixed by hand, unless force is set.
+func mergeLocalizations(existing map[string]YouTubeLocalization, translated map[string]YouTubeLocalization, force bool) (map[string]YouTubeLocalization, []string) {
+	merged := make(map[string]YouTubeLocalization, len(existing)+len(translated))
+	for lang, loc := range existing {
+		merged[lang] = loc
+	}
+
+	var added []string
+	for lang, loc := range translated {
+		if current, ok := findLocalization(merged, lang); ok && !force {
+			continue
+		} else if ok {
+			delete(merged, current)
+		}
+		merged[lang] = loc
+		added = append(added, lang)
+	}
+	sort.Strings(added)
+
+	return merged, added
+}
+
+// keepUntranslatedFields replaces the fields of translated that weren't
+// translated with what existing already holds for the language, so that
+// uploading only the titles doesn't overwrite a localized description with
+// the original one. A language without a localization gets them empty.
+func keepUntranslatedFields(existing map[string]YouTubeLocalization, translated map[string]YouTubeLocalization, fields videoFields) map[string]YouTubeLocalization {
+	fields = fields.orAll()
+	if fields == allVideoFields {
+		return translated
+	}
+
+	kept := make(map[string]YouTubeLocalization, len(translated))
+	for lang, loc := range translated {
+		var current YouTubeLocalization
+		if key, ok := findLocalization(existing, lang); ok {
+			current = existing[key]
+		}
+		if !fields.Title {
+			loc.Title = current.Title
+		}
+		if !fields.Description {
+			loc.Description = current.Description
+		}
+		kept[lang] = loc
+	}
+	return kept
+}
+
+// findLocalization looks lang up ignoring case and returns the key it is
+// stored under.
+func findLocalization(locs map[string]YouTubeLocalization, lang string) (string, bool) {
+	for key := range locs {
+		if strings.EqualFold(key, lang) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// uploadLocalizations writes translated to the video on top of its existing
+// localizations, see mergeLocalizations, and returns the languages it wrote.
+// Nothing is sent when no language is new.
+func uploadLocalizations(ctx context.Context, videoID string, token string, translated map[string]YouTubeLocalization, fields videoFields, force bool) ([]string, error) {
+	existing, err := fetchVideoLocalizationsCtx(ctx, videoID, token)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, added := mergeLocalizations(existing, keepUntranslatedFields(existing, translated, fields), force)
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	if err := updateVideoLocalizationsCtx(ctx, videoID, token, merged); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// extractVideoID accepts a bare video ID or a watch, youtu.be, embed, shorts
+// or live URL and returns the video ID.
+func extractVideoID(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if youtubeVideoIDPattern.MatchString(input) {
+		return input, nil
+	}
+
+	raw := input
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("no YouTube video ID found in %q", input)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	var id string
+	switch host {
+	case "youtu.be":
+		id = segments[0]
+	case "youtube.com", "music.youtube.com", "youtube-nocookie.com":
+		if segments[0] == "watch" {
+			id = u.Query().Get("v")
+		} else if len(segments) >= 2 {
+			switch segments[0] {
+			case "embed", "shorts", "live", "v":
+				id = segments[1]
+			}
+		}
+	}
+
+	if !youtubeVideoIDPattern.MatchString(id) {
+		return "", fmt.Errorf("no YouTube video ID found in %q", input)
+	}
+	return id, nil
+}
+
+// fetchPlaylistVideoIDs returns the IDs of every video in a playlist in
+// playlist order, following pagination. An empty playlist yields no IDs.
+func fetchPlaylistVideoIDs(playlistID string, apiKey string) ([]string, error) {
+	return fetchPlaylistVideoIDsCtx(context.Background(), playlistID, apiKey)
+}
+
+func fetchPlaylistVideoIDsCtx(ctx context.Context, playlistID string, apiKey string) ([]string, error) {
+	return fetchPlaylistVideoIDsMax(ctx, playlistID, apiKey, 0)
+}
+
+// fetchPlaylistVideoIDsMax stops after max IDs, zero or less fetches all.
+func fetchPlaylistVideoIDsMax(ctx context.Context, playlistID string, apiKey string, max int) ([]string, error) {
+	return fetchPlaylistVideoIDsSince(ctx, playlistID, apiKey, max, time.Time{})
+}
+
+// fetchPlaylistVideoIDsSince is fetchPlaylistVideoIDsMax that stops at the
+// first item published before since, unless since is zero. That only makes
+// sense for playlists sorted newest first, like a channel's uploads.
+func fetchPlaylistVideoIDsSince(ctx context.Context, playlistID string, apiKey string, max int, since time.Time) ([]string, error) {
+	query := url.Values{}
+	query.Set("playlistId", playlistID)
+	query.Set("key", apiKey)
+	query.Set("part", "contentDetails")
+	if !since.IsZero() {
+		query.Set("part", "snippet,contentDetails")
+	}
+
+	var videoIDs []string
+	for {
+		pageSize := youtubeMaxIDsPerRequest
+		if max > 0 && max-len(videoIDs) < pageSize {
+			pageSize = max - len(videoIDs)
+		}
+		query.Set("maxResults", fmt.Sprint(pageSize))
+
+		req, err := http.NewRequestWithContext(ctx, "GET", youtubeAPIBaseURL+"/playlistItems?"+query.Encode(), nil)
+		if err != nil {
+			return videoIDs, err
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return videoIDs, err
+		}
+
+		var response struct {
+			NextPageToken string `json:"nextPageToken"`
+			Items         []struct {
+				Snippet struct {
+					PublishedAt time.Time `json:"publishedAt"`
+				} `json:"snippet"`
+				ContentDetails struct {
+					VideoID string `json:"videoId"`
+				} `json:"contentDetails"`
+			} `json:"items"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			youtubeErr := newYouTubeError(resp)
+			resp.Body.Close()
+			return videoIDs, fmt.Errorf("failed to fetch playlist items: %w", youtubeErr)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+		if err != nil {
+			return videoIDs, err
+		}
+
+		for _, item := range response.Items {
+			if !since.IsZero() && item.Snippet.PublishedAt.Before(since) {
+				// Everything after this item is older still
+				return videoIDs, nil
+			}
+			videoIDs = append(videoIDs, item.ContentDetails.VideoID)
+		}
+
+		if response.NextPageToken == "" || (max > 0 && len(videoIDs) >= max) {
+			if max > 0 && len(videoIDs) > max {
+				videoIDs = videoIDs[:max]
+			}
+			return videoIDs, nil
+		}
+		query.Set("pageToken", response.NextPageToken)
+	}
+}
+
+// fetchChannelUploads returns the IDs of up to max of the channel's most
+// recent uploads, zero or less returns all of them. channelID must be a
+// channel ID ("UC..."), handles like "@name" aren't resolved.
+func fetchChannelUploads(channelID string, apiKey string, max int) ([]string, error) {
+	return fetchChannelUploadsCtx(context.Background(), channelID, apiKey, max)
+}
+
+func fetchChannelUploadsCtx(ctx context.Context, channelID string, apiKey string, max int) ([]string, error) {
+	return fetchChannelUploadsSince(ctx, channelID, apiKey, max, time.Time{})
+}
+
+// fetchChannelUploadsSince is fetchChannelUploadsCtx for the videos published
+// at or after since. Uploads are listed newest first, so paging stops at the
+// first older video.
+func fetchChannelUploadsSince(ctx context.Context, channelID string, apiKey string, max int, since time.Time) ([]string, error) {
+	if strings.HasPrefix(channelID, "@") {
+		return nil, fmt.Errorf("%q is a channel handle, use the channel ID starting with \"UC\" instead", channelID)
+	}
+
+	playlistID, err := fetchUploadsPlaylistID(ctx, channelID, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return fetchPlaylistVideoIDsSince(ctx, playlistID, apiKey, max, since)
+}
+
+// fetchUploadsPlaylistID looks up the playlist that holds every upload of
+// the channel.
+func fetchUploadsPlaylistID(ctx context.Context, channelID string, apiKey string) (string, error) {
+	query := url.Values{}
+	query.Set("id", channelID)
+	query.Set("key", apiKey)
+	query.Set("part", "contentDetails")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", youtubeAPIBaseURL+"/channels?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch channel: %w", newYouTubeError(resp))
+	}
+
+	var response struct {
+		Items []struct {
+			ContentDetails struct {
+				RelatedPlaylists struct {
+					Uploads string `json:"uploads"`
+				} `json:"relatedPlaylists"`
+			} `json:"contentDetails"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	if len(response.Items) == 0 {
+		return "", fmt.Errorf("channel %s: %w", channelID, ErrYouTubeNotFound)
+	}
+
+	return response.Items[0].ContentDetails.RelatedPlaylists.Uploads, nil
+}
+
+// YouTubePlaylist is the metadata of a playlist itself, not of its videos.
+type YouTubePlaylist struct {
+	ID              string
+	Title           string
+	Description     string
+	DefaultLanguage string
+	Localizations   map[string]YouTubeLocalization
+}
+
+func fetchPlaylistInfo(playlistID string, apiKey string) (YouTubePlaylist, error) {
+	return fetchPlaylistInfoCtx(context.Background(), playlistID, apiKey)
+}
+
+func fetchPlaylistInfoCtx(ctx context.Context, playlistID string, apiKey string) (YouTubePlaylist, error) {
+	query := url.Values{}
+	query.Set("id", playlistID)
+	query.Set("key", apiKey)
+	query.Set("part", "snippet,localizations")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", youtubeAPIBaseURL+"/playlists?"+query.Encode(), nil)
+	if err != nil {
+		return YouTubePlaylist{}, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return YouTubePlaylist{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return YouTubePlaylist{}, fmt.Errorf("failed to fetch playlist information: %w", newYouTubeError(resp))
+	}
+
+	var response struct {
+		Items []struct {
+			ID      string `json:"id"`
+			Snippet struct {
+				Title           string `json:"title"`
+				Description     string `json:"description"`
+				DefaultLanguage string `json:"defaultLanguage"`
+			} `json:"snippet"`
+			Localizations map[string]YouTubeLocalization `json:"localizations"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return YouTubePlaylist{}, err
+	}
+
+	if len(response.Items) == 0 {
+		return YouTubePlaylist{}, fmt.Errorf("playlist with ID %s: %w", playlistID, ErrYouTubeNotFound)
+	}
+
+	item := response.Items[0]
+	playlist := YouTubePlaylist{
+		ID:              item.ID,
+		Title:           item.Snippet.Title,
+		Description:     item.Snippet.Description,
+		DefaultLanguage: item.Snippet.DefaultLanguage,
+		Localizations:   item.Localizations,
+	}
+	if playlist.Localizations == nil {
+		playlist.Localizations = map[string]YouTubeLocalization{}
+	}
+	return playlist, nil
+}
+
+// playlistUpdate is the body of a playlists.update request. YouTube replaces
+// the whole snippet, so the title and description are sent unchanged.
+type playlistUpdate struct {
+	ID      string `json:"id"`
+	Snippet struct {
+		Title           string `json:"title"`
+		Description     string `json:"description"`
+		DefaultLanguage string `json:"defaultLanguage,omitempty"`
+	} `json:"snippet"`
+	Localizations map[string]YouTubeLocalization `json:"localizations"`
+}
+
+func newPlaylistUpdate(playlist YouTubePlaylist, locs map[string]YouTubeLocalization) playlistUpdate {
+	var update playlistUpdate
+	update.ID = playlist.ID
+	update.Snippet.Title = playlist.Title
+	update.Snippet.Description = playlist.Description
+	update.Snippet.DefaultLanguage = playlist.DefaultLanguage
+	update.Localizations = locs
+	return update
+}
+
+// updatePlaylistLocalizations replaces the localizations of playlist with
+// locs. token must be an OAuth access token.
+func updatePlaylistLocalizations(playlist YouTubePlaylist, token string, locs map[string]YouTubeLocalization) error {
+	return updatePlaylistLocalizationsCtx(context.Background(), playlist, token, locs)
+}
+
+func updatePlaylistLocalizationsCtx(ctx context.Context, playlist YouTubePlaylist, token string, locs map[string]YouTubeLocalization) error {
+	if err := checkOAuthToken(token); err != nil {
+		return err
+	}
+
+	requestData, err := json.Marshal(newPlaylistUpdate(playlist, locs))
+	if err != nil {
+		return fmt.Errorf("failed to marshal request data: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", youtubeAPIBaseURL+"/playlists?part=snippet,localizations", bytes.NewBuffer(requestData))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update playlist localizations: %w", newYouTubeError(resp))
+	}
+
+	return nil
+}
+
+// youtubeMaxDescriptionChars is the longest description YouTube accepts.
+const youtubeMaxDescriptionChars = 5000
+
+// truncateAtWord shortens text to at most max characters, cutting at the last
+// word boundary that leaves room for an ellipsis.
+func truncateAtWord(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+
+	const ellipsis = "…"
+	cut := max - utf8.RuneCountInString(ellipsis)
+	if cut <= 0 {
+		return string(runes[:max])
+	}
+	end := cut
+	for end > 0 && !unicode.IsSpace(runes[end]) {
+		end--
+	}
+	if end == 0 {
+		// A single word longer than the limit has no boundary to cut at
+		end = cut
+	}
+	return strings.TrimRightFunc(string(runes[:end]), unicode.IsSpace) + ellipsis
+}
+
+// fitDescription warns when a translated description is longer than YouTube
+// accepts, translations often run longer than the original. With truncate
+// set it is cut down to fit instead.
+func fitDescription(videoID string, targetLang string, description string, truncate bool) string {
+	length := utf8.RuneCountInString(description)
+	if length <= youtubeMaxDescriptionChars {
+		return description
+	}
+	if !truncate {
+		slog.Warn("translated description is too long for YouTube, use -truncate to shorten it",
+			"video", videoID, "target_lang", targetLang, "characters", length, "limit", youtubeMaxDescriptionChars)
+		return description
+	}
+	slog.Warn("truncated translated description to fit YouTube's limit",
+		"video", videoID, "target_lang", targetLang, "characters", length, "limit", youtubeMaxDescriptionChars)
+	return truncateAtWord(description, youtubeMaxDescriptionChars)
+}
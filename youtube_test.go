@@ -0,0 +1,813 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// rewriteDoer sends every request to the test server at target, keeping
+// the path and query, since the API base URLs are constants.
+type rewriteDoer struct {
+	target *url.URL
+	client HTTPDoer
+}
+
+func (d rewriteDoer) Do(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = d.target.Scheme
+	req.URL.Host = d.target.Host
+	req.Host = d.target.Host
+	return d.client.Do(req)
+}
+
+// useStubServer serves every API request of the test with handler, through
+// httpClient, and restores the client afterwards.
+func useStubServer(t *testing.T, handler http.Handler) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous, previousCache := httpClient, youtubeCache
+	httpClient = rewriteDoer{target: target, client: server.Client()}
+	youtubeCache = noopCache{}
+	t.Cleanup(func() {
+		httpClient, youtubeCache = previous, previousCache
+		server.Close()
+	})
+}
+
+func TestFetchYouTubeVideoInfoTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet string
+		want    []string
+	}{
+		{"three tags", `{"title":"T","description":"D","tags":["go","deepl","youtube"]}`, []string{"go", "deepl", "youtube"}},
+		{"no tags", `{"title":"T","description":"D"}`, nil},
+		{"empty tags", `{"title":"T","description":"D","tags":[]}`, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"items":[{"id":"dQw4w9WgXcQ","snippet":` + tt.snippet + `}]}`))
+			}))
+
+			video, err := fetchYouTubeVideoInfo("dQw4w9WgXcQ", "youtube-key")
+			if err != nil {
+				t.Fatalf("fetchYouTubeVideoInfo() error = %v", err)
+			}
+			if !reflect.DeepEqual(video.Tags, tt.want) {
+				t.Errorf("Tags = %#v, want %#v", video.Tags, tt.want)
+			}
+			if video.Title != "T" || video.Description != "D" {
+				t.Errorf("video = %+v, want title T and description D", video)
+			}
+		})
+	}
+}
+
+// knownVideosHandler answers videos requests with the asked for IDs in known,
+// like YouTube leaving out the ones it doesn't know.
+func knownVideosHandler(known map[string]bool, requests *atomic.Int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var items []string
+		for _, id := range strings.Split(r.URL.Query().Get("id"), ",") {
+			if known[id] {
+				items = append(items, `{"id":"`+id+`","snippet":{"title":"Title `+id+`"}}`)
+			}
+		}
+		w.Write([]byte(`{"items":[` + strings.Join(items, ",") + `]}`))
+	})
+}
+
+func TestFetchYouTubeVideos(t *testing.T) {
+	var many []string
+	known := map[string]bool{"vid1": true, "vid3": true}
+	for i := 0; i < youtubeMaxIDsPerRequest+1; i++ {
+		id := "many" + strings.Repeat("x", i)
+		many = append(many, id)
+		known[id] = true
+	}
+
+	tests := []struct {
+		name         string
+		ids          []string
+		want         int
+		wantRequests int64
+	}{
+		{"unknown id is left out", []string{"vid1", "vid2", "vid3"}, 2, 1},
+		{"batches of 50", many, len(many), 2},
+		{"no ids", nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int64
+			useStubServer(t, knownVideosHandler(known, &requests))
+
+			videos, err := fetchYouTubeVideos(tt.ids, "youtube-key")
+			if err != nil {
+				t.Fatalf("fetchYouTubeVideos() error = %v", err)
+			}
+			if len(videos) != tt.want || requests.Load() != tt.wantRequests {
+				t.Errorf("%d videos in %d requests, want %d in %d", len(videos), requests.Load(), tt.want, tt.wantRequests)
+			}
+			for _, video := range videos {
+				if video.Title != "Title "+video.ID {
+					t.Errorf("video %s has title %q", video.ID, video.Title)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchYouTubeVideosPages(t *testing.T) {
+	useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageToken") == "" {
+			w.Write([]byte(`{"nextPageToken":"page2","items":[{"id":"vid1"}]}`))
+			return
+		}
+		w.Write([]byte(`{"items":[{"id":"vid2"}]}`))
+	}))
+
+	videos, err := fetchYouTubeVideos([]string{"vid1", "vid2"}, "youtube-key")
+	if err != nil || len(videos) != 2 || videos[1].ID != "vid2" {
+		t.Errorf("fetchYouTubeVideos() = %+v, %v, want both pages", videos, err)
+	}
+}
+
+func TestFetchYouTubeVideoInfoCtxCancel(t *testing.T) {
+	started := make(chan struct{}, 1)
+	useStubServer(t, blockingHandler(started))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := fetchYouTubeVideoInfoCtx(ctx, "dQw4w9WgXcQ", "youtube-key"); !errors.Is(err, context.Canceled) {
+		t.Errorf("fetchYouTubeVideoInfoCtx() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestUpdateVideoLocalizations(t *testing.T) {
+	var method, auth, part string
+	var body map[string]interface{}
+	useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, auth, part = r.Method, r.Header.Get("Authorization"), r.URL.Query().Get("part")
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{}`))
+	}))
+
+	locs := map[string]YouTubeLocalization{"de": {Title: "Titel", Description: "Beschreibung"}}
+	if err := updateVideoLocalizations("dQw4w9WgXcQ", "ya29.token", locs); err != nil {
+		t.Fatalf("updateVideoLocalizations() error = %v", err)
+	}
+	if method != "PUT" || auth != "Bearer ya29.token" || part != "localizations" {
+		t.Errorf("sent %s with %q and part %q, want PUT with the bearer token and part localizations", method, auth, part)
+	}
+	want := map[string]interface{}{
+		"id": "dQw4w9WgXcQ",
+		"localizations": map[string]interface{}{
+			"de": map[string]interface{}{"title": "Titel", "description": "Beschreibung"},
+		},
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+}
+
+func TestUpdateVideoLocalizationsNeedsOAuth(t *testing.T) {
+	useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("sent a request without an OAuth token")
+	}))
+
+	for _, token := range []string{"", googleAPIKeyPrefix + "SyApiKey"} {
+		if err := updateVideoLocalizations("dQw4w9WgXcQ", token, nil); !errors.Is(err, errOAuthRequired) {
+			t.Errorf("updateVideoLocalizations(%q) error = %v, want errOAuthRequired", token, err)
+		}
+	}
+}
+
+func TestExtractVideoID(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"dQw4w9WgXcQ", "dQw4w9WgXcQ", false},
+		{"  dQw4w9WgXcQ\n", "dQw4w9WgXcQ", false},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ", false},
+		{"https://www.youtube.com/watch?feature=share&v=dQw4w9WgXcQ&t=42s", "dQw4w9WgXcQ", false},
+		{"youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ", false},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ", false},
+		{"https://youtu.be/dQw4w9WgXcQ", "dQw4w9WgXcQ", false},
+		{"https://youtu.be/dQw4w9WgXcQ?si=abc", "dQw4w9WgXcQ", false},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ", "dQw4w9WgXcQ", false},
+		{"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", "dQw4w9WgXcQ", false},
+		{"https://www.youtube.com/shorts/dQw4w9WgXcQ", "dQw4w9WgXcQ", false},
+		{"https://www.youtube.com/live/dQw4w9WgXcQ", "dQw4w9WgXcQ", false},
+		{"https://www.youtube.com/watch?v=short", "", true},
+		{"https://example.com/watch?v=dQw4w9WgXcQ", "", true},
+		{"https://www.youtube.com/channel/UC123", "", true},
+		{"not a video", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := extractVideoID(tt.input)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("extractVideoID(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+// playlistItemsHandler serves the playlistItems of playlists, which maps
+// playlist IDs to their video IDs newest first, one page of maxResults items
+// per request. Item i was published i days before 2024-02-01.
+func playlistItemsHandler(t *testing.T, playlists map[string][]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		ids, ok := playlists[query.Get("playlistId")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"message":"playlist not found","errors":[{"reason":"playlistNotFound"}]}}`))
+			return
+		}
+		size, _ := strconv.Atoi(query.Get("maxResults"))
+		if size <= 0 || size > youtubeMaxIDsPerRequest {
+			t.Errorf("maxResults = %q", query.Get("maxResults"))
+			size = youtubeMaxIDsPerRequest
+		}
+		start, _ := strconv.Atoi(query.Get("pageToken"))
+		end := start + size
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		var response struct {
+			NextPageToken string        `json:"nextPageToken,omitempty"`
+			Items         []interface{} `json:"items"`
+		}
+		for i := start; i < end; i++ {
+			published := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -i)
+			response.Items = append(response.Items, map[string]interface{}{
+				"snippet":        map[string]interface{}{"publishedAt": published},
+				"contentDetails": map[string]string{"videoId": ids[i]},
+			})
+		}
+		if end < len(ids) {
+			response.NextPageToken = strconv.Itoa(end)
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+func TestFetchPlaylistVideoIDs(t *testing.T) {
+	var ids []string
+	for i := 0; i < youtubeMaxIDsPerRequest+5; i++ {
+		ids = append(ids, fmt.Sprintf("vid%08d", i))
+	}
+
+	tests := []struct {
+		name     string
+		playlist string
+		want     []string
+		wantErr  bool
+	}{
+		{"two pages", "PLmany", ids, false},
+		{"empty playlist", "PLempty", nil, false},
+		{"unknown playlist", "PLmissing", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useStubServer(t, playlistItemsHandler(t, map[string][]string{"PLmany": ids, "PLempty": nil}))
+
+			got, err := fetchPlaylistVideoIDs(tt.playlist, "youtube-key")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchPlaylistVideoIDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fetchPlaylistVideoIDs() = %d IDs, want %d in order", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+// channelsHandler serves the channels endpoint for uploads, which maps
+// channel IDs to their uploads playlist, and playlistItems for playlists.
+func channelsHandler(t *testing.T, uploads map[string]string, playlists map[string][]string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/youtube/v3/channels", func(w http.ResponseWriter, r *http.Request) {
+		if part := r.URL.Query().Get("part"); part != "contentDetails" {
+			t.Errorf("part = %q, want contentDetails", part)
+		}
+		items := []interface{}{}
+		if playlist, ok := uploads[r.URL.Query().Get("id")]; ok {
+			items = append(items, map[string]interface{}{
+				"contentDetails": map[string]interface{}{"relatedPlaylists": map[string]string{"uploads": playlist}},
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+	})
+	mux.Handle("/youtube/v3/playlistItems", playlistItemsHandler(t, playlists))
+	return mux
+}
+
+func TestFetchChannelUploads(t *testing.T) {
+	var ids []string
+	for i := 0; i < youtubeMaxIDsPerRequest+10; i++ {
+		ids = append(ids, fmt.Sprintf("vid%08d", i))
+	}
+
+	tests := []struct {
+		name     string
+		channel  string
+		max      int
+		want     []string
+		wantErr  error
+		wantText string
+	}{
+		{name: "all uploads", channel: "UC1", want: ids},
+		{name: "max within a page", channel: "UC1", max: 3, want: ids[:3]},
+		{name: "max across pages", channel: "UC1", max: youtubeMaxIDsPerRequest + 2, want: ids[:youtubeMaxIDsPerRequest+2]},
+		{name: "unknown channel", channel: "UCmissing", wantErr: ErrYouTubeNotFound},
+		{name: "handle", channel: "@someone", wantText: "channel handle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useStubServer(t, channelsHandler(t, map[string]string{"UC1": "UU1"}, map[string][]string{"UU1": ids}))
+
+			got, err := fetchChannelUploads(tt.channel, "youtube-key", tt.max)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("fetchChannelUploads() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantText) {
+					t.Fatalf("fetchChannelUploads() error = %v, want one about a %s", err, tt.wantText)
+				}
+			case err != nil:
+				t.Fatalf("fetchChannelUploads() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fetchChannelUploads() = %d IDs, want %d in order", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestFetchYouTubeVideoInfoErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		wantQuota    bool
+		wantNotFound bool
+	}{
+		{
+			name:      "quota exceeded",
+			status:    http.StatusForbidden,
+			body:      `{"error":{"code":403,"message":"The request cannot be completed because you have exceeded your quota.","errors":[{"reason":"quotaExceeded"}]}}`,
+			wantQuota: true,
+		},
+		{
+			name:      "daily limit exceeded",
+			status:    http.StatusForbidden,
+			body:      `{"error":{"code":403,"message":"Daily Limit Exceeded","errors":[{"reason":"dailyLimitExceeded"}]}}`,
+			wantQuota: true,
+		},
+		{
+			name:   "forbidden",
+			status: http.StatusForbidden,
+			body:   `{"error":{"code":403,"message":"The request is missing a valid API key.","errors":[{"reason":"forbidden"}]}}`,
+		},
+		{
+			name:         "not found",
+			status:       http.StatusNotFound,
+			body:         `{"error":{"code":404,"message":"Requested entity was not found.","errors":[{"reason":"notFound"}]}}`,
+			wantNotFound: true,
+		},
+		{
+			name:   "body without JSON",
+			status: http.StatusBadRequest,
+			body:   "bad request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+
+			_, err := fetchYouTubeVideoInfo("dQw4w9WgXcQ", "youtube-key")
+			var youtubeErr *YouTubeError
+			if !errors.As(err, &youtubeErr) || youtubeErr.StatusCode != tt.status {
+				t.Fatalf("fetchYouTubeVideoInfo() error = %v, want a YouTubeError with status %d", err, tt.status)
+			}
+			if got := errors.Is(err, ErrYouTubeQuotaExceeded); got != tt.wantQuota {
+				t.Errorf("errors.Is(%v, ErrYouTubeQuotaExceeded) = %v, want %v", err, got, tt.wantQuota)
+			}
+			if got := errors.Is(err, ErrYouTubeNotFound); got != tt.wantNotFound {
+				t.Errorf("errors.Is(%v, ErrYouTubeNotFound) = %v, want %v", err, got, tt.wantNotFound)
+			}
+		})
+	}
+}
+
+func TestUploadLocalizationsKeepsExisting(t *testing.T) {
+	manual := YouTubeLocalization{Title: "Von Hand", Description: "Korrigiert"}
+	translated := map[string]YouTubeLocalization{
+		"DE": {Title: "Titel", Description: "Beschreibung"},
+		"FR": {Title: "Titre", Description: "La description"},
+	}
+
+	tests := []struct {
+		name       string
+		translated map[string]YouTubeLocalization
+		fields     videoFields
+		force      bool
+		wantAdded  []string
+		wantSent   map[string]YouTubeLocalization
+	}{
+		{
+			name:       "keep DE",
+			translated: translated,
+			wantAdded:  []string{"FR"},
+			wantSent:   map[string]YouTubeLocalization{"de": manual, "FR": translated["FR"]},
+		},
+		{
+			name:       "force",
+			translated: translated,
+			force:      true,
+			wantAdded:  []string{"DE", "FR"},
+			wantSent:   translated,
+		},
+		{
+			name:       "force only titles",
+			translated: translated,
+			fields:     videoFields{Title: true},
+			force:      true,
+			wantAdded:  []string{"DE", "FR"},
+			wantSent: map[string]YouTubeLocalization{
+				"DE": {Title: "Titel", Description: "Korrigiert"},
+				"FR": {Title: "Titre"},
+			},
+		},
+		{
+			name:       "force only descriptions",
+			translated: translated,
+			fields:     videoFields{Description: true},
+			force:      true,
+			wantAdded:  []string{"DE", "FR"},
+			wantSent: map[string]YouTubeLocalization{
+				"DE": {Title: "Von Hand", Description: "Beschreibung"},
+				"FR": {Description: "La description"},
+			},
+		},
+		{
+			name:       "nothing new",
+			translated: map[string]YouTubeLocalization{"DE": translated["DE"]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent map[string]YouTubeLocalization
+			useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "GET" {
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"items": []interface{}{map[string]interface{}{"localizations": map[string]YouTubeLocalization{"de": manual}}},
+					})
+					return
+				}
+				var body struct {
+					Localizations map[string]YouTubeLocalization `json:"localizations"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+				sent = body.Localizations
+				w.Write([]byte(`{}`))
+			}))
+
+			added, err := uploadLocalizations(context.Background(), "dQw4w9WgXcQ", "ya29.token", tt.translated, tt.fields, tt.force)
+			if err != nil {
+				t.Fatalf("uploadLocalizations() error = %v", err)
+			}
+			if !reflect.DeepEqual(added, tt.wantAdded) {
+				t.Errorf("uploadLocalizations() added %v, want %v", added, tt.wantAdded)
+			}
+			if !reflect.DeepEqual(sent, tt.wantSent) {
+				t.Errorf("sent %v, want %v", sent, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestDeeplToYouTubeLang(t *testing.T) {
+	tests := []struct {
+		deepl   string
+		youtube string
+	}{
+		{"EN-US", "en"},
+		{"EN-GB", "en-GB"},
+		{"PT-BR", "pt-BR"},
+		{"ZH", "zh-Hans"},
+		{"zh-hant", "zh-Hant"},
+		{"HE", "iw"},
+		{"NB", "no"},
+		{"XX", "xx"},
+	}
+	for _, tt := range tests {
+		if got := deeplToYouTubeLang(tt.deepl); got != tt.youtube {
+			t.Errorf("deeplToYouTubeLang(%q) = %q, want %q", tt.deepl, got, tt.youtube)
+		}
+	}
+
+	for youtube, deepl := range map[string]string{"en": "EN", "pt-BR": "PT-BR", "PT-br": "PT-BR", "zh-Hans": "ZH", "iw": "HE", "xx": "XX"} {
+		if got := youtubeToDeeplLang(youtube); got != deepl {
+			t.Errorf("youtubeToDeeplLang(%q) = %q, want %q", youtube, got, deepl)
+		}
+	}
+}
+
+func TestDeeplToYouTubeLangWarnsUnmapped(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	deeplToYouTubeLang("DE")
+	if buf.Len() != 0 {
+		t.Errorf("mapped code logged %q", buf.String())
+	}
+	deeplToYouTubeLang("XX")
+	if !strings.Contains(buf.String(), "level=WARN") || !strings.Contains(buf.String(), "code=XX") {
+		t.Errorf("unmapped code logged %q, want a warning naming it", buf.String())
+	}
+}
+
+func TestFetchYouTubeVideoInfoHL(t *testing.T) {
+	tests := []struct {
+		name      string
+		hl        string
+		localized map[string]string
+		wantSent  bool
+		wantTitle string
+	}{
+		{"no hl", "", map[string]string{"title": "Titel", "description": "Beschreibung"}, false, "Title"},
+		{"localized", "de", map[string]string{"title": "Titel", "description": "Beschreibung"}, true, "Titel"},
+		{"no localization", "ja", map[string]string{"title": "Title", "description": "Description"}, true, "Title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query url.Values
+			useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.Query()
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"items": []interface{}{map[string]interface{}{
+						"id": "dQw4w9WgXcQ",
+						"snippet": map[string]interface{}{
+							"title": "Title", "description": "Description", "localized": tt.localized,
+						},
+					}},
+				})
+			}))
+
+			video, err := fetchYouTubeVideoInfoHL(context.Background(), "dQw4w9WgXcQ", "youtube-key", tt.hl)
+			if err != nil {
+				t.Fatalf("fetchYouTubeVideoInfoHL() error = %v", err)
+			}
+			if got, sent := query.Get("hl"), query.Has("hl"); sent != tt.wantSent || got != tt.hl {
+				t.Errorf("hl = %q (sent %v), want %q sent %v", got, sent, tt.hl, tt.wantSent)
+			}
+			if video.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", video.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestFetchPlaylistInfo(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		want         YouTubePlaylist
+		wantNotFound bool
+		wantAPIErr   bool
+	}{
+		{
+			name: "snippet and localizations",
+			body: `{"items":[{"id":"PL123","snippet":{"title":"Tutorials","description":"All of them","defaultLanguage":"en"},` +
+				`"localizations":{"de":{"title":"Anleitungen","description":"Alle"}}}]}`,
+			want: YouTubePlaylist{ID: "PL123", Title: "Tutorials", Description: "All of them", DefaultLanguage: "en",
+				Localizations: map[string]YouTubeLocalization{"de": {Title: "Anleitungen", Description: "Alle"}}},
+		},
+		{
+			name: "no localizations",
+			body: `{"items":[{"id":"PL123","snippet":{"title":"Tutorials"}}]}`,
+			want: YouTubePlaylist{ID: "PL123", Title: "Tutorials", Localizations: map[string]YouTubeLocalization{}},
+		},
+		{name: "unknown playlist", body: `{"items":[]}`, wantNotFound: true},
+		{name: "forbidden", status: http.StatusForbidden, body: `{"error":{"code":403,"message":"quota"}}`, wantAPIErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/youtube/v3/playlists" || r.URL.Query().Get("id") != "PL123" || r.URL.Query().Get("part") != "snippet,localizations" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				w.Write([]byte(tt.body))
+			}))
+
+			got, err := fetchPlaylistInfo("PL123", "youtube-key")
+			var youtubeErr *YouTubeError
+			switch {
+			case tt.wantNotFound:
+				if !errors.Is(err, ErrYouTubeNotFound) {
+					t.Errorf("fetchPlaylistInfo() error = %v, want ErrYouTubeNotFound", err)
+				}
+			case tt.wantAPIErr:
+				if !errors.As(err, &youtubeErr) {
+					t.Errorf("fetchPlaylistInfo() error = %v, want a YouTubeError", err)
+				}
+			case err != nil:
+				t.Fatalf("fetchPlaylistInfo() error = %v", err)
+			case !reflect.DeepEqual(got, tt.want):
+				t.Errorf("fetchPlaylistInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdatePlaylistLocalizations(t *testing.T) {
+	var body map[string]interface{}
+	useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/youtube/v3/playlists" || r.URL.Query().Get("part") != "snippet,localizations" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if r.Header.Get("Authorization") != "Bearer ya29.token" {
+			t.Errorf("Authorization = %q, want the OAuth token", r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{}`))
+	}))
+
+	playlist := YouTubePlaylist{ID: "PL123", Title: "Tutorials", Description: "All of them", DefaultLanguage: "en"}
+	locs := map[string]YouTubeLocalization{"de": {Title: "Anleitungen", Description: "Alle"}}
+	if err := updatePlaylistLocalizations(playlist, "ya29.token", locs); err != nil {
+		t.Fatalf("updatePlaylistLocalizations() error = %v", err)
+	}
+
+	// The snippet goes along unchanged since YouTube replaces all of it
+	want := map[string]interface{}{
+		"id": "PL123",
+		"snippet": map[string]interface{}{
+			"title": "Tutorials", "description": "All of them", "defaultLanguage": "en",
+		},
+		"localizations": map[string]interface{}{
+			"de": map[string]interface{}{"title": "Anleitungen", "description": "Alle"},
+		},
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("request body = %v, want %v", body, want)
+	}
+
+	if err := updatePlaylistLocalizations(playlist, "", locs); !errors.Is(err, errOAuthRequired) {
+		t.Errorf("updatePlaylistLocalizations() without a token error = %v, want errOAuthRequired", err)
+	}
+}
+
+func TestTruncateAtWord(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"short enough", 20, "short enough"},
+		{"exactly ten", 11, "exactly ten"},
+		{"cut at the last word", 15, "cut at the…"},
+		{"trailing  spaces here", 12, "trailing…"},
+		{"Grüße aus München", 12, "Grüße aus…"},
+		{"unbreakablewordwithoutspaces", 10, "unbreakab…"},
+		{"tiny", 1, "t"},
+	}
+	for _, tt := range tests {
+		got := truncateAtWord(tt.text, tt.max)
+		if got != tt.want {
+			t.Errorf("truncateAtWord(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+		if n := utf8.RuneCountInString(got); n > tt.max {
+			t.Errorf("truncateAtWord(%q, %d) is %d characters long", tt.text, tt.max, n)
+		}
+	}
+}
+
+func TestFitDescription(t *testing.T) {
+	long := strings.Repeat("word ", youtubeMaxDescriptionChars/5+10)
+
+	tests := []struct {
+		name        string
+		description string
+		truncate    bool
+		wantChanged bool
+	}{
+		{"within the limit", "Beschreibung", true, false},
+		{"too long without -truncate", long, false, false},
+		{"too long with -truncate", long, true, true},
+	}
+	for _, tt := range tests {
+		got := fitDescription("dQw4w9WgXcQ", "DE", tt.description, tt.truncate)
+		if (got != tt.description) != tt.wantChanged {
+			t.Errorf("%s: fitDescription() changed = %v, want %v", tt.name, got != tt.description, tt.wantChanged)
+		}
+		if tt.wantChanged && utf8.RuneCountInString(got) > youtubeMaxDescriptionChars {
+			t.Errorf("%s: fitDescription() left %d characters", tt.name, utf8.RuneCountInString(got))
+		}
+	}
+}
+
+func TestFetchYouTubeVideoInfoETag(t *testing.T) {
+	var etag, title string
+	var sentIfNoneMatch []string
+	useStubServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sentIfNoneMatch = append(sentIfNoneMatch, r.Header.Get("If-None-Match"))
+		if etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{
+				"id":      "dQw4w9WgXcQ",
+				"snippet": map[string]string{"title": title, "description": "Description"},
+			}},
+		})
+	}))
+	youtubeCache = NewMemoryCache(10)
+
+	tests := []struct {
+		name            string
+		etag            string
+		title           string
+		wantIfNoneMatch string
+		wantTitle       string
+	}{
+		{"first fetch", `"v1"`, "Title", "", "Title"},
+		{"unchanged returns the cached copy", `"v1"`, "Title", `"v1"`, "Title"},
+		{"changed video", `"v2"`, "New title", `"v1"`, "New title"},
+		{"changed copy is cached", `"v2"`, "New title", `"v2"`, "New title"},
+		{"response without an ETag", "", "Newest title", `"v2"`, "Newest title"},
+	}
+	for _, tt := range tests {
+		etag, title = tt.etag, tt.title
+		sentIfNoneMatch = nil
+
+		video, err := fetchYouTubeVideoInfo("dQw4w9WgXcQ", "youtube-key")
+		if err != nil {
+			t.Fatalf("%s: fetchYouTubeVideoInfo() error = %v", tt.name, err)
+		}
+		if len(sentIfNoneMatch) != 1 || sentIfNoneMatch[0] != tt.wantIfNoneMatch {
+			t.Errorf("%s: sent If-None-Match %q, want %q", tt.name, sentIfNoneMatch, tt.wantIfNoneMatch)
+		}
+		want := YouTubeVideo{ID: "dQw4w9WgXcQ", Title: tt.wantTitle, Description: "Description"}
+		if video.ID != want.ID || video.Title != want.Title || video.Description != want.Description {
+			t.Errorf("%s: fetchYouTubeVideoInfo() = %+v, want %+v", tt.name, video, want)
+		}
+	}
+
+	// Each hl has its own cached copy
+	if _, err := fetchYouTubeVideoInfoHL(context.Background(), "dQw4w9WgXcQ", "youtube-key", "de"); err != nil {
+		t.Fatal(err)
+	}
+	if sentIfNoneMatch[len(sentIfNoneMatch)-1] != "" {
+		t.Errorf("hl=de sent If-None-Match %q from the default metadata", sentIfNoneMatch[len(sentIfNoneMatch)-1])
+	}
+}